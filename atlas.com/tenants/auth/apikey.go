@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"net/http"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// NewAPIKeyAuthenticator creates an Authenticator for static API keys presented in the X-API-Key header. Keys are
+// indexed by their digest, so lookups do not compare the presented key against stored keys directly.
+func NewAPIKeyAuthenticator(keys map[string]Principal) Authenticator {
+	index := make(map[[sha256.Size]byte]Principal, len(keys))
+	for key, p := range keys {
+		index[sha256.Sum256([]byte(key))] = p
+	}
+
+	return AuthenticatorFunc(func(r *http.Request) (Principal, error) {
+		key := r.Header.Get(APIKeyHeader)
+		if key == "" {
+			return Principal{}, ErrNoCredentials
+		}
+		p, ok := index[sha256.Sum256([]byte(key))]
+		if !ok {
+			return Principal{}, ErrInvalidCredentials
+		}
+		return p, nil
+	})
+}
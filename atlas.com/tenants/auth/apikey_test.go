@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	tenantId := uuid.New()
+	a := NewAPIKeyAuthenticator(map[string]Principal{
+		"ops-key":    NewBuilder("ops").SetMethod(MethodAPIKey).SetRole(RoleOperator).Build(),
+		"scoped-key": NewBuilder("scoped").SetMethod(MethodAPIKey).SetRole(RoleViewer).SetTenantIds([]uuid.UUID{tenantId}).Build(),
+	})
+
+	tests := []struct {
+		name        string
+		key         string
+		wantErr     error
+		wantSubject string
+		wantRole    Role
+		wantScoped  bool
+	}{
+		{name: "no key", wantErr: ErrNoCredentials},
+		{name: "unknown key", key: "guess", wantErr: ErrInvalidCredentials},
+		{name: "key", key: "ops-key", wantSubject: "ops", wantRole: RoleOperator},
+		{name: "scoped key", key: "scoped-key", wantSubject: "scoped", wantRole: RoleViewer, wantScoped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/tenants", nil)
+			if tt.key != "" {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+
+			p, err := a.Authenticate(r)
+			if err != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if p.Subject() != tt.wantSubject || p.Role() != tt.wantRole || p.Method() != MethodAPIKey || p.Scoped() != tt.wantScoped {
+				t.Errorf("Authenticate() = %s, want subject %s with role %s", p, tt.wantSubject, tt.wantRole)
+			}
+			if tt.wantScoped && (!p.CanAccessTenant(tenantId) || p.CanAccessTenant(uuid.New())) {
+				t.Errorf("Authenticate() = %s, want access to only tenant %s", p, tenantId)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	keys := NewAPIKeyAuthenticator(map[string]Principal{"ops-key": NewBuilder("ops").SetMethod(MethodAPIKey).SetRole(RoleOperator).Build()})
+	jwts := NewJWTAuthenticator(testKeys)
+
+	tests := []struct {
+		name        string
+		key         string
+		bearer      string
+		wantErr     error
+		wantSubject string
+	}{
+		{name: "no credentials", wantErr: ErrNoCredentials},
+		{name: "api key", key: "ops-key", wantSubject: "ops"},
+		{name: "invalid api key stops the chain", key: "guess", bearer: "token", wantErr: ErrInvalidCredentials},
+		{name: "invalid token", bearer: "token", wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/tenants", nil)
+			if tt.key != "" {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+
+			p, err := Chain(keys, jwts).Authenticate(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && p.Subject() != tt.wantSubject {
+				t.Errorf("Authenticate() = %s, want subject %s", p, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestDeny(t *testing.T) {
+	if _, err := Deny().Authenticate(httptest.NewRequest("GET", "/api/tenants", nil)); err != ErrNoCredentials {
+		t.Fatalf("Authenticate() error = %v, want %v", err, ErrNoCredentials)
+	}
+}
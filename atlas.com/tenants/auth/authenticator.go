@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"atlas-tenants/domain"
+	"errors"
+	"net/http"
+)
+
+const (
+	MethodNone   = "none"
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+	// MethodCommand identifies a principal acting through a Kafka command, which the service does not authenticate
+	MethodCommand = "command"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request carries no credentials it understands
+	ErrNoCredentials = domain.Unauthenticated("UNAUTHENTICATED", "authentication required")
+	// ErrInvalidCredentials is returned when the request carries credentials which cannot be verified
+	ErrInvalidCredentials = domain.Unauthenticated("INVALID_CREDENTIALS", "credentials could not be verified")
+	// ErrForbidden is returned when the principal's role does not permit the operation
+	ErrForbidden = domain.Forbidden("FORBIDDEN", "operation not permitted for the caller's role")
+	// ErrTenantForbidden is returned when the principal is not permitted to act on the requested tenant
+	ErrTenantForbidden = domain.Forbidden("TENANT_FORBIDDEN", "operation not permitted on the requested tenant")
+)
+
+// Authenticator establishes the principal of a request
+type Authenticator interface {
+	// Authenticate returns the principal of the request, ErrNoCredentials when the request carries no credentials
+	// the authenticator understands, or ErrInvalidCredentials when they cannot be verified.
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator
+type AuthenticatorFunc func(r *http.Request) (Principal, error)
+
+// Authenticate calls the function
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (Principal, error) {
+	return f(r)
+}
+
+// Chain returns an Authenticator which tries each authenticator in order, until one finds credentials it understands
+func Chain(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (Principal, error) {
+		for _, a := range authenticators {
+			p, err := a.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			return p, err
+		}
+		return Principal{}, ErrNoCredentials
+	})
+}
+
+// Anonymous returns an Authenticator which grants every request the given role. It is used when authentication is
+// disabled.
+func Anonymous(role Role) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (Principal, error) {
+		return NewBuilder("anonymous").SetMethod(MethodNone).SetRole(role).Build(), nil
+	})
+}
+
+// Deny returns an Authenticator which rejects every request as unauthenticated. It is used until authentication is
+// configured.
+func Deny() Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (Principal, error) {
+		return Principal{}, ErrNoCredentials
+	})
+}
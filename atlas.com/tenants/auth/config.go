@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config is the authentication and authorization applied to every registered handler
+type Config struct {
+	authenticator Authenticator
+	policy        Policy
+}
+
+// NewConfig creates a new Config
+func NewConfig(authenticator Authenticator, policy Policy) Config {
+	return Config{
+		authenticator: authenticator,
+		policy:        policy,
+	}
+}
+
+// Authenticator returns the authenticator establishing the principal of requests
+func (c Config) Authenticator() Authenticator {
+	return c.authenticator
+}
+
+// Policy returns the policy authorizing handler invocations
+func (c Config) Policy() Policy {
+	return c.policy
+}
+
+// FromEnvironment creates a Config from the AUTH_API_KEYS, AUTH_JWT_KEYS, AUTH_DISABLED and AUTH_ROLES environment
+// variables. Either API keys or JWT keys must be configured, unless AUTH_DISABLED is true, in which case every request
+// is granted the admin role.
+func FromEnvironment(l logrus.FieldLogger) (Config, error) {
+	policy := DefaultPolicy()
+	for _, entry := range splitList(os.Getenv("AUTH_ROLES")) {
+		handlerName, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("invalid AUTH_ROLES entry [%s]", entry)
+		}
+		role, err := ParseRole(value)
+		if err != nil {
+			return Config{}, err
+		}
+		policy = policy.WithRole(handlerName, role)
+	}
+
+	authenticators := make([]Authenticator, 0)
+
+	apiKeys, err := parseAPIKeys(os.Getenv("AUTH_API_KEYS"))
+	if err != nil {
+		return Config{}, err
+	}
+	if len(apiKeys) > 0 {
+		authenticators = append(authenticators, NewAPIKeyAuthenticator(apiKeys))
+	}
+
+	jwtKeys, err := parseJWTKeys(os.Getenv("AUTH_JWT_KEYS"))
+	if err != nil {
+		return Config{}, err
+	}
+	if len(jwtKeys) > 0 {
+		authenticators = append(authenticators, NewJWTAuthenticator(jwtKeys))
+	}
+
+	disabled, err := parseDisabled(os.Getenv("AUTH_DISABLED"))
+	if err != nil {
+		return Config{}, err
+	}
+	if disabled {
+		if len(authenticators) > 0 {
+			return Config{}, errors.New("AUTH_DISABLED is set while AUTH_API_KEYS or AUTH_JWT_KEYS is configured")
+		}
+		l.Warnf("Authentication is disabled. Every request is granted the [%s] role.", RoleAdmin)
+		return NewConfig(Anonymous(RoleAdmin), policy), nil
+	}
+	if len(authenticators) == 0 {
+		return Config{}, errors.New("no AUTH_API_KEYS or AUTH_JWT_KEYS configured, and AUTH_DISABLED is not set")
+	}
+	l.Infof("Authentication enabled with [%d] API keys and [%d] JWT keys.", len(apiKeys), len(jwtKeys))
+	return NewConfig(Chain(authenticators...), policy), nil
+}
+
+// parseDisabled parses the AUTH_DISABLED flag, which is false when unset
+func parseDisabled(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	disabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid AUTH_DISABLED [%s]", value)
+	}
+	return disabled, nil
+}
+
+// parseAPIKeys parses entries of the form subject:key:role[:tenantId|tenantId...]
+func parseAPIKeys(value string) (map[string]Principal, error) {
+	results := make(map[string]Principal)
+	for _, entry := range splitList(value) {
+		parts := strings.Split(entry, ":")
+		if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid AUTH_API_KEYS entry for subject [%s]", parts[0])
+		}
+		role, err := ParseRole(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		tenantIds := make([]uuid.UUID, 0)
+		if len(parts) == 4 {
+			for _, t := range strings.Split(parts[3], "|") {
+				id, err := uuid.Parse(t)
+				if err != nil {
+					return nil, fmt.Errorf("invalid tenant [%s] for API key subject [%s]", t, parts[0])
+				}
+				tenantIds = append(tenantIds, id)
+			}
+		}
+		results[parts[1]] = NewBuilder(parts[0]).SetMethod(MethodAPIKey).SetRole(role).SetTenantIds(tenantIds).Build()
+	}
+	return results, nil
+}
+
+// parseJWTKeys parses entries of the form keyId:secret
+func parseJWTKeys(value string) (map[string][]byte, error) {
+	results := make(map[string][]byte)
+	for _, entry := range splitList(value) {
+		keyId, secret, ok := strings.Cut(entry, ":")
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("invalid AUTH_JWT_KEYS entry for key [%s]", keyId)
+		}
+		results[keyId] = []byte(secret)
+	}
+	return results, nil
+}
+
+func splitList(value string) []string {
+	results := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			results = append(results, v)
+		}
+	}
+	return results
+}
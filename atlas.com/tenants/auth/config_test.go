@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"github.com/sirupsen/logrus/hooks/test"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// setEnvironment sets exactly the given authentication variables for the test
+func setEnvironment(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, k := range []string{"AUTH_API_KEYS", "AUTH_JWT_KEYS", "AUTH_DISABLED", "AUTH_ROLES"} {
+		t.Setenv(k, env[k])
+	}
+}
+
+func TestFromEnvironment(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		apiKey        string
+		wantSubject   string
+		wantAnonymous bool
+	}{
+		{name: "api keys", env: map[string]string{"AUTH_API_KEYS": "ops:ops-key:operator"}, apiKey: "ops-key", wantSubject: "ops"},
+		{name: "jwt keys", env: map[string]string{"AUTH_JWT_KEYS": "primary:secret"}},
+		{name: "disabled", env: map[string]string{"AUTH_DISABLED": "true"}, wantSubject: "anonymous", wantAnonymous: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnvironment(t, tt.env)
+			l, _ := test.NewNullLogger()
+
+			c, err := FromEnvironment(l)
+			if err != nil {
+				t.Fatalf("FromEnvironment() error = %v", err)
+			}
+			r := httptest.NewRequest("GET", "/api/tenants", nil)
+			if tt.apiKey != "" {
+				r.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			p, err := c.Authenticator().Authenticate(r)
+			if tt.wantSubject == "" {
+				if err != ErrNoCredentials {
+					t.Errorf("Authenticate() without credentials error = %v, want %v", err, ErrNoCredentials)
+				}
+				return
+			}
+			if err != nil || p.Subject() != tt.wantSubject || p.Anonymous() != tt.wantAnonymous {
+				t.Errorf("Authenticate() = %s, %v, want subject %s", p, err, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestFromEnvironmentErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "nothing configured", env: map[string]string{}, wantErr: "AUTH_DISABLED is not set"},
+		{name: "disabled false", env: map[string]string{"AUTH_DISABLED": "false"}, wantErr: "AUTH_DISABLED is not set"},
+		{name: "disabled with keys", env: map[string]string{"AUTH_DISABLED": "true", "AUTH_API_KEYS": "ops:ops-key:operator"}, wantErr: "AUTH_DISABLED is set"},
+		{name: "invalid disabled", env: map[string]string{"AUTH_DISABLED": "maybe"}, wantErr: "invalid AUTH_DISABLED"},
+		{name: "api key without role", env: map[string]string{"AUTH_API_KEYS": "ops:ops-key"}, wantErr: "invalid AUTH_API_KEYS"},
+		{name: "api key role", env: map[string]string{"AUTH_API_KEYS": "ops:ops-key:owner"}, wantErr: "unknown role"},
+		{name: "api key tenant", env: map[string]string{"AUTH_API_KEYS": "ops:ops-key:operator:tenant"}, wantErr: "invalid tenant"},
+		{name: "jwt key", env: map[string]string{"AUTH_JWT_KEYS": "primary"}, wantErr: "invalid AUTH_JWT_KEYS"},
+		{name: "roles entry", env: map[string]string{"AUTH_DISABLED": "true", "AUTH_ROLES": "get_all_tenants"}, wantErr: "invalid AUTH_ROLES"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnvironment(t, tt.env)
+			l, _ := test.NewNullLogger()
+
+			_, err := FromEnvironment(l)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("FromEnvironment() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFromEnvironmentRoles(t *testing.T) {
+	setEnvironment(t, map[string]string{"AUTH_DISABLED": "true", "AUTH_ROLES": "get_all_tenants=admin, delete_route=viewer"})
+	l, _ := test.NewNullLogger()
+
+	c, err := FromEnvironment(l)
+	if err != nil {
+		t.Fatalf("FromEnvironment() error = %v", err)
+	}
+	if got := c.Policy().RequiredRole("get_all_tenants"); got != RoleAdmin {
+		t.Errorf("RequiredRole(get_all_tenants) = %s, want %s", got, RoleAdmin)
+	}
+	if got := c.Policy().RequiredRole("delete_route"); got != RoleViewer {
+		t.Errorf("RequiredRole(delete_route) = %s, want %s", got, RoleViewer)
+	}
+}
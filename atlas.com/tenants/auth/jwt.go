@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clockSkew is the tolerance applied to the exp and nbf claims
+const clockSkew = 30 * time.Second
+
+var algorithms = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyId     string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Role      string   `json:"role"`
+	Tenants   []string `json:"tenants"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+}
+
+// NewJWTAuthenticator creates an Authenticator for HMAC signed JWTs presented as bearer tokens. Tokens are verified
+// against the key named by their kid header, or against the only key when a single key is configured and the token
+// names none. Tokens must carry an exp claim. The role claim grants the principal's role, and the optional tenants claim limits it to those tenants.
+func NewJWTAuthenticator(keys map[string][]byte) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (Principal, error) {
+		token, ok := bearerToken(r)
+		if !ok {
+			return Principal{}, ErrNoCredentials
+		}
+		p, err := verifyJWT(keys, time.Now(), token)
+		if err != nil {
+			return Principal{}, ErrInvalidCredentials.WithDetail(err.Error()).Wrap(err)
+		}
+		return p, nil
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	value := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(value, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func verifyJWT(keys map[string][]byte, now time.Time, token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Principal{}, fmt.Errorf("malformed token header: %w", err)
+	}
+	alg, ok := algorithms[header.Algorithm]
+	if !ok {
+		return Principal{}, fmt.Errorf("unsupported algorithm [%s]", header.Algorithm)
+	}
+	key, ok := signingKey(keys, header.KeyId)
+	if !ok {
+		return Principal{}, fmt.Errorf("unknown key [%s]", header.KeyId)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("malformed token signature: %w", err)
+	}
+	mac := hmac.New(alg, key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return Principal{}, errors.New("invalid token signature")
+	}
+
+	var claims jwtClaims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if claims.ExpiresAt == nil {
+		return Principal{}, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(*claims.ExpiresAt, 0).Add(clockSkew)) {
+		return Principal{}, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now.Add(clockSkew).Before(time.Unix(*claims.NotBefore, 0)) {
+		return Principal{}, errors.New("token not yet valid")
+	}
+	if claims.Subject == "" {
+		return Principal{}, errors.New("token has no subject")
+	}
+
+	role, err := ParseRole(claims.Role)
+	if err != nil {
+		return Principal{}, err
+	}
+	tenantIds := make([]uuid.UUID, 0, len(claims.Tenants))
+	for _, t := range claims.Tenants {
+		id, err := uuid.Parse(t)
+		if err != nil {
+			return Principal{}, fmt.Errorf("invalid tenant [%s]", t)
+		}
+		tenantIds = append(tenantIds, id)
+	}
+
+	return NewBuilder(claims.Subject).
+		SetMethod(MethodJWT).
+		SetRole(role).
+		SetTenantIds(tenantIds).
+		Build(), nil
+}
+
+func signingKey(keys map[string][]byte, keyId string) ([]byte, bool) {
+	if keyId == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, true
+		}
+	}
+	k, ok := keys[keyId]
+	return k, ok
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
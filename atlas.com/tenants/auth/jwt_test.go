@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var testKeys = map[string][]byte{"primary": []byte("secret")}
+
+// signToken encodes the header and claims and signs them with HS256 and the key
+func signToken(t *testing.T, header map[string]interface{}, claims map[string]interface{}, key []byte) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("unable to encode token segment: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := segment(header) + "." + segment(claims)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	now := time.Unix(1750000000, 0)
+	header := map[string]interface{}{"alg": "HS256", "kid": "primary"}
+	claims := func(modify func(c map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":  "operator@example.com",
+			"role": "operator",
+			"exp":  now.Add(time.Hour).Unix(),
+		}
+		modify(c)
+		return c
+	}
+	valid := func(map[string]interface{}) {}
+
+	tests := []struct {
+		name    string
+		token   func(t *testing.T) string
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			token: func(t *testing.T) string { return signToken(t, header, claims(valid), testKeys["primary"]) },
+		},
+		{
+			name: "expired within tolerance",
+			token: func(t *testing.T) string {
+				return signToken(t, header, claims(func(c map[string]interface{}) { c["exp"] = now.Add(-10 * time.Second).Unix() }), testKeys["primary"])
+			},
+		},
+		{
+			name:    "bad signature",
+			token:   func(t *testing.T) string { return signToken(t, header, claims(valid), []byte("other")) },
+			wantErr: "invalid token signature",
+		},
+		{
+			name: "wrong algorithm",
+			token: func(t *testing.T) string {
+				return signToken(t, map[string]interface{}{"alg": "none", "kid": "primary"}, claims(valid), testKeys["primary"])
+			},
+			wantErr: "unsupported algorithm [none]",
+		},
+		{
+			name: "unknown key",
+			token: func(t *testing.T) string {
+				return signToken(t, map[string]interface{}{"alg": "HS256", "kid": "retired"}, claims(valid), testKeys["primary"])
+			},
+			wantErr: "unknown key [retired]",
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				return signToken(t, header, claims(func(c map[string]interface{}) { c["exp"] = now.Add(-time.Minute).Unix() }), testKeys["primary"])
+			},
+			wantErr: "token expired",
+		},
+		{
+			name: "not yet valid",
+			token: func(t *testing.T) string {
+				return signToken(t, header, claims(func(c map[string]interface{}) { c["nbf"] = now.Add(time.Minute).Unix() }), testKeys["primary"])
+			},
+			wantErr: "token not yet valid",
+		},
+		{
+			name: "missing expiry",
+			token: func(t *testing.T) string {
+				return signToken(t, header, claims(func(c map[string]interface{}) { delete(c, "exp") }), testKeys["primary"])
+			},
+			wantErr: "token has no expiry",
+		},
+		{
+			name: "missing subject",
+			token: func(t *testing.T) string {
+				return signToken(t, header, claims(func(c map[string]interface{}) { delete(c, "sub") }), testKeys["primary"])
+			},
+			wantErr: "token has no subject",
+		},
+		{
+			name:    "malformed",
+			token:   func(*testing.T) string { return "not-a-token" },
+			wantErr: "malformed token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := verifyJWT(testKeys, now, tt.token(t))
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("verifyJWT() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyJWT() error = %v", err)
+			}
+			if p.Subject() != "operator@example.com" || p.Role() != RoleOperator || p.Method() != MethodJWT {
+				t.Errorf("verifyJWT() = %v, want the operator principal", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+// Policy maps handler names to the role required to invoke them
+type Policy struct {
+	roles    map[string]Role
+	fallback Role
+	filtered map[string]bool
+}
+
+// DefaultPolicy returns the policy of the admin API. Reads require viewer, configuration changes require operator and
+// tenant changes require admin. Handlers without an entry require admin.
+func DefaultPolicy() Policy {
+	return Policy{
+		roles: map[string]Role{
+			"get_all_tenants":            RoleViewer,
+			"get_tenant_by_id":           RoleViewer,
+			"get_all_configurations":     RoleViewer,
+			"get_all_routes":             RoleViewer,
+			"get_route_by_id":            RoleViewer,
+			"get_all_vessels":            RoleViewer,
+			"get_vessel_by_id":           RoleViewer,
+			"get_configuration_diff":     RoleViewer,
+			"create_route":               RoleOperator,
+			"update_route":               RoleOperator,
+			"delete_route":               RoleOperator,
+			"create_vessel":              RoleOperator,
+			"update_vessel":              RoleOperator,
+			"delete_vessel":              RoleOperator,
+			"promote_configuration":      RoleOperator,
+			"create_tenant":              RoleAdmin,
+			"update_tenant":              RoleAdmin,
+			"delete_tenant":              RoleAdmin,
+			"reconcile_tenant_snapshots": RoleAdmin,
+			"replay_events":              RoleAdmin,
+			"get_event_replay":           RoleAdmin,
+		},
+		fallback: RoleAdmin,
+		filtered: map[string]bool{
+			"get_all_tenants": true,
+		},
+	}
+}
+
+// WithRole returns a copy of the policy requiring the role for the handler
+func (p Policy) WithRole(handlerName string, role Role) Policy {
+	roles := make(map[string]Role, len(p.roles)+1)
+	for k, v := range p.roles {
+		roles[k] = v
+	}
+	roles[handlerName] = role
+	p.roles = roles
+	return p
+}
+
+// RequiredRole returns the role required to invoke the handler
+func (p Policy) RequiredRole(handlerName string) Role {
+	if r, ok := p.roles[handlerName]; ok {
+		return r
+	}
+	return p.fallback
+}
+
+// FiltersByTenant returns true if the handler names no tenant, and instead limits its results to the tenants the
+// caller may access. Callers limited to specific tenants may only invoke such handlers when no tenant is named.
+func (p Policy) FiltersByTenant(handlerName string) bool {
+	return p.filtered[handlerName]
+}
@@ -0,0 +1,68 @@
+package auth
+
+import "testing"
+
+func TestDefaultPolicy(t *testing.T) {
+	tests := []struct {
+		handlerName string
+		want        Role
+	}{
+		{handlerName: "get_all_tenants", want: RoleViewer},
+		{handlerName: "get_tenant_by_id", want: RoleViewer},
+		{handlerName: "get_all_configurations", want: RoleViewer},
+		{handlerName: "get_route_by_id", want: RoleViewer},
+		{handlerName: "get_configuration_diff", want: RoleViewer},
+		{handlerName: "create_route", want: RoleOperator},
+		{handlerName: "delete_vessel", want: RoleOperator},
+		{handlerName: "promote_configuration", want: RoleOperator},
+		{handlerName: "create_tenant", want: RoleAdmin},
+		{handlerName: "update_tenant", want: RoleAdmin},
+		{handlerName: "delete_tenant", want: RoleAdmin},
+		{handlerName: "reconcile_tenant_snapshots", want: RoleAdmin},
+		{handlerName: "replay_events", want: RoleAdmin},
+		{handlerName: "unregistered_handler", want: RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.handlerName, func(t *testing.T) {
+			if got := DefaultPolicy().RequiredRole(tt.handlerName); got != tt.want {
+				t.Errorf("RequiredRole(%s) = %s, want %s", tt.handlerName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyWithRole(t *testing.T) {
+	p := DefaultPolicy()
+	overridden := p.WithRole("get_all_tenants", RoleAdmin)
+
+	if got := overridden.RequiredRole("get_all_tenants"); got != RoleAdmin {
+		t.Errorf("RequiredRole(get_all_tenants) = %s, want %s", got, RoleAdmin)
+	}
+	if got := p.RequiredRole("get_all_tenants"); got != RoleViewer {
+		t.Errorf("RequiredRole(get_all_tenants) of the original policy = %s, want %s", got, RoleViewer)
+	}
+	if !overridden.FiltersByTenant("get_all_tenants") || overridden.FiltersByTenant("get_tenant_by_id") {
+		t.Error("FiltersByTenant() want only get_all_tenants")
+	}
+}
+
+func TestRoleIncludes(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		want     bool
+	}{
+		{role: RoleAdmin, required: RoleViewer, want: true},
+		{role: RoleOperator, required: RoleOperator, want: true},
+		{role: RoleOperator, required: RoleAdmin, want: false},
+		{role: RoleViewer, required: RoleOperator, want: false},
+		{role: Role(""), required: RoleViewer, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role)+" "+string(tt.required), func(t *testing.T) {
+			if got := tt.role.Includes(tt.required); got != tt.want {
+				t.Errorf("%s.Includes(%s) = %t, want %t", tt.role, tt.required, got, tt.want)
+			}
+		})
+	}
+}
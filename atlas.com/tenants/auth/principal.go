@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+type principalKey struct{}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	subject   string
+	method    string
+	role      Role
+	tenantIds []uuid.UUID
+}
+
+// Subject returns the name of the caller
+func (p Principal) Subject() string {
+	return p.subject
+}
+
+// Method returns the authentication method which established the principal
+func (p Principal) Method() string {
+	return p.method
+}
+
+// Role returns the role granted to the caller
+func (p Principal) Role() Role {
+	return p.role
+}
+
+// TenantIds returns the tenants the caller is limited to. An empty result means the caller is not limited.
+func (p Principal) TenantIds() []uuid.UUID {
+	return p.tenantIds
+}
+
+// Scoped returns true if the caller is limited to specific tenants
+func (p Principal) Scoped() bool {
+	return len(p.tenantIds) > 0
+}
+
+// CanAccessTenant returns true if the caller may act on the tenant
+func (p Principal) CanAccessTenant(tenantId uuid.UUID) bool {
+	if !p.Scoped() {
+		return true
+	}
+	for _, id := range p.tenantIds {
+		if id == tenantId {
+			return true
+		}
+	}
+	return false
+}
+
+// Anonymous returns true if the principal was not established by any credentials
+func (p Principal) Anonymous() bool {
+	return p.method == MethodNone
+}
+
+// String returns a string representation of the principal
+func (p Principal) String() string {
+	return fmt.Sprintf("Subject [%s] Method [%s] Role [%s] Tenants [%d]", p.subject, p.method, p.role, len(p.tenantIds))
+}
+
+// WithPrincipal returns a context carrying the principal acting in it
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal acting in ctx, if there is one
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Builder is used to build a Principal
+type Builder struct {
+	subject   string
+	method    string
+	role      Role
+	tenantIds []uuid.UUID
+}
+
+// NewBuilder creates a new Builder
+func NewBuilder(subject string) *Builder {
+	return &Builder{
+		subject:   subject,
+		tenantIds: make([]uuid.UUID, 0),
+	}
+}
+
+// SetMethod sets the authentication method
+func (b *Builder) SetMethod(method string) *Builder {
+	b.method = method
+	return b
+}
+
+// SetRole sets the role
+func (b *Builder) SetRole(role Role) *Builder {
+	b.role = role
+	return b
+}
+
+// SetTenantIds sets the tenants the caller is limited to
+func (b *Builder) SetTenantIds(tenantIds []uuid.UUID) *Builder {
+	b.tenantIds = tenantIds
+	return b
+}
+
+// Build creates a new Principal
+func (b *Builder) Build() Principal {
+	return Principal{
+		subject:   b.subject,
+		method:    b.method,
+		role:      b.role,
+		tenantIds: b.tenantIds,
+	}
+}
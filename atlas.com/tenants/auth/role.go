@@ -0,0 +1,36 @@
+package auth
+
+import "fmt"
+
+// Role is a level of access to the admin API. Each role includes the access of the roles below it.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ParseRole converts a role name to a Role
+func ParseRole(value string) (Role, error) {
+	r := Role(value)
+	if _, ok := roleRanks[r]; !ok {
+		return "", fmt.Errorf("unknown role [%s]", value)
+	}
+	return r, nil
+}
+
+// Includes returns true if the role grants at least the access of the required role
+func (r Role) Includes(required Role) bool {
+	rank, ok := roleRanks[r]
+	if !ok {
+		return false
+	}
+	return rank >= roleRanks[required]
+}
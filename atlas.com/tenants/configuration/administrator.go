@@ -2,24 +2,75 @@ package configuration
 
 import (
 	"atlas-tenants/database"
+	"atlas-tenants/domain"
 	"encoding/json"
 	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"time"
 )
 
-// CreateConfiguration creates a new configuration in the database
-func CreateConfiguration(db *gorm.DB, e Entity) error {
-	return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
-		return tx.Create(&e).Error
+var (
+	// ErrResourceNotFound is returned when a configuration resource to delete does not exist
+	ErrResourceNotFound = domain.NotFound("RESOURCE_NOT_FOUND", "resource not found")
+	// ErrRevisionConflict is returned when a configuration was changed after it was read
+	ErrRevisionConflict = domain.Conflict("CONFIGURATION_REVISION_CONFLICT", "configuration was changed concurrently")
+)
+
+// CreateConfiguration creates a new configuration in the database, returning it at its first revision. It returns
+// ErrRevisionConflict when the tenant already holds a configuration of the resource, created after it was read.
+func CreateConfiguration(db *gorm.DB, e Entity) (Entity, error) {
+	e.Revision = 1
+	err := database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+		return createEntity(tx, &e)
 	})
+	if err != nil {
+		return Entity{}, err
+	}
+	return e, nil
 }
 
-// UpdateConfiguration updates an existing configuration in the database
-func UpdateConfiguration(db *gorm.DB, e Entity) error {
-	return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
-		return tx.Save(&e).Error
+// createEntity inserts a configuration. The tenant and resource name of live configurations are unique, so a
+// duplicate is a configuration created concurrently, reported as ErrRevisionConflict.
+func createEntity(tx *gorm.DB, e *Entity) error {
+	err := tx.Create(e).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrRevisionConflict.Wrap(err)
+	}
+	return err
+}
+
+// UpdateConfiguration stores the changes to a configuration as the revision after the one it was read at, returning
+// the stored configuration
+func UpdateConfiguration(db *gorm.DB, e Entity) (Entity, error) {
+	var saved Entity
+	err := database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+		var err error
+		saved, err = saveRevision(tx, e)
+		return err
 	})
+	if err != nil {
+		return Entity{}, err
+	}
+	return saved, nil
+}
+
+// saveRevision stores the resource data of a configuration as the revision after the one it was read at. It returns
+// ErrRevisionConflict when the stored revision is no longer the one read, so concurrent changes are not lost.
+func saveRevision(tx *gorm.DB, e Entity) (Entity, error) {
+	now := time.Now()
+	result := tx.Model(&Entity{}).
+		Where("id = ? AND revision = ?", e.ID, e.Revision).
+		Updates(map[string]interface{}{"resource_data": e.ResourceData, "revision": e.Revision + 1, "updated_at": now})
+	if result.Error != nil {
+		return Entity{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return Entity{}, ErrRevisionConflict
+	}
+	e.Revision++
+	e.UpdatedAt = now
+	return e, nil
 }
 
 // DeleteConfiguration deletes a configuration from the database
@@ -28,15 +79,37 @@ func DeleteConfiguration(db *gorm.DB, tenantID uuid.UUID, resourceName string, r
 	err := db.Where("tenant_id = ? AND resource_name = ?", tenantID, resourceName).First(&e).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("configuration not found")
+			return ErrResourceNotFound.Wrap(err)
 		}
 		return err
 	}
 
-	// Parse the resource data to find and remove the specific resource by ID
+	updated, remove, err := removeResource(e, resourceID)
+	if err != nil {
+		return err
+	}
+	return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+		if !remove {
+			_, err := saveRevision(tx, updated)
+			return err
+		}
+		result := tx.Where("revision = ?", e.Revision).Delete(&e)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRevisionConflict
+		}
+		return nil
+	})
+}
+
+// removeResource returns the configuration without the resource with the given ID. remove is true when the resource
+// was the only one held, so the whole configuration should be deleted.
+func removeResource(e Entity, resourceID string) (updated Entity, remove bool, err error) {
 	var resourceData map[string]interface{}
 	if err := json.Unmarshal(e.ResourceData, &resourceData); err != nil {
-		return err
+		return Entity{}, false, err
 	}
 
 	// For array of resources, filter out the one with matching ID
@@ -54,29 +127,81 @@ func DeleteConfiguration(db *gorm.DB, tenantID uuid.UUID, resourceName string, r
 		}
 
 		if !found {
-			return errors.New("resource not found")
+			return Entity{}, false, ErrResourceNotFound
 		}
 
 		resourceData["data"] = newResources
 		updatedData, err := json.Marshal(resourceData)
 		if err != nil {
-			return err
+			return Entity{}, false, err
 		}
 
-		e.ResourceData = updatedData
-		return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
-			return tx.Save(&e).Error
-		})
+		e.ResourceData = database.JSON(updatedData)
+		return e, false, nil
 	}
 
 	// If it's a single resource and the ID matches, delete the entire configuration
 	if data, ok := resourceData["data"].(map[string]interface{}); ok {
 		if id, ok := data["id"].(string); ok && id == resourceID {
-			return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
-				return tx.Delete(&e).Error
-			})
+			return e, true, nil
 		}
 	}
 
-	return errors.New("resource not found")
+	return Entity{}, false, ErrResourceNotFound
+}
+
+// Replacement is the resources a configuration is replaced with, and the revision the configuration was read at. A
+// revision of 0 means the configuration did not exist when read.
+type Replacement struct {
+	Revision  uint32
+	Resources []map[string]interface{}
+}
+
+// ReplaceConfigurations replaces the resources of the given resource names for a tenant within a single transaction.
+// ErrRevisionConflict is returned when any of the configurations was changed after it was read.
+func ReplaceConfigurations(db *gorm.DB, tenantID uuid.UUID, replacements map[string]Replacement) error {
+	return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+		for resourceName, r := range replacements {
+			resourceData, err := replacementData(r.Resources)
+			if err != nil {
+				return err
+			}
+
+			var e Entity
+			err = tx.Where("tenant_id = ? AND resource_name = ?", tenantID, resourceName).First(&e).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if r.Revision != 0 {
+					return ErrRevisionConflict
+				}
+				e = Entity{
+					ID:           uuid.New(),
+					TenantID:     tenantID,
+					ResourceName: resourceName,
+					ResourceData: database.JSON(resourceData),
+					Revision:     1,
+				}
+				if err = createEntity(tx, &e); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if e.Revision != r.Revision {
+				return ErrRevisionConflict
+			}
+
+			e.ResourceData = database.JSON(resourceData)
+			if _, err = saveRevision(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// replacementData returns the resource data holding exactly the given resources
+func replacementData(resources []map[string]interface{}) (json.RawMessage, error) {
+	return json.Marshal(map[string]interface{}{"data": resources})
 }
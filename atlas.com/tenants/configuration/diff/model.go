@@ -0,0 +1,163 @@
+package diff
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// AttributeDifference represents a single attribute which differs between two matched resources
+type AttributeDifference struct {
+	attribute string
+	left      interface{}
+	right     interface{}
+}
+
+// Attribute returns the attribute name
+func (d AttributeDifference) Attribute() string {
+	return d.attribute
+}
+
+// Left returns the attribute value in the left tenant, or nil if absent
+func (d AttributeDifference) Left() interface{} {
+	return d.left
+}
+
+// Right returns the attribute value in the right tenant, or nil if absent
+func (d AttributeDifference) Right() interface{} {
+	return d.right
+}
+
+// Change represents a resource present in both tenants whose attributes differ
+type Change struct {
+	leftId      string
+	rightId     string
+	name        string
+	differences []AttributeDifference
+}
+
+// LeftId returns the ID of the resource in the left tenant
+func (c Change) LeftId() string {
+	return c.leftId
+}
+
+// RightId returns the ID of the resource in the right tenant
+func (c Change) RightId() string {
+	return c.rightId
+}
+
+// Name returns the name of the resource
+func (c Change) Name() string {
+	return c.name
+}
+
+// Differences returns the attributes which differ between the two resources
+func (c Change) Differences() []AttributeDifference {
+	return c.differences
+}
+
+// Model represents the differences of a single resource type between two tenants
+type Model struct {
+	resourceName  string
+	leftTenantId  uuid.UUID
+	rightTenantId uuid.UUID
+	added         []map[string]interface{}
+	removed       []map[string]interface{}
+	changed       []Change
+}
+
+// ResourceName returns the configuration resource name
+func (m Model) ResourceName() string {
+	return m.resourceName
+}
+
+// LeftTenantId returns the ID of the tenant used as the base of the comparison
+func (m Model) LeftTenantId() uuid.UUID {
+	return m.leftTenantId
+}
+
+// RightTenantId returns the ID of the tenant compared against the base
+func (m Model) RightTenantId() uuid.UUID {
+	return m.rightTenantId
+}
+
+// Added returns the resources present only in the right tenant
+func (m Model) Added() []map[string]interface{} {
+	return m.added
+}
+
+// Removed returns the resources present only in the left tenant
+func (m Model) Removed() []map[string]interface{} {
+	return m.removed
+}
+
+// Changed returns the resources present in both tenants with differing attributes
+func (m Model) Changed() []Change {
+	return m.changed
+}
+
+// Empty returns true if the two tenants hold identical resources
+func (m Model) Empty() bool {
+	return len(m.added) == 0 && len(m.removed) == 0 && len(m.changed) == 0
+}
+
+// String returns a string representation of the diff
+func (m Model) String() string {
+	return fmt.Sprintf("ResourceName [%s] Left [%s] Right [%s] Added [%d] Removed [%d] Changed [%d]", m.ResourceName(), m.LeftTenantId().String(), m.RightTenantId().String(), len(m.Added()), len(m.Removed()), len(m.Changed()))
+}
+
+// Builder is used to build a Model
+type Builder struct {
+	resourceName  string
+	leftTenantId  uuid.UUID
+	rightTenantId uuid.UUID
+	added         []map[string]interface{}
+	removed       []map[string]interface{}
+	changed       []Change
+}
+
+// NewBuilder creates a new Builder
+func NewBuilder(resourceName string, leftTenantId uuid.UUID, rightTenantId uuid.UUID) *Builder {
+	return &Builder{
+		resourceName:  resourceName,
+		leftTenantId:  leftTenantId,
+		rightTenantId: rightTenantId,
+		added:         make([]map[string]interface{}, 0),
+		removed:       make([]map[string]interface{}, 0),
+		changed:       make([]Change, 0),
+	}
+}
+
+// AddAdded records a resource present only in the right tenant
+func (b *Builder) AddAdded(resource map[string]interface{}) *Builder {
+	b.added = append(b.added, resource)
+	return b
+}
+
+// AddRemoved records a resource present only in the left tenant
+func (b *Builder) AddRemoved(resource map[string]interface{}) *Builder {
+	b.removed = append(b.removed, resource)
+	return b
+}
+
+// AddChanged records a resource whose attributes differ between the tenants
+func (b *Builder) AddChanged(leftId string, rightId string, name string, differences []AttributeDifference) *Builder {
+	b.changed = append(b.changed, Change{
+		leftId:      leftId,
+		rightId:     rightId,
+		name:        name,
+		differences: differences,
+	})
+	return b
+}
+
+// Build creates a new Model
+func (b *Builder) Build() Model {
+	return Model{
+		resourceName:  b.resourceName,
+		leftTenantId:  b.leftTenantId,
+		rightTenantId: b.rightTenantId,
+		added:         b.added,
+		removed:       b.removed,
+		changed:       b.changed,
+	}
+}
@@ -0,0 +1,254 @@
+package diff
+
+import (
+	"atlas-tenants/configuration"
+	"atlas-tenants/domain"
+	"atlas-tenants/tenant"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"reflect"
+	"sort"
+)
+
+// ErrUnknownResource is returned when a diff requests a resource type the service does not hold
+var ErrUnknownResource = domain.Validation("UNKNOWN_RESOURCE", "unknown configuration resource").WithParameter("resource")
+
+// Processor defines the interface for configuration diff operations
+type Processor interface {
+	// Diff compares the configuration resources of two tenants. When no resource names are given, every resource
+	// type held by either tenant is compared. A tenant which does not exist is reported as tenant.ErrNotFound, and a
+	// resource type the service does not hold as ErrUnknownResource.
+	Diff(leftTenantId uuid.UUID, rightTenantId uuid.UUID, resourceNames ...string) ([]Model, error)
+
+	// DiffProvider returns a provider for the comparison of the configuration resources of two tenants
+	DiffProvider(leftTenantId uuid.UUID, rightTenantId uuid.UUID, resourceNames ...string) model.Provider[[]Model]
+}
+
+// ProcessorImpl implements the Processor interface
+type ProcessorImpl struct {
+	l   logrus.FieldLogger
+	ctx context.Context
+	db  *gorm.DB
+}
+
+// NewProcessor creates a new Processor
+func NewProcessor(l logrus.FieldLogger, ctx context.Context, db *gorm.DB) Processor {
+	return &ProcessorImpl{
+		l:   l,
+		ctx: ctx,
+		db:  db.WithContext(ctx),
+	}
+}
+
+// Diff compares the configuration resources of two tenants
+func (p *ProcessorImpl) Diff(leftTenantId uuid.UUID, rightTenantId uuid.UUID, resourceNames ...string) ([]Model, error) {
+	return p.DiffProvider(leftTenantId, rightTenantId, resourceNames...)()
+}
+
+// DiffProvider returns a provider for the comparison of the configuration resources of two tenants
+func (p *ProcessorImpl) DiffProvider(leftTenantId uuid.UUID, rightTenantId uuid.UUID, resourceNames ...string) model.Provider[[]Model] {
+	return func() ([]Model, error) {
+		for _, name := range resourceNames {
+			if !contains(configuration.ResourceNames, name) {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownResource, name)
+			}
+		}
+
+		tp := tenant.NewProcessor(p.l, p.ctx, p.db)
+		if _, err := tp.GetById(leftTenantId); err != nil {
+			return nil, withParameter(err, "left")
+		}
+		if _, err := tp.GetById(rightTenantId); err != nil {
+			return nil, withParameter(err, "right")
+		}
+
+		left, err := p.resourcesByName(leftTenantId)
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.resourcesByName(rightTenantId)
+		if err != nil {
+			return nil, err
+		}
+
+		names := resourceNames
+		if len(names) == 0 {
+			names = unionKeys(left, right)
+		}
+
+		results := make([]Model, 0, len(names))
+		for _, name := range names {
+			results = append(results, Compare(name, leftTenantId, rightTenantId, left[name], right[name]))
+		}
+		return results, nil
+	}
+}
+
+// withParameter names the query parameter of a tenant which does not exist
+func withParameter(err error, parameter string) error {
+	if errors.Is(err, tenant.ErrNotFound) {
+		return tenant.ErrNotFound.WithParameter(parameter)
+	}
+	return err
+}
+
+// resourcesByName loads every configuration of a tenant, keyed by resource name. Suppression markers are left out, as
+// they hide inherited resources rather than being resources of the tenant.
+func (p *ProcessorImpl) resourcesByName(tenantId uuid.UUID) (map[string][]map[string]interface{}, error) {
+	grouped, err := model.Map(configuration.GroupResources)(configuration.NewProcessor(p.l, p.ctx, p.db).ByTenantIdProvider(tenantId))()
+	if err != nil {
+		return nil, err
+	}
+	for name, resources := range grouped {
+		grouped[name] = configuration.Unsuppressed(resources)
+	}
+	return grouped, nil
+}
+
+// Compare computes the differences between two sets of resources of the same type, pairing them with Match.
+func Compare(resourceName string, leftTenantId uuid.UUID, rightTenantId uuid.UUID, left []map[string]interface{}, right []map[string]interface{}) Model {
+	b := NewBuilder(resourceName, leftTenantId, rightTenantId)
+
+	matched := Match(left, right)
+	rightUsed := make([]bool, len(right))
+	for _, j := range matched {
+		if j >= 0 {
+			rightUsed[j] = true
+		}
+	}
+
+	for i, l := range left {
+		if matched[i] < 0 {
+			b.AddRemoved(l)
+			continue
+		}
+		r := right[matched[i]]
+		differences := CompareAttributes(attributes(l), attributes(r))
+		if len(differences) > 0 {
+			b.AddChanged(idOf(l), idOf(r), nameOf(r), differences)
+		}
+	}
+	for j, r := range right {
+		if !rightUsed[j] {
+			b.AddAdded(r)
+		}
+	}
+	return b.Build()
+}
+
+// Match pairs resources of the same type. Resources are matched by ID first, and the remaining resources are then
+// matched by name. The result holds, for each left resource, the index of its matching right resource or -1.
+func Match(left []map[string]interface{}, right []map[string]interface{}) []int {
+	matched := make([]int, len(left))
+	rightUsed := make([]bool, len(right))
+	for i := range left {
+		matched[i] = -1
+	}
+
+	rightById := make(map[string]int)
+	for j, r := range right {
+		if id := idOf(r); id != "" {
+			rightById[id] = j
+		}
+	}
+	for i, l := range left {
+		if j, ok := rightById[idOf(l)]; ok && !rightUsed[j] {
+			matched[i] = j
+			rightUsed[j] = true
+		}
+	}
+
+	rightByName := make(map[string]int)
+	for j, r := range right {
+		if name := nameOf(r); name != "" && !rightUsed[j] {
+			if _, ok := rightByName[name]; !ok {
+				rightByName[name] = j
+			}
+		}
+	}
+	for i, l := range left {
+		if matched[i] >= 0 {
+			continue
+		}
+		if j, ok := rightByName[nameOf(l)]; ok && !rightUsed[j] {
+			matched[i] = j
+			rightUsed[j] = true
+		}
+	}
+	return matched
+}
+
+// CompareAttributes returns the attributes which differ between two attribute sets, ordered by attribute name
+func CompareAttributes(left map[string]interface{}, right map[string]interface{}) []AttributeDifference {
+	keys := make(map[string]bool)
+	for k := range left {
+		keys[k] = true
+	}
+	for k := range right {
+		keys[k] = true
+	}
+
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	results := make([]AttributeDifference, 0)
+	for _, k := range names {
+		lv, rv := left[k], right[k]
+		if reflect.DeepEqual(lv, rv) {
+			continue
+		}
+		results = append(results, AttributeDifference{attribute: k, left: lv, right: rv})
+	}
+	return results
+}
+
+func idOf(resource map[string]interface{}) string {
+	id, _ := resource["id"].(string)
+	return id
+}
+
+func nameOf(resource map[string]interface{}) string {
+	name, _ := attributes(resource)["name"].(string)
+	return name
+}
+
+func attributes(resource map[string]interface{}) map[string]interface{} {
+	a, ok := resource["attributes"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+	return a
+}
+
+func unionKeys(left map[string][]map[string]interface{}, right map[string][]map[string]interface{}) []string {
+	keys := make(map[string]bool)
+	for k := range left {
+		keys[k] = true
+	}
+	for k := range right {
+		keys[k] = true
+	}
+	results := make([]string, 0, len(keys))
+	for k := range keys {
+		results = append(results, k)
+	}
+	sort.Strings(results)
+	return results
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"atlas-tenants/openapi"
+	"atlas-tenants/rest"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-rest/server"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jtumidanski/api2go/jsonapi"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// GetDiffHandler handles GET /configurations/diff?left={tenantId}&right={tenantId}&resource={resourceName}
+func GetDiffHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+		return rest.ParseQueryTenantId(d.Logger(), "left", func(leftTenantId uuid.UUID) http.HandlerFunc {
+			return rest.ParseQueryTenantId(d.Logger(), "right", func(rightTenantId uuid.UUID) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					processor := NewProcessor(d.Logger(), d.Context(), db)
+
+					resourceNames := rest.ParseQueryList(r, "resource")
+					restModels, err := model.SliceMap(Transform)(processor.DiffProvider(leftTenantId, rightTenantId, resourceNames...))(model.ParallelMap())()
+					if err != nil {
+						d.Logger().WithError(err).Error("Failed to compute configuration diff")
+						rest.WriteError(d.Logger())(w)(err)
+						return
+					}
+
+					query := r.URL.Query()
+					queryParams := jsonapi.ParseQueryFields(&query)
+					server.MarshalResponse[[]RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(restModels)
+				}
+			})
+		})
+	}
+}
+
+// RegisterRoutes registers the configuration diff routes
+func RegisterRoutes(db *gorm.DB) func(si jsonapi.ServerInformation) server.RouteInitializer {
+	return func(si jsonapi.ServerInformation) server.RouteInitializer {
+		return func(r *mux.Router, l logrus.FieldLogger) {
+			registerHandler := rest.RegisterHandler(l)(si)
+
+			r.HandleFunc("/configurations/diff", registerHandler("get_configuration_diff", GetDiffHandler(db))).Methods(http.MethodGet)
+		}
+	}
+}
+
+// Operations describes the configuration diff routes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Name: "get_configuration_diff", Method: http.MethodGet, Path: "/configurations/diff", Summary: "Compare the configuration of two tenants", Tag: "configurations", Parameters: []openapi.Parameter{
+			openapi.QueryParameter("left", "Id of the left tenant", true),
+			openapi.QueryParameter("right", "Id of the right tenant", true),
+			openapi.QueryParameter("resource", "Comma separated resource names to compare", false),
+		}, Response: RestModel{}, List: true},
+	}
+}
@@ -0,0 +1,72 @@
+package diff
+
+// RestModel is the JSON:API resource for configuration diffs
+type RestModel struct {
+	Id      string                   `json:"-"`
+	Left    string                   `json:"left"`
+	Right   string                   `json:"right"`
+	Added   []map[string]interface{} `json:"added"`
+	Removed []map[string]interface{} `json:"removed"`
+	Changed []ChangeRestModel        `json:"changed"`
+}
+
+// ChangeRestModel describes a resource present in both tenants with differing attributes
+type ChangeRestModel struct {
+	LeftId      string                `json:"leftId"`
+	RightId     string                `json:"rightId"`
+	Name        string                `json:"name"`
+	Differences []DifferenceRestModel `json:"differences"`
+}
+
+// DifferenceRestModel describes a single attribute which differs
+type DifferenceRestModel struct {
+	Attribute string      `json:"attribute"`
+	Left      interface{} `json:"left"`
+	Right     interface{} `json:"right"`
+}
+
+// GetID returns the resource ID
+func (r RestModel) GetID() string {
+	return r.Id
+}
+
+// SetID sets the resource ID
+func (r *RestModel) SetID(id string) error {
+	r.Id = id
+	return nil
+}
+
+// GetName returns the resource name
+func (r RestModel) GetName() string {
+	return "configurationDiffs"
+}
+
+// Transform converts a Model to a RestModel. The resource ID is the name of the compared resource type.
+func Transform(m Model) (RestModel, error) {
+	changed := make([]ChangeRestModel, 0, len(m.Changed()))
+	for _, c := range m.Changed() {
+		differences := make([]DifferenceRestModel, 0, len(c.Differences()))
+		for _, d := range c.Differences() {
+			differences = append(differences, DifferenceRestModel{
+				Attribute: d.Attribute(),
+				Left:      d.Left(),
+				Right:     d.Right(),
+			})
+		}
+		changed = append(changed, ChangeRestModel{
+			LeftId:      c.LeftId(),
+			RightId:     c.RightId(),
+			Name:        c.Name(),
+			Differences: differences,
+		})
+	}
+
+	return RestModel{
+		Id:      m.ResourceName(),
+		Left:    m.LeftTenantId().String(),
+		Right:   m.RightTenantId().String(),
+		Added:   m.Added(),
+		Removed: m.Removed(),
+		Changed: changed,
+	}, nil
+}
@@ -1,7 +1,7 @@
 package configuration
 
 import (
-	"encoding/json"
+	"atlas-tenants/database"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -9,10 +9,11 @@ import (
 // Entity represents a configuration in the database
 type Entity struct {
 	gorm.Model
-	ID           uuid.UUID      `gorm:"type:uuid;primaryKey"`
-	TenantID     uuid.UUID      `gorm:"type:uuid;not null"`
-	ResourceName string         `gorm:"not null"`
-	ResourceData json.RawMessage `gorm:"type:jsonb;not null"`
+	ID           uuid.UUID     `gorm:"type:uuid;primaryKey"`
+	TenantID     uuid.UUID     `gorm:"type:uuid;not null"`
+	ResourceName string        `gorm:"not null"`
+	ResourceData database.JSON `gorm:"not null"`
+	Revision     uint32        `gorm:"not null;default:1"`
 }
 
 // TableName overrides the table name
@@ -20,7 +21,14 @@ func (Entity) TableName() string {
 	return "configurations"
 }
 
-// MigrateEntities creates the configuration table in the database
-func MigrateEntities(db *gorm.DB) error {
-	return db.AutoMigrate(&Entity{})
+// tenantEntity is the projection of the tenants table needed to resolve configuration inheritance
+type tenantEntity struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	ParentID  *uuid.UUID `gorm:"type:uuid"`
+	DeletedAt gorm.DeletedAt
+}
+
+// TableName overrides the table name
+func (tenantEntity) TableName() string {
+	return "tenants"
 }
@@ -0,0 +1,105 @@
+package configuration
+
+import (
+	"atlas-tenants/kafka/message"
+	"context"
+	"encoding/json"
+	"github.com/Chronicle20/atlas-kafka/producer"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	EventTopicConfigurationStatus = "configuration.status"
+	EventTypeCreated              = "CREATED"
+	EventTypeUpdated              = "UPDATED"
+	EventTypeDeleted              = "DELETED"
+	EventTypeCommandFailed        = "COMMAND_FAILED"
+
+	CommandTypeCreateResource = "CREATE_RESOURCE"
+	CommandTypeUpdateResource = "UPDATE_RESOURCE"
+	CommandTypeDeleteResource = "DELETE_RESOURCE"
+)
+
+// StatusEvent is a generic event for configuration resource changes. Events caused by a command carry its ID.
+type StatusEvent[T any] struct {
+	TenantId     uuid.UUID  `json:"tenantId"`
+	CommandId    *uuid.UUID `json:"commandId,omitempty"`
+	ResourceName string     `json:"resourceName"`
+	ResourceId   string     `json:"resourceId"`
+	Type         string     `json:"type"`
+	Body         T          `json:"body"`
+}
+
+// StatusEventBody is the body for a configuration resource event, carrying the resource attributes
+type StatusEventBody struct {
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// StatusEventCommandFailedBody is the body for the event reporting a command which could not be applied
+type StatusEventCommandFailedBody struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Command is a generic command requesting a change to a route or vessel of a tenant. Configuration commands share the
+// tenant command topic.
+type Command[T any] struct {
+	CommandId    uuid.UUID `json:"commandId"`
+	TenantId     uuid.UUID `json:"tenantId"`
+	ResourceName string    `json:"resourceName"`
+	ResourceId   string    `json:"resourceId"`
+	Type         string    `json:"type"`
+	Body         T         `json:"body"`
+}
+
+// CommandResourceBody is the body for a resource create or update command, carrying the resource attributes as they
+// are sent to the REST API
+type CommandResourceBody struct {
+	Attributes json.RawMessage `json:"attributes"`
+}
+
+// CommandDeleteBody is the body for a resource delete command
+type CommandDeleteBody struct {
+}
+
+// CreateStatusEventProvider creates a provider for configuration status events, correlated with the command handled in
+// ctx
+func CreateStatusEventProvider(ctx context.Context, tenantId uuid.UUID, resourceName string, eventType string, resource map[string]interface{}) model.Provider[[]kafka.Message] {
+	resourceId, _ := resource["id"].(string)
+	attributes, ok := resource["attributes"].(map[string]interface{})
+	if !ok {
+		attributes = make(map[string]interface{})
+	}
+
+	key := []byte(tenantId.String())
+	value := StatusEvent[StatusEventBody]{
+		TenantId:     tenantId,
+		CommandId:    message.CommandId(ctx),
+		ResourceName: resourceName,
+		ResourceId:   resourceId,
+		Type:         eventType,
+		Body: StatusEventBody{
+			Attributes: attributes,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
+// CommandFailedEventProvider creates a provider for the event reporting the command handled in ctx as failed
+func CommandFailedEventProvider(ctx context.Context, tenantId uuid.UUID, resourceName string, resourceId string, code string, detail string) model.Provider[[]kafka.Message] {
+	key := []byte(tenantId.String())
+	value := StatusEvent[StatusEventCommandFailedBody]{
+		TenantId:     tenantId,
+		CommandId:    message.CommandId(ctx),
+		ResourceName: resourceName,
+		ResourceId:   resourceId,
+		Type:         EventTypeCommandFailed,
+		Body: StatusEventCommandFailedBody{
+			Code:   code,
+			Detail: detail,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
@@ -0,0 +1,131 @@
+package configuration
+
+import (
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ParentIdFunc returns a provider for the ID of the tenant a tenant inherits configuration from
+type ParentIdFunc func(tenantID uuid.UUID) model.Provider[uuid.UUID]
+
+type configurationKey struct {
+	tenantID     uuid.UUID
+	resourceName string
+}
+
+// InMemoryRepository stores configurations in memory. It is safe for concurrent use, and is intended for tests.
+type InMemoryRepository struct {
+	mu       sync.RWMutex
+	entities map[configurationKey]Entity
+	parentId ParentIdFunc
+}
+
+// NewInMemoryRepository creates an empty in-memory repository. Tenants inherit configuration from the parents provided
+// by parentId, or from no tenant when parentId is nil.
+func NewInMemoryRepository(parentId ParentIdFunc) *InMemoryRepository {
+	if parentId == nil {
+		parentId = func(tenantID uuid.UUID) model.Provider[uuid.UUID] {
+			return model.FixedProvider(uuid.Nil)
+		}
+	}
+	return &InMemoryRepository{
+		entities: make(map[configurationKey]Entity),
+		parentId: parentId,
+	}
+}
+
+// ByTenantIdAndResourceNameProvider returns a provider for a configuration by tenant ID and resource name
+func (r *InMemoryRepository) ByTenantIdAndResourceNameProvider(tenantID uuid.UUID, resourceName string) model.Provider[Entity] {
+	return func() (Entity, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		e, ok := r.entities[configurationKey{tenantID: tenantID, resourceName: resourceName}]
+		if !ok {
+			return Entity{}, gorm.ErrRecordNotFound
+		}
+		return e, nil
+	}
+}
+
+// ByTenantIdProvider returns a provider for all configurations for a tenant, ordered by resource name
+func (r *InMemoryRepository) ByTenantIdProvider(tenantID uuid.UUID) model.Provider[[]Entity] {
+	return func() ([]Entity, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		results := make([]Entity, 0)
+		for k, e := range r.entities {
+			if k.tenantID == tenantID {
+				results = append(results, e)
+			}
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].ResourceName < results[j].ResourceName })
+		return results, nil
+	}
+}
+
+// ParentIdProvider returns a provider for the ID of the tenant a tenant inherits configuration from
+func (r *InMemoryRepository) ParentIdProvider(tenantID uuid.UUID) model.Provider[uuid.UUID] {
+	return r.parentId(tenantID)
+}
+
+// Create stores a new configuration
+func (r *InMemoryRepository) Create(e Entity) (Entity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := configurationKey{tenantID: e.TenantID, resourceName: e.ResourceName}
+	if _, ok := r.entities[k]; ok {
+		return Entity{}, ErrRevisionConflict.Wrap(gorm.ErrDuplicatedKey)
+	}
+	now := time.Now()
+	e.CreatedAt = now
+	e.UpdatedAt = now
+	e.Revision = 1
+	r.entities[k] = e
+	return e, nil
+}
+
+// Update stores the changes to an existing configuration
+func (r *InMemoryRepository) Update(e Entity) (Entity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.save(e)
+}
+
+// DeleteResource removes a single resource from a configuration
+func (r *InMemoryRepository) DeleteResource(tenantID uuid.UUID, resourceName string, resourceID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := configurationKey{tenantID: tenantID, resourceName: resourceName}
+	e, ok := r.entities[k]
+	if !ok {
+		return ErrResourceNotFound.Wrap(gorm.ErrRecordNotFound)
+	}
+
+	updated, remove, err := removeResource(e, resourceID)
+	if err != nil {
+		return err
+	}
+	if remove {
+		delete(r.entities, k)
+		return nil
+	}
+	_, err = r.save(updated)
+	return err
+}
+
+// save stores the configuration as the revision after the one it was read at, unless it was changed since. The caller
+// must hold the write lock.
+func (r *InMemoryRepository) save(e Entity) (Entity, error) {
+	k := configurationKey{tenantID: e.TenantID, resourceName: e.ResourceName}
+	if stored, ok := r.entities[k]; !ok || stored.Revision != e.Revision {
+		return Entity{}, ErrRevisionConflict
+	}
+	e.Revision++
+	e.UpdatedAt = time.Now()
+	r.entities[k] = e
+	return e, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
+	"time"
 )
 
 // Model represents a configuration in the domain
@@ -12,6 +13,8 @@ type Model struct {
 	tenantID     uuid.UUID
 	resourceName string
 	resourceData json.RawMessage
+	revision     uint32
+	lastModified time.Time
 }
 
 // ID returns the configuration ID
@@ -34,6 +37,58 @@ func (m Model) ResourceData() json.RawMessage {
 	return m.resourceData
 }
 
+// Revision returns the number of times the resource data has been written
+func (m Model) Revision() uint32 {
+	return m.revision
+}
+
+// LastModified returns the time the resource data was last written
+func (m Model) LastModified() time.Time {
+	return m.lastModified
+}
+
+// Resources returns the individual resources held in the resource data
+func (m Model) Resources() ([]map[string]interface{}, error) {
+	var resourceData map[string]interface{}
+	if err := json.Unmarshal(m.resourceData, &resourceData); err != nil {
+		return nil, err
+	}
+
+	// Check if it's an array of resources
+	if resources, ok := resourceData["data"].([]interface{}); ok {
+		result := make([]map[string]interface{}, 0, len(resources))
+		for _, resource := range resources {
+			if resourceMap, ok := resource.(map[string]interface{}); ok {
+				result = append(result, resourceMap)
+			}
+		}
+		return result, nil
+	}
+
+	// Check if it's a single resource
+	if data, ok := resourceData["data"].(map[string]interface{}); ok {
+		return []map[string]interface{}{data}, nil
+	}
+
+	return []map[string]interface{}{}, nil
+}
+
+// ResourceNames are the configuration resource types a tenant holds, with routes before the vessels referencing them
+var ResourceNames = []string{"routes", "vessels"}
+
+// GroupResources collects the individual resources of the configurations, keyed by resource name
+func GroupResources(ms []Model) (map[string][]map[string]interface{}, error) {
+	results := make(map[string][]map[string]interface{})
+	for _, m := range ms {
+		rs, err := m.Resources()
+		if err != nil {
+			return nil, err
+		}
+		results[m.ResourceName()] = append(results[m.ResourceName()], rs...)
+	}
+	return results, nil
+}
+
 // String returns a string representation of the configuration
 func (m Model) String() string {
 	return fmt.Sprintf("ID [%s] TenantID [%s] ResourceName [%s]", m.ID().String(), m.TenantID().String(), m.ResourceName())
@@ -45,6 +100,8 @@ type Builder struct {
 	tenantID     uuid.UUID
 	resourceName string
 	resourceData json.RawMessage
+	revision     uint32
+	lastModified time.Time
 }
 
 // NewBuilder creates a new Builder
@@ -54,6 +111,8 @@ func NewBuilder() *Builder {
 		tenantID:     uuid.Nil,
 		resourceName: "",
 		resourceData: nil,
+		revision:     0,
+		lastModified: time.Time{},
 	}
 }
 
@@ -81,6 +140,18 @@ func (b *Builder) SetResourceData(resourceData json.RawMessage) *Builder {
 	return b
 }
 
+// SetRevision sets the revision
+func (b *Builder) SetRevision(revision uint32) *Builder {
+	b.revision = revision
+	return b
+}
+
+// SetLastModified sets the last modified time
+func (b *Builder) SetLastModified(lastModified time.Time) *Builder {
+	b.lastModified = lastModified
+	return b
+}
+
 // Build creates a new Model
 func (b *Builder) Build() Model {
 	return Model{
@@ -88,6 +159,8 @@ func (b *Builder) Build() Model {
 		tenantID:     b.tenantID,
 		resourceName: b.resourceName,
 		resourceData: b.resourceData,
+		revision:     b.revision,
+		lastModified: b.lastModified,
 	}
 }
 
@@ -97,6 +170,8 @@ func Make(e Entity) (Model, error) {
 		SetID(e.ID).
 		SetTenantID(e.TenantID).
 		SetResourceName(e.ResourceName).
-		SetResourceData(e.ResourceData).
+		SetResourceData(json.RawMessage(e.ResourceData)).
+		SetRevision(e.Revision).
+		SetLastModified(e.UpdatedAt).
 		Build(), nil
 }
@@ -1,18 +1,25 @@
 package configuration
 
 import (
+	"atlas-tenants/database"
+	"atlas-tenants/domain"
 	"atlas-tenants/kafka/message"
+	"atlas-tenants/kafka/producer"
 	"context"
 	"encoding/json"
 	"errors"
 	"github.com/Chronicle20/atlas-model/model"
 	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 // Processor defines the interface for configuration operations
 type Processor interface {
+	// ByTenantIdProvider returns a provider for every configuration stored for a tenant
+	ByTenantIdProvider(tenantID uuid.UUID) model.Provider[[]Model]
+
 	// Route operations
 	// CreateRoute creates a new route configuration
 	CreateRoute(mb *message.Buffer) func(tenantID uuid.UUID) func(route map[string]interface{}) (Model, error)
@@ -34,6 +41,10 @@ type Processor interface {
 	RouteByIdProvider(tenantID uuid.UUID, routeID string) model.Provider[map[string]interface{}]
 	// AllRoutesProvider returns a provider for all routes for a tenant
 	AllRoutesProvider(tenantID uuid.UUID) model.Provider[[]map[string]interface{}]
+	// LocalRouteByIdProvider returns a provider for a route defined by the tenant itself, ignoring inheritance
+	LocalRouteByIdProvider(tenantID uuid.UUID, routeID string) model.Provider[map[string]interface{}]
+	// AllLocalRoutesProvider returns a provider for the routes defined by the tenant itself, ignoring inheritance
+	AllLocalRoutesProvider(tenantID uuid.UUID) model.Provider[[]map[string]interface{}]
 
 	// Vessel operations
 	// CreateVessel creates a new vessel configuration
@@ -56,30 +67,56 @@ type Processor interface {
 	VesselByIdProvider(tenantID uuid.UUID, vesselID string) model.Provider[map[string]interface{}]
 	// AllVesselsProvider returns a provider for all vessels for a tenant
 	AllVesselsProvider(tenantID uuid.UUID) model.Provider[[]map[string]interface{}]
+	// LocalVesselByIdProvider returns a provider for a vessel defined by the tenant itself, ignoring inheritance
+	LocalVesselByIdProvider(tenantID uuid.UUID, vesselID string) model.Provider[map[string]interface{}]
+	// AllLocalVesselsProvider returns a provider for the vessels defined by the tenant itself, ignoring inheritance
+	AllLocalVesselsProvider(tenantID uuid.UUID) model.Provider[[]map[string]interface{}]
 }
 
+var (
+	// ErrRouteNotFound is returned when a route does not exist for a tenant
+	ErrRouteNotFound = domain.NotFound("ROUTE_NOT_FOUND", "route not found")
+	// ErrVesselNotFound is returned when a vessel does not exist for a tenant
+	ErrVesselNotFound = domain.NotFound("VESSEL_NOT_FOUND", "vessel not found")
+	// ErrInheritanceCycle is returned when a tenant's parent chain leads back to itself
+	ErrInheritanceCycle = domain.Conflict("INHERITANCE_CYCLE", "configuration inheritance cycle detected")
+)
+
 // ProcessorImpl implements the Processor interface
 type ProcessorImpl struct {
 	l   logrus.FieldLogger
 	ctx context.Context
-	db  *gorm.DB
+	r   Repository
+	p   producer.Provider
 }
 
-// NewProcessor creates a new Processor
+// NewProcessor creates a new Processor backed by the database. Database access is bound to ctx, so it is cancelled
+// with the request. Events are produced after the database commit, so producing them is not.
 func NewProcessor(l logrus.FieldLogger, ctx context.Context, db *gorm.DB) Processor {
+	return NewRepositoryProcessor(l, ctx, NewGormRepository(db.WithContext(ctx)))
+}
+
+// NewRepositoryProcessor creates a new Processor storing configurations in the given repository
+func NewRepositoryProcessor(l logrus.FieldLogger, ctx context.Context, r Repository) Processor {
 	return &ProcessorImpl{
 		l:   l,
 		ctx: ctx,
-		db:  db,
+		r:   r,
+		p:   producer.ProviderImpl(l)(context.WithoutCancel(ctx)),
 	}
 }
 
+// ByTenantIdProvider returns a provider for every configuration stored for a tenant
+func (p *ProcessorImpl) ByTenantIdProvider(tenantID uuid.UUID) model.Provider[[]Model] {
+	return model.SliceMap(Make)(p.r.ByTenantIdProvider(tenantID))(model.ParallelMap())
+}
+
 // Create creates a new route configuration
 func (p *ProcessorImpl) CreateRoute(mb *message.Buffer) func(tenantID uuid.UUID) func(route map[string]interface{}) (Model, error) {
 	return func(tenantID uuid.UUID) func(route map[string]interface{}) (Model, error) {
 		return func(route map[string]interface{}) (Model, error) {
 			// Check if configuration already exists
-			existingProvider := GetByTenantIdAndResourceNameProvider(tenantID, "routes")(p.db)
+			existingProvider := p.r.ByTenantIdAndResourceNameProvider(tenantID, "routes")
 			existing, err := existingProvider()
 
 			var resourceData json.RawMessage
@@ -101,19 +138,27 @@ func (p *ProcessorImpl) CreateRoute(mb *message.Buffer) func(tenantID uuid.UUID)
 						return Model{}, err
 					}
 				} else {
-					// CreateRoute a new array with the existing resource and the new one
-					resourceData, err = CreateRouteJsonData([]map[string]interface{}{route})
+					// Create a new array with the existing resource and the new one
+					routes := []map[string]interface{}{route}
+					if data, ok := existingData["data"].(map[string]interface{}); ok {
+						routes = []map[string]interface{}{data, route}
+					}
+					resourceData, err = CreateRouteJsonData(routes)
 					if err != nil {
 						return Model{}, err
 					}
 				}
 
-				existing.ResourceData = resourceData
-				if err := UpdateConfiguration(p.db, existing); err != nil {
+				existing.ResourceData = database.JSON(resourceData)
+				saved, err := p.r.Update(existing)
+				if err != nil {
 					return Model{}, err
 				}
 
-				return Make(existing)
+				if err := mb.Put(EventTopicConfigurationStatus, CreateStatusEventProvider(p.ctx, tenantID, "routes", EventTypeCreated, route)); err != nil {
+					return Model{}, err
+				}
+				return Make(saved)
 			} else if errors.Is(err, gorm.ErrRecordNotFound) {
 				// Configuration doesn't exist, create it
 				resourceData, err = CreateSingleRouteJsonData(route)
@@ -125,14 +170,18 @@ func (p *ProcessorImpl) CreateRoute(mb *message.Buffer) func(tenantID uuid.UUID)
 					ID:           uuid.New(),
 					TenantID:     tenantID,
 					ResourceName: "routes",
-					ResourceData: resourceData,
+					ResourceData: database.JSON(resourceData),
 				}
 
-				if err := CreateConfiguration(p.db, entity); err != nil {
+				saved, err := p.r.Create(entity)
+				if err != nil {
 					return Model{}, err
 				}
 
-				return Make(entity)
+				if err := mb.Put(EventTopicConfigurationStatus, CreateStatusEventProvider(p.ctx, tenantID, "routes", EventTypeCreated, route)); err != nil {
+					return Model{}, err
+				}
+				return Make(saved)
 			} else {
 				// Other error
 				return Model{}, err
@@ -143,15 +192,9 @@ func (p *ProcessorImpl) CreateRoute(mb *message.Buffer) func(tenantID uuid.UUID)
 
 // CreateAndEmit creates a new route configuration and emits events
 func (p *ProcessorImpl) CreateRouteAndEmit(tenantID uuid.UUID, route map[string]interface{}) (Model, error) {
-	mb := message.NewBuffer()
-	result, err := p.CreateRoute(mb)(tenantID)(route)
-	if err != nil {
-		return Model{}, err
-	}
-
-	// No events to emit for now
-
-	return result, nil
+	return message.EmitWithResult[Model, map[string]interface{}](p.p)(func(mb *message.Buffer) func(map[string]interface{}) (Model, error) {
+		return p.CreateRoute(mb)(tenantID)
+	})(route)
 }
 
 // Update updates an existing route configuration
@@ -160,8 +203,16 @@ func (p *ProcessorImpl) UpdateRoute(mb *message.Buffer) func(tenantID uuid.UUID)
 		return func(routeID string) func(route map[string]interface{}) (Model, error) {
 			return func(route map[string]interface{}) (Model, error) {
 				// Check if configuration exists
-				existingProvider := GetByTenantIdAndResourceNameProvider(tenantID, "routes")(p.db)
+				existingProvider := p.r.ByTenantIdAndResourceNameProvider(tenantID, "routes")
 				existing, err := existingProvider()
+				if errors.Is(err, gorm.ErrRecordNotFound) && p.isInherited("routes", tenantID, routeID) {
+					// Overriding an inherited route creates the tenant's first local route
+					route["id"] = routeID
+					return p.createLocalResource(mb, "routes", EventTypeUpdated, tenantID, route)
+				}
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return Model{}, ErrRouteNotFound.Wrap(err)
+				}
 				if err != nil {
 					return Model{}, err
 				}
@@ -188,15 +239,20 @@ func (p *ProcessorImpl) UpdateRoute(mb *message.Buffer) func(tenantID uuid.UUID)
 					}
 
 					if !found {
-						return Model{}, errors.New("route not found")
+						if p.isInherited("routes", tenantID, routeID) {
+							return p.createLocalResource(mb, "routes", EventTypeUpdated, tenantID, route)
+						}
+						return Model{}, ErrRouteNotFound
 					}
 
 					existingData["data"] = resources
 				} else if data, ok := existingData["data"].(map[string]interface{}); ok {
 					if id, ok := data["id"].(string); ok && id == routeID {
 						existingData["data"] = route
+					} else if p.isInherited("routes", tenantID, routeID) {
+						return p.createLocalResource(mb, "routes", EventTypeUpdated, tenantID, route)
 					} else {
-						return Model{}, errors.New("route not found")
+						return Model{}, ErrRouteNotFound
 					}
 				} else {
 					return Model{}, errors.New("invalid resource data format")
@@ -207,12 +263,16 @@ func (p *ProcessorImpl) UpdateRoute(mb *message.Buffer) func(tenantID uuid.UUID)
 					return Model{}, err
 				}
 
-				existing.ResourceData = resourceData
-				if err := UpdateConfiguration(p.db, existing); err != nil {
+				existing.ResourceData = database.JSON(resourceData)
+				saved, err := p.r.Update(existing)
+				if err != nil {
 					return Model{}, err
 				}
 
-				return Make(existing)
+				if err := mb.Put(EventTopicConfigurationStatus, CreateStatusEventProvider(p.ctx, tenantID, "routes", EventTypeUpdated, route)); err != nil {
+					return Model{}, err
+				}
+				return Make(saved)
 			}
 		}
 	}
@@ -220,37 +280,45 @@ func (p *ProcessorImpl) UpdateRoute(mb *message.Buffer) func(tenantID uuid.UUID)
 
 // UpdateAndEmit updates an existing route configuration and emits events
 func (p *ProcessorImpl) UpdateRouteAndEmit(tenantID uuid.UUID, routeID string, route map[string]interface{}) (Model, error) {
-	mb := message.NewBuffer()
-	result, err := p.UpdateRoute(mb)(tenantID)(routeID)(route)
-	if err != nil {
-		return Model{}, err
-	}
-
-	// No events to emit for now
-
-	return result, nil
+	return message.EmitWithResult[Model, map[string]interface{}](p.p)(func(mb *message.Buffer) func(map[string]interface{}) (Model, error) {
+		return p.UpdateRoute(mb)(tenantID)(routeID)
+	})(route)
 }
 
 // Delete deletes a route configuration
 func (p *ProcessorImpl) DeleteRoute(mb *message.Buffer) func(tenantID uuid.UUID) func(routeID string) error {
 	return func(tenantID uuid.UUID) func(routeID string) error {
 		return func(routeID string) error {
-			return DeleteConfiguration(p.db, tenantID, "routes", routeID)
+			local, err := p.LocalRouteByIdProvider(tenantID, routeID)()
+			if err == nil && IsSuppressed(local) {
+				return ErrRouteNotFound
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) && p.isInherited("routes", tenantID, routeID) {
+				// Deleting an inherited route suppresses it for this tenant
+				_, err = p.createLocalResource(mb, "routes", EventTypeDeleted, tenantID, suppressionMarker("routes", routeID))
+				return err
+			}
+			if err != nil {
+				return err
+			}
+
+			err = p.r.DeleteResource(tenantID, "routes", routeID)
+			if errors.Is(err, ErrResourceNotFound) {
+				return ErrRouteNotFound.Wrap(err)
+			}
+			if err != nil {
+				return err
+			}
+			return mb.Put(EventTopicConfigurationStatus, p.localResourceDeletedEventProvider("routes", tenantID, routeID, local))
 		}
 	}
 }
 
 // DeleteAndEmit deletes a route configuration and emits events
 func (p *ProcessorImpl) DeleteRouteAndEmit(tenantID uuid.UUID, routeID string) error {
-	mb := message.NewBuffer()
-	err := p.DeleteRoute(mb)(tenantID)(routeID)
-	if err != nil {
-		return err
-	}
-
-	// No events to emit for now
-
-	return nil
+	return message.Emit(p.p)(func(mb *message.Buffer) error {
+		return p.DeleteRoute(mb)(tenantID)(routeID)
+	})
 }
 
 // GetRouteById gets a route by ID
@@ -263,14 +331,24 @@ func (p *ProcessorImpl) GetAllRoutes(tenantID uuid.UUID) ([]map[string]interface
 	return p.AllRoutesProvider(tenantID)()
 }
 
-// RouteByIdProvider returns a provider for a route by ID
+// RouteByIdProvider returns a provider for a route by ID, resolved through the tenant's parent chain
 func (p *ProcessorImpl) RouteByIdProvider(tenantID uuid.UUID, routeID string) model.Provider[map[string]interface{}] {
-	return GetRouteByIdProvider(tenantID, routeID)(p.db)
+	return notFoundAs(ErrRouteNotFound, model.Map(findResource(routeID))(p.AllRoutesProvider(tenantID)))
 }
 
-// AllRoutesProvider returns a provider for all routes for a tenant
+// AllRoutesProvider returns a provider for all routes for a tenant, resolved through the tenant's parent chain
 func (p *ProcessorImpl) AllRoutesProvider(tenantID uuid.UUID) model.Provider[[]map[string]interface{}] {
-	return GetAllRoutesProvider(tenantID)(p.db)
+	return p.resolvedResourcesProvider("routes", tenantID)
+}
+
+// LocalRouteByIdProvider returns a provider for a route defined by the tenant itself, ignoring inheritance
+func (p *ProcessorImpl) LocalRouteByIdProvider(tenantID uuid.UUID, routeID string) model.Provider[map[string]interface{}] {
+	return notFoundAs(ErrRouteNotFound, model.Map(resourceById(routeID))(p.r.ByTenantIdAndResourceNameProvider(tenantID, "routes")))
+}
+
+// AllLocalRoutesProvider returns a provider for the routes defined by the tenant itself, ignoring inheritance
+func (p *ProcessorImpl) AllLocalRoutesProvider(tenantID uuid.UUID) model.Provider[[]map[string]interface{}] {
+	return model.Map(allResources)(p.r.ByTenantIdAndResourceNameProvider(tenantID, "routes"))
 }
 
 // CreateVessel creates a new vessel configuration
@@ -278,7 +356,7 @@ func (p *ProcessorImpl) CreateVessel(mb *message.Buffer) func(tenantID uuid.UUID
 	return func(tenantID uuid.UUID) func(vessel map[string]interface{}) (Model, error) {
 		return func(vessel map[string]interface{}) (Model, error) {
 			// Check if configuration already exists
-			existingProvider := GetByTenantIdAndResourceNameProvider(tenantID, "vessels")(p.db)
+			existingProvider := p.r.ByTenantIdAndResourceNameProvider(tenantID, "vessels")
 			existing, err := existingProvider()
 
 			var resourceData json.RawMessage
@@ -300,19 +378,27 @@ func (p *ProcessorImpl) CreateVessel(mb *message.Buffer) func(tenantID uuid.UUID
 						return Model{}, err
 					}
 				} else {
-					// CreateRoute a new array with the existing resource and the new one
-					resourceData, err = CreateVesselJsonData([]map[string]interface{}{vessel})
+					// Create a new array with the existing resource and the new one
+					vessels := []map[string]interface{}{vessel}
+					if data, ok := existingData["data"].(map[string]interface{}); ok {
+						vessels = []map[string]interface{}{data, vessel}
+					}
+					resourceData, err = CreateVesselJsonData(vessels)
 					if err != nil {
 						return Model{}, err
 					}
 				}
 
-				existing.ResourceData = resourceData
-				if err := UpdateConfiguration(p.db, existing); err != nil {
+				existing.ResourceData = database.JSON(resourceData)
+				saved, err := p.r.Update(existing)
+				if err != nil {
 					return Model{}, err
 				}
 
-				return Make(existing)
+				if err := mb.Put(EventTopicConfigurationStatus, CreateStatusEventProvider(p.ctx, tenantID, "vessels", EventTypeCreated, vessel)); err != nil {
+					return Model{}, err
+				}
+				return Make(saved)
 			} else if errors.Is(err, gorm.ErrRecordNotFound) {
 				// Configuration doesn't exist, create it
 				resourceData, err = CreateSingleVesselJsonData(vessel)
@@ -324,14 +410,18 @@ func (p *ProcessorImpl) CreateVessel(mb *message.Buffer) func(tenantID uuid.UUID
 					ID:           uuid.New(),
 					TenantID:     tenantID,
 					ResourceName: "vessels",
-					ResourceData: resourceData,
+					ResourceData: database.JSON(resourceData),
 				}
 
-				if err := CreateConfiguration(p.db, entity); err != nil {
+				saved, err := p.r.Create(entity)
+				if err != nil {
 					return Model{}, err
 				}
 
-				return Make(entity)
+				if err := mb.Put(EventTopicConfigurationStatus, CreateStatusEventProvider(p.ctx, tenantID, "vessels", EventTypeCreated, vessel)); err != nil {
+					return Model{}, err
+				}
+				return Make(saved)
 			} else {
 				// Other error
 				return Model{}, err
@@ -342,15 +432,9 @@ func (p *ProcessorImpl) CreateVessel(mb *message.Buffer) func(tenantID uuid.UUID
 
 // CreateVesselAndEmit creates a new vessel configuration and emits events
 func (p *ProcessorImpl) CreateVesselAndEmit(tenantID uuid.UUID, vessel map[string]interface{}) (Model, error) {
-	mb := message.NewBuffer()
-	result, err := p.CreateVessel(mb)(tenantID)(vessel)
-	if err != nil {
-		return Model{}, err
-	}
-
-	// No events to emit for now
-
-	return result, nil
+	return message.EmitWithResult[Model, map[string]interface{}](p.p)(func(mb *message.Buffer) func(map[string]interface{}) (Model, error) {
+		return p.CreateVessel(mb)(tenantID)
+	})(vessel)
 }
 
 // UpdateVessel updates an existing vessel configuration
@@ -359,8 +443,16 @@ func (p *ProcessorImpl) UpdateVessel(mb *message.Buffer) func(tenantID uuid.UUID
 		return func(vesselID string) func(vessel map[string]interface{}) (Model, error) {
 			return func(vessel map[string]interface{}) (Model, error) {
 				// Check if configuration exists
-				existingProvider := GetByTenantIdAndResourceNameProvider(tenantID, "vessels")(p.db)
+				existingProvider := p.r.ByTenantIdAndResourceNameProvider(tenantID, "vessels")
 				existing, err := existingProvider()
+				if errors.Is(err, gorm.ErrRecordNotFound) && p.isInherited("vessels", tenantID, vesselID) {
+					// Overriding an inherited vessel creates the tenant's first local vessel
+					vessel["id"] = vesselID
+					return p.createLocalResource(mb, "vessels", EventTypeUpdated, tenantID, vessel)
+				}
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return Model{}, ErrVesselNotFound.Wrap(err)
+				}
 				if err != nil {
 					return Model{}, err
 				}
@@ -387,15 +479,20 @@ func (p *ProcessorImpl) UpdateVessel(mb *message.Buffer) func(tenantID uuid.UUID
 					}
 
 					if !found {
-						return Model{}, errors.New("vessel not found")
+						if p.isInherited("vessels", tenantID, vesselID) {
+							return p.createLocalResource(mb, "vessels", EventTypeUpdated, tenantID, vessel)
+						}
+						return Model{}, ErrVesselNotFound
 					}
 
 					existingData["data"] = resources
 				} else if data, ok := existingData["data"].(map[string]interface{}); ok {
 					if id, ok := data["id"].(string); ok && id == vesselID {
 						existingData["data"] = vessel
+					} else if p.isInherited("vessels", tenantID, vesselID) {
+						return p.createLocalResource(mb, "vessels", EventTypeUpdated, tenantID, vessel)
 					} else {
-						return Model{}, errors.New("vessel not found")
+						return Model{}, ErrVesselNotFound
 					}
 				} else {
 					return Model{}, errors.New("invalid resource data format")
@@ -406,12 +503,16 @@ func (p *ProcessorImpl) UpdateVessel(mb *message.Buffer) func(tenantID uuid.UUID
 					return Model{}, err
 				}
 
-				existing.ResourceData = resourceData
-				if err := UpdateConfiguration(p.db, existing); err != nil {
+				existing.ResourceData = database.JSON(resourceData)
+				saved, err := p.r.Update(existing)
+				if err != nil {
 					return Model{}, err
 				}
 
-				return Make(existing)
+				if err := mb.Put(EventTopicConfigurationStatus, CreateStatusEventProvider(p.ctx, tenantID, "vessels", EventTypeUpdated, vessel)); err != nil {
+					return Model{}, err
+				}
+				return Make(saved)
 			}
 		}
 	}
@@ -419,37 +520,45 @@ func (p *ProcessorImpl) UpdateVessel(mb *message.Buffer) func(tenantID uuid.UUID
 
 // UpdateVesselAndEmit updates an existing vessel configuration and emits events
 func (p *ProcessorImpl) UpdateVesselAndEmit(tenantID uuid.UUID, vesselID string, vessel map[string]interface{}) (Model, error) {
-	mb := message.NewBuffer()
-	result, err := p.UpdateVessel(mb)(tenantID)(vesselID)(vessel)
-	if err != nil {
-		return Model{}, err
-	}
-
-	// No events to emit for now
-
-	return result, nil
+	return message.EmitWithResult[Model, map[string]interface{}](p.p)(func(mb *message.Buffer) func(map[string]interface{}) (Model, error) {
+		return p.UpdateVessel(mb)(tenantID)(vesselID)
+	})(vessel)
 }
 
 // DeleteVessel deletes a vessel configuration
 func (p *ProcessorImpl) DeleteVessel(mb *message.Buffer) func(tenantID uuid.UUID) func(vesselID string) error {
 	return func(tenantID uuid.UUID) func(vesselID string) error {
 		return func(vesselID string) error {
-			return DeleteConfiguration(p.db, tenantID, "vessels", vesselID)
+			local, err := p.LocalVesselByIdProvider(tenantID, vesselID)()
+			if err == nil && IsSuppressed(local) {
+				return ErrVesselNotFound
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) && p.isInherited("vessels", tenantID, vesselID) {
+				// Deleting an inherited vessel suppresses it for this tenant
+				_, err = p.createLocalResource(mb, "vessels", EventTypeDeleted, tenantID, suppressionMarker("vessels", vesselID))
+				return err
+			}
+			if err != nil {
+				return err
+			}
+
+			err = p.r.DeleteResource(tenantID, "vessels", vesselID)
+			if errors.Is(err, ErrResourceNotFound) {
+				return ErrVesselNotFound.Wrap(err)
+			}
+			if err != nil {
+				return err
+			}
+			return mb.Put(EventTopicConfigurationStatus, p.localResourceDeletedEventProvider("vessels", tenantID, vesselID, local))
 		}
 	}
 }
 
 // DeleteVesselAndEmit deletes a vessel configuration and emits events
 func (p *ProcessorImpl) DeleteVesselAndEmit(tenantID uuid.UUID, vesselID string) error {
-	mb := message.NewBuffer()
-	err := p.DeleteVessel(mb)(tenantID)(vesselID)
-	if err != nil {
-		return err
-	}
-
-	// No events to emit for now
-
-	return nil
+	return message.Emit(p.p)(func(mb *message.Buffer) error {
+		return p.DeleteVessel(mb)(tenantID)(vesselID)
+	})
 }
 
 // GetVesselById gets a vessel by ID
@@ -462,12 +571,226 @@ func (p *ProcessorImpl) GetAllVessels(tenantID uuid.UUID) ([]map[string]interfac
 	return p.AllVesselsProvider(tenantID)()
 }
 
-// VesselByIdProvider returns a provider for a vessel by ID
+// VesselByIdProvider returns a provider for a vessel by ID, resolved through the tenant's parent chain
 func (p *ProcessorImpl) VesselByIdProvider(tenantID uuid.UUID, vesselID string) model.Provider[map[string]interface{}] {
-	return GetVesselByIdProvider(tenantID, vesselID)(p.db)
+	return notFoundAs(ErrVesselNotFound, model.Map(findResource(vesselID))(p.AllVesselsProvider(tenantID)))
 }
 
-// AllVesselsProvider returns a provider for all vessels for a tenant
+// AllVesselsProvider returns a provider for all vessels for a tenant, resolved through the tenant's parent chain
 func (p *ProcessorImpl) AllVesselsProvider(tenantID uuid.UUID) model.Provider[[]map[string]interface{}] {
-	return GetAllVesselsProvider(tenantID)(p.db)
+	return p.resolvedResourcesProvider("vessels", tenantID)
+}
+
+// LocalVesselByIdProvider returns a provider for a vessel defined by the tenant itself, ignoring inheritance
+func (p *ProcessorImpl) LocalVesselByIdProvider(tenantID uuid.UUID, vesselID string) model.Provider[map[string]interface{}] {
+	return notFoundAs(ErrVesselNotFound, model.Map(resourceById(vesselID))(p.r.ByTenantIdAndResourceNameProvider(tenantID, "vessels")))
+}
+
+// AllLocalVesselsProvider returns a provider for the vessels defined by the tenant itself, ignoring inheritance
+func (p *ProcessorImpl) AllLocalVesselsProvider(tenantID uuid.UUID) model.Provider[[]map[string]interface{}] {
+	return model.Map(allResources)(p.r.ByTenantIdAndResourceNameProvider(tenantID, "vessels"))
+}
+
+// localResourcesProvider returns a provider for the resources of the given type defined by the tenant itself
+func (p *ProcessorImpl) localResourcesProvider(resourceName string, tenantID uuid.UUID) model.Provider[[]map[string]interface{}] {
+	if resourceName == "vessels" {
+		return p.AllLocalVesselsProvider(tenantID)
+	}
+	return p.AllLocalRoutesProvider(tenantID)
+}
+
+// resolvedResourcesProvider returns a provider for the resources of the given type, where the resources inherited
+// from the tenant's parent chain are overlaid with the tenant's own overrides
+func (p *ProcessorImpl) resolvedResourcesProvider(resourceName string, tenantID uuid.UUID) model.Provider[[]map[string]interface{}] {
+	return func() ([]map[string]interface{}, error) {
+		return p.resolveResources(resourceName, tenantID, make(map[uuid.UUID]bool))
+	}
+}
+
+func (p *ProcessorImpl) resolveResources(resourceName string, tenantID uuid.UUID, visited map[uuid.UUID]bool) ([]map[string]interface{}, error) {
+	if visited[tenantID] {
+		return nil, ErrInheritanceCycle
+	}
+	visited[tenantID] = true
+
+	local, err := p.localResourcesProvider(resourceName, tenantID)()
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	localFound := err == nil
+
+	parentID, err := p.r.ParentIdProvider(tenantID)()
+	if err != nil {
+		return nil, err
+	}
+	if parentID == uuid.Nil {
+		if !localFound {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return OverlayResources(nil, local), nil
+	}
+
+	inherited, err := p.resolveResources(resourceName, parentID, visited)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) || !localFound {
+			return nil, err
+		}
+		inherited = nil
+	}
+	return OverlayResources(inherited, local), nil
+}
+
+// createLocalResource stores a local resource which overrides or suppresses an inherited resource, and records the
+// event describing its effect on the tenant's resolved resources
+func (p *ProcessorImpl) createLocalResource(mb *message.Buffer, resourceName string, eventType string, tenantID uuid.UUID, resource map[string]interface{}) (Model, error) {
+	create := p.CreateRoute
+	if resourceName == "vessels" {
+		create = p.CreateVessel
+	}
+
+	m, err := create(message.NewBuffer())(tenantID)(resource)
+	if err != nil {
+		return Model{}, err
+	}
+
+	err = mb.Put(EventTopicConfigurationStatus, CreateStatusEventProvider(p.ctx, tenantID, resourceName, eventType, resource))
+	if err != nil {
+		return Model{}, err
+	}
+	return m, nil
+}
+
+// localResourceDeletedEventProvider returns the event for a deleted local resource. Deleting the override of an
+// inherited resource makes the inherited resource visible again, so it is reported as UPDATED with the resolved resource.
+func (p *ProcessorImpl) localResourceDeletedEventProvider(resourceName string, tenantID uuid.UUID, resourceID string, local map[string]interface{}) model.Provider[[]kafka.Message] {
+	if !p.isInherited(resourceName, tenantID, resourceID) {
+		return CreateStatusEventProvider(p.ctx, tenantID, resourceName, EventTypeDeleted, local)
+	}
+	resolved, err := model.Map(findResource(resourceID))(p.resolvedResourcesProvider(resourceName, tenantID))()
+	if err != nil {
+		return model.ErrorProvider[[]kafka.Message](err)
+	}
+	return CreateStatusEventProvider(p.ctx, tenantID, resourceName, EventTypeUpdated, resolved)
+}
+
+// isInherited returns true if the resource is visible to the tenant through its parent chain
+func (p *ProcessorImpl) isInherited(resourceName string, tenantID uuid.UUID, resourceID string) bool {
+	parentID, err := p.r.ParentIdProvider(tenantID)()
+	if err != nil || parentID == uuid.Nil {
+		return false
+	}
+	_, err = model.Map(findResource(resourceID))(p.resolvedResourcesProvider(resourceName, parentID))()
+	return err == nil
+}
+
+// OverlayResources applies the overrides on top of the inherited resources. Overrides replace the attributes of the
+// inherited resource with the same ID, new resources are appended, and suppressed resources are removed.
+func OverlayResources(inherited []map[string]interface{}, overrides []map[string]interface{}) []map[string]interface{} {
+	merged := make([]map[string]interface{}, 0, len(inherited)+len(overrides))
+	index := make(map[string]int)
+	for _, r := range inherited {
+		if id, ok := r["id"].(string); ok {
+			index[id] = len(merged)
+		}
+		merged = append(merged, r)
+	}
+	for _, o := range overrides {
+		id, _ := o["id"].(string)
+		if i, ok := index[id]; ok {
+			merged[i] = mergeResource(merged[i], o)
+			continue
+		}
+		index[id] = len(merged)
+		merged = append(merged, o)
+	}
+
+	result := make([]map[string]interface{}, 0, len(merged))
+	for _, r := range merged {
+		if !IsSuppressed(r) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// mergeResource returns a copy of base where the attributes present in override take precedence
+func mergeResource(base map[string]interface{}, override map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		result[k] = v
+	}
+	attributes := make(map[string]interface{})
+	if ba, ok := base["attributes"].(map[string]interface{}); ok {
+		for k, v := range ba {
+			attributes[k] = v
+		}
+	}
+	for k, v := range override {
+		if k != "attributes" {
+			result[k] = v
+		}
+	}
+	if oa, ok := override["attributes"].(map[string]interface{}); ok {
+		for k, v := range oa {
+			attributes[k] = v
+		}
+	}
+	result["attributes"] = attributes
+	return result
+}
+
+// IsSuppressed returns true if the resource hides an inherited resource of the same ID
+func IsSuppressed(resource map[string]interface{}) bool {
+	attributes, ok := resource["attributes"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	suppressed, _ := attributes["suppressed"].(bool)
+	return suppressed
+}
+
+// Unsuppressed returns the resources which are not suppression markers
+func Unsuppressed(resources []map[string]interface{}) []map[string]interface{} {
+	results := make([]map[string]interface{}, 0, len(resources))
+	for _, r := range resources {
+		if !IsSuppressed(r) {
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// suppressionMarker creates a local resource which suppresses the inherited resource of the same ID
+func suppressionMarker(resourceName string, resourceID string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": resourceName,
+		"id":   resourceID,
+		"attributes": map[string]interface{}{
+			"suppressed": true,
+		},
+	}
+}
+
+// notFoundAs returns a provider which reports a missing resource as the given domain error. The original error is kept
+// as the cause, so callers may still test for gorm.ErrRecordNotFound.
+func notFoundAs(notFound *domain.Error, p model.Provider[map[string]interface{}]) model.Provider[map[string]interface{}] {
+	return func() (map[string]interface{}, error) {
+		r, err := p()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, notFound.Wrap(err)
+		}
+		return r, err
+	}
+}
+
+// findResource returns a transformer which selects the resource with the given ID
+func findResource(resourceID string) func([]map[string]interface{}) (map[string]interface{}, error) {
+	return func(resources []map[string]interface{}) (map[string]interface{}, error) {
+		for _, r := range resources {
+			if id, ok := r["id"].(string); ok && id == resourceID {
+				return r, nil
+			}
+		}
+		return nil, gorm.ErrRecordNotFound
+	}
 }
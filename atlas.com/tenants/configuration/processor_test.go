@@ -0,0 +1,479 @@
+package configuration
+
+import (
+	"atlas-tenants/kafka/kafkatest"
+	"atlas-tenants/kafka/message"
+	"context"
+	"errors"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"sort"
+	"testing"
+)
+
+// resourceOperations are the processor operations for one resource type, so each test covers routes and vessels
+type resourceOperations struct {
+	name     string
+	notFound error
+	create   func(p Processor) func(mb *message.Buffer) func(tenantID uuid.UUID) func(r map[string]interface{}) (Model, error)
+	update   func(p Processor) func(mb *message.Buffer) func(tenantID uuid.UUID) func(id string) func(r map[string]interface{}) (Model, error)
+	del      func(p Processor) func(mb *message.Buffer) func(tenantID uuid.UUID) func(id string) error
+	emitters func(p Processor) (create func(tenantID uuid.UUID, r map[string]interface{}) (Model, error), del func(tenantID uuid.UUID, id string) error)
+	all      func(p Processor) func(tenantID uuid.UUID) model.Provider[[]map[string]interface{}]
+	byId     func(p Processor) func(tenantID uuid.UUID, id string) model.Provider[map[string]interface{}]
+}
+
+var resourceTypes = []resourceOperations{
+	{
+		name:     "routes",
+		notFound: ErrRouteNotFound,
+		create: func(p Processor) func(*message.Buffer) func(uuid.UUID) func(map[string]interface{}) (Model, error) {
+			return p.CreateRoute
+		},
+		update: func(p Processor) func(*message.Buffer) func(uuid.UUID) func(string) func(map[string]interface{}) (Model, error) {
+			return p.UpdateRoute
+		},
+		del: func(p Processor) func(*message.Buffer) func(uuid.UUID) func(string) error { return p.DeleteRoute },
+		emitters: func(p Processor) (func(uuid.UUID, map[string]interface{}) (Model, error), func(uuid.UUID, string) error) {
+			return p.CreateRouteAndEmit, p.DeleteRouteAndEmit
+		},
+		all: func(p Processor) func(uuid.UUID) model.Provider[[]map[string]interface{}] { return p.AllRoutesProvider },
+		byId: func(p Processor) func(uuid.UUID, string) model.Provider[map[string]interface{}] {
+			return p.RouteByIdProvider
+		},
+	},
+	{
+		name:     "vessels",
+		notFound: ErrVesselNotFound,
+		create: func(p Processor) func(*message.Buffer) func(uuid.UUID) func(map[string]interface{}) (Model, error) {
+			return p.CreateVessel
+		},
+		update: func(p Processor) func(*message.Buffer) func(uuid.UUID) func(string) func(map[string]interface{}) (Model, error) {
+			return p.UpdateVessel
+		},
+		del: func(p Processor) func(*message.Buffer) func(uuid.UUID) func(string) error { return p.DeleteVessel },
+		emitters: func(p Processor) (func(uuid.UUID, map[string]interface{}) (Model, error), func(uuid.UUID, string) error) {
+			return p.CreateVesselAndEmit, p.DeleteVesselAndEmit
+		},
+		all: func(p Processor) func(uuid.UUID) model.Provider[[]map[string]interface{}] {
+			return p.AllVesselsProvider
+		},
+		byId: func(p Processor) func(uuid.UUID, string) model.Provider[map[string]interface{}] {
+			return p.VesselByIdProvider
+		},
+	},
+}
+
+// testTenants are a parent tenant and a child inheriting its configuration
+type testTenants struct {
+	parent uuid.UUID
+	child  uuid.UUID
+}
+
+func newTestProcessor(t *testing.T) (Processor, *InMemoryRepository, testTenants) {
+	t.Helper()
+	l, _ := test.NewNullLogger()
+	ts := testTenants{parent: uuid.New(), child: uuid.New()}
+	parents := map[uuid.UUID]uuid.UUID{ts.child: ts.parent}
+	r := NewInMemoryRepository(func(tenantID uuid.UUID) model.Provider[uuid.UUID] {
+		return model.FixedProvider(parents[tenantID])
+	})
+	return NewRepositoryProcessor(l, context.Background(), r), r, ts
+}
+
+func resource(resourceName string, id string, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       resourceName,
+		"id":         id,
+		"attributes": map[string]interface{}{"name": name},
+	}
+}
+
+func mustCreateResource(t *testing.T, p Processor, ops resourceOperations, tenantID uuid.UUID, id string, name string) {
+	t.Helper()
+	if _, err := ops.create(p)(message.NewBuffer())(tenantID)(resource(ops.name, id, name)); err != nil {
+		t.Fatalf("unable to create %s [%s]: %v", ops.name, id, err)
+	}
+}
+
+// names returns the name attribute of each resource, keyed by resource ID
+func names(rs []map[string]interface{}) map[string]string {
+	results := make(map[string]string)
+	for _, r := range rs {
+		id, _ := r["id"].(string)
+		attributes, _ := r["attributes"].(map[string]interface{})
+		results[id], _ = attributes["name"].(string)
+	}
+	return results
+}
+
+// events returns the type and resource ID of each configuration status event in the buffer
+func events(t *testing.T, mb *message.Buffer) []string {
+	t.Helper()
+	var results []string
+	for _, e := range kafkatest.Decode[StatusEvent[StatusEventBody]](t, mb.GetAll()[EventTopicConfigurationStatus]) {
+		results = append(results, e.Type+" "+e.ResourceId)
+	}
+	sort.Strings(results)
+	return results
+}
+
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCreateResource(t *testing.T) {
+	for _, ops := range resourceTypes {
+		t.Run(ops.name, func(t *testing.T) {
+			p, r, ts := newTestProcessor(t)
+
+			for i, id := range []string{"1", "2"} {
+				mb := message.NewBuffer()
+				m, err := ops.create(p)(mb)(ts.parent)(resource(ops.name, id, "resource "+id))
+				if err != nil {
+					t.Fatalf("create() error = %v", err)
+				}
+				if m.Revision() != uint32(i+1) {
+					t.Errorf("create() revision = %d, want %d", m.Revision(), i+1)
+				}
+				if got := events(t, mb); !equal(got, []string{EventTypeCreated + " " + id}) {
+					t.Errorf("create() events = %v", got)
+				}
+
+				e, err := r.ByTenantIdAndResourceNameProvider(ts.parent, ops.name)()
+				if err != nil {
+					t.Fatalf("configuration was not stored: %v", err)
+				}
+				if e.Revision != uint32(i+1) {
+					t.Errorf("revision = %d, want %d", e.Revision, i+1)
+				}
+			}
+
+			rs, err := ops.all(p)(ts.parent)()
+			if err != nil {
+				t.Fatalf("all() error = %v", err)
+			}
+			if got := names(rs); len(got) != 2 || got["1"] != "resource 1" || got["2"] != "resource 2" {
+				t.Errorf("all() = %v, want both resources", got)
+			}
+		})
+	}
+}
+
+func TestUpdateResource(t *testing.T) {
+	tests := []struct {
+		name      string
+		arrange   func(t *testing.T, p Processor, ops resourceOperations, ts testTenants)
+		tenant    func(ts testTenants) uuid.UUID
+		wantErr   bool
+		wantEvent string
+	}{
+		{
+			name: "local resource",
+			arrange: func(t *testing.T, p Processor, ops resourceOperations, ts testTenants) {
+				mustCreateResource(t, p, ops, ts.child, "1", "original")
+			},
+			tenant:    func(ts testTenants) uuid.UUID { return ts.child },
+			wantEvent: EventTypeUpdated + " 1",
+		},
+		{
+			name: "inherited resource",
+			arrange: func(t *testing.T, p Processor, ops resourceOperations, ts testTenants) {
+				mustCreateResource(t, p, ops, ts.parent, "1", "original")
+			},
+			tenant:    func(ts testTenants) uuid.UUID { return ts.child },
+			wantEvent: EventTypeUpdated + " 1",
+		},
+		{
+			name: "inherited resource with other local resources",
+			arrange: func(t *testing.T, p Processor, ops resourceOperations, ts testTenants) {
+				mustCreateResource(t, p, ops, ts.parent, "1", "original")
+				mustCreateResource(t, p, ops, ts.child, "2", "local")
+			},
+			tenant:    func(ts testTenants) uuid.UUID { return ts.child },
+			wantEvent: EventTypeUpdated + " 1",
+		},
+		{
+			name:    "unknown resource",
+			arrange: func(*testing.T, Processor, resourceOperations, testTenants) {},
+			tenant:  func(ts testTenants) uuid.UUID { return ts.child },
+			wantErr: true,
+		},
+		{
+			name: "resource of a child",
+			arrange: func(t *testing.T, p Processor, ops resourceOperations, ts testTenants) {
+				mustCreateResource(t, p, ops, ts.child, "1", "original")
+			},
+			tenant:  func(ts testTenants) uuid.UUID { return ts.parent },
+			wantErr: true,
+		},
+	}
+	for _, ops := range resourceTypes {
+		for _, tt := range tests {
+			t.Run(ops.name+"/"+tt.name, func(t *testing.T) {
+				p, _, ts := newTestProcessor(t)
+				tt.arrange(t, p, ops, ts)
+				tenantID := tt.tenant(ts)
+				mb := message.NewBuffer()
+
+				_, err := ops.update(p)(mb)(tenantID)("1")(resource(ops.name, "", "updated"))
+
+				if tt.wantErr {
+					if !errors.Is(err, ops.notFound) {
+						t.Fatalf("update() error = %v, want %v", err, ops.notFound)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("update() error = %v", err)
+				}
+				r, err := ops.byId(p)(tenantID, "1")()
+				if err != nil {
+					t.Fatalf("byId() error = %v", err)
+				}
+				if got := names([]map[string]interface{}{r})["1"]; got != "updated" {
+					t.Errorf("byId() name = %s, want updated", got)
+				}
+				if got := events(t, mb); !equal(got, []string{tt.wantEvent}) {
+					t.Errorf("update() events = %v, want [%s]", got, tt.wantEvent)
+				}
+			})
+		}
+	}
+}
+
+func TestUpdateStaleRevisionConflicts(t *testing.T) {
+	for _, ops := range resourceTypes {
+		t.Run(ops.name, func(t *testing.T) {
+			p, r, ts := newTestProcessor(t)
+			mustCreateResource(t, p, ops, ts.parent, "1", "original")
+			read, err := r.ByTenantIdAndResourceNameProvider(ts.parent, ops.name)()
+			if err != nil {
+				t.Fatalf("configuration was not stored: %v", err)
+			}
+
+			m, err := ops.update(p)(message.NewBuffer())(ts.parent)("1")(resource(ops.name, "", "updated"))
+			if err != nil {
+				t.Fatalf("update() error = %v", err)
+			}
+			if m.Revision() != read.Revision+1 {
+				t.Errorf("update() revision = %d, want %d", m.Revision(), read.Revision+1)
+			}
+
+			if _, err = r.Update(read); !errors.Is(err, ErrRevisionConflict) {
+				t.Errorf("Update() of a stale revision error = %v, want %v", err, ErrRevisionConflict)
+			}
+		})
+	}
+}
+
+func TestUpdateInheritedResourceLeavesParentUnchanged(t *testing.T) {
+	for _, ops := range resourceTypes {
+		t.Run(ops.name, func(t *testing.T) {
+			p, _, ts := newTestProcessor(t)
+			mustCreateResource(t, p, ops, ts.parent, "1", "original")
+
+			if _, err := ops.update(p)(message.NewBuffer())(ts.child)("1")(resource(ops.name, "", "override")); err != nil {
+				t.Fatalf("update() error = %v", err)
+			}
+
+			parent, err := ops.all(p)(ts.parent)()
+			if err != nil {
+				t.Fatalf("all() error = %v", err)
+			}
+			if got := names(parent); got["1"] != "original" {
+				t.Errorf("parent resources = %v, want the original resource", got)
+			}
+		})
+	}
+}
+
+func TestDeleteResource(t *testing.T) {
+	tests := []struct {
+		name      string
+		arrange   func(t *testing.T, p Processor, ops resourceOperations, ts testTenants)
+		wantErr   bool
+		wantEvent string
+		wantLeft  []string
+	}{
+		{
+			name: "only local resource",
+			arrange: func(t *testing.T, p Processor, ops resourceOperations, ts testTenants) {
+				mustCreateResource(t, p, ops, ts.child, "1", "local")
+			},
+			wantEvent: EventTypeDeleted + " 1",
+		},
+		{
+			name: "one of several local resources",
+			arrange: func(t *testing.T, p Processor, ops resourceOperations, ts testTenants) {
+				mustCreateResource(t, p, ops, ts.child, "1", "local")
+				mustCreateResource(t, p, ops, ts.child, "2", "local")
+			},
+			wantEvent: EventTypeDeleted + " 1",
+			wantLeft:  []string{"2"},
+		},
+		{
+			name: "inherited resource",
+			arrange: func(t *testing.T, p Processor, ops resourceOperations, ts testTenants) {
+				mustCreateResource(t, p, ops, ts.parent, "1", "inherited")
+				mustCreateResource(t, p, ops, ts.parent, "2", "inherited")
+			},
+			wantEvent: EventTypeDeleted + " 1",
+			wantLeft:  []string{"2"},
+		},
+		{
+			name:    "unknown resource",
+			arrange: func(*testing.T, Processor, resourceOperations, testTenants) {},
+			wantErr: true,
+		},
+	}
+	for _, ops := range resourceTypes {
+		for _, tt := range tests {
+			t.Run(ops.name+"/"+tt.name, func(t *testing.T) {
+				p, _, ts := newTestProcessor(t)
+				tt.arrange(t, p, ops, ts)
+				mb := message.NewBuffer()
+
+				err := ops.del(p)(mb)(ts.child)("1")
+
+				if tt.wantErr {
+					if !errors.Is(err, ops.notFound) {
+						t.Fatalf("delete() error = %v, want %v", err, ops.notFound)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("delete() error = %v", err)
+				}
+				if got := events(t, mb); !equal(got, []string{tt.wantEvent}) {
+					t.Errorf("delete() events = %v, want [%s]", got, tt.wantEvent)
+				}
+				if _, err = ops.byId(p)(ts.child, "1")(); !errors.Is(err, ops.notFound) {
+					t.Errorf("byId() after delete error = %v, want %v", err, ops.notFound)
+				}
+				if err = ops.del(p)(message.NewBuffer())(ts.child)("1"); !errors.Is(err, ops.notFound) {
+					t.Errorf("second delete() error = %v, want %v", err, ops.notFound)
+				}
+
+				rs, err := ops.all(p)(ts.child)()
+				if err != nil && len(tt.wantLeft) > 0 {
+					t.Fatalf("all() error = %v", err)
+				}
+				var left []string
+				for id := range names(rs) {
+					left = append(left, id)
+				}
+				if !equal(left, tt.wantLeft) {
+					t.Errorf("resources left = %v, want %v", left, tt.wantLeft)
+				}
+			})
+		}
+	}
+}
+
+func TestDeleteOverrideRestoresInheritedResource(t *testing.T) {
+	for _, ops := range resourceTypes {
+		t.Run(ops.name, func(t *testing.T) {
+			p, _, ts := newTestProcessor(t)
+			mustCreateResource(t, p, ops, ts.parent, "1", "inherited")
+			if _, err := ops.update(p)(message.NewBuffer())(ts.child)("1")(resource(ops.name, "", "override")); err != nil {
+				t.Fatalf("update() error = %v", err)
+			}
+			mb := message.NewBuffer()
+
+			if err := ops.del(p)(mb)(ts.child)("1"); err != nil {
+				t.Fatalf("delete() error = %v", err)
+			}
+
+			es := kafkatest.Decode[StatusEvent[StatusEventBody]](t, mb.GetAll()[EventTopicConfigurationStatus])
+			if len(es) != 1 || es[0].Type != EventTypeUpdated || es[0].ResourceId != "1" {
+				t.Fatalf("delete() events = %+v, want UPDATED 1", es)
+			}
+			if got := es[0].Body.Attributes["name"]; got != "inherited" {
+				t.Errorf("UPDATED event name = %v, want inherited", got)
+			}
+			r, err := ops.byId(p)(ts.child, "1")()
+			if err != nil {
+				t.Fatalf("byId() error = %v", err)
+			}
+			if got := names([]map[string]interface{}{r})["1"]; got != "inherited" {
+				t.Errorf("byId() name = %s, want inherited", got)
+			}
+		})
+	}
+}
+
+func TestResolvedResourcesOverlayParent(t *testing.T) {
+	for _, ops := range resourceTypes {
+		t.Run(ops.name, func(t *testing.T) {
+			p, _, ts := newTestProcessor(t)
+			mustCreateResource(t, p, ops, ts.parent, "1", "inherited")
+			mustCreateResource(t, p, ops, ts.parent, "2", "inherited")
+			mustCreateResource(t, p, ops, ts.child, "2", "override")
+			mustCreateResource(t, p, ops, ts.child, "3", "local")
+
+			rs, err := ops.all(p)(ts.child)()
+			if err != nil {
+				t.Fatalf("all() error = %v", err)
+			}
+			want := map[string]string{"1": "inherited", "2": "override", "3": "local"}
+			got := names(rs)
+			if len(got) != len(want) {
+				t.Fatalf("all() = %v, want %v", got, want)
+			}
+			for id, name := range want {
+				if got[id] != name {
+					t.Errorf("all() = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolvedResourcesDetectCycle(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	a, b := uuid.New(), uuid.New()
+	parents := map[uuid.UUID]uuid.UUID{a: b, b: a}
+	p := NewRepositoryProcessor(l, context.Background(), NewInMemoryRepository(func(tenantID uuid.UUID) model.Provider[uuid.UUID] {
+		return model.FixedProvider(parents[tenantID])
+	}))
+
+	if _, err := p.AllRoutesProvider(a)(); !errors.Is(err, ErrInheritanceCycle) {
+		t.Errorf("AllRoutesProvider() error = %v, want %v", err, ErrInheritanceCycle)
+	}
+}
+
+func TestEmitResource(t *testing.T) {
+	for _, ops := range resourceTypes {
+		t.Run(ops.name, func(t *testing.T) {
+			rec := kafkatest.Record(t)
+			p, _, ts := newTestProcessor(t)
+			create, del := ops.emitters(p)
+
+			if _, err := create(ts.parent, resource(ops.name, "1", "original")); err != nil {
+				t.Fatalf("create() error = %v", err)
+			}
+			if err := del(ts.parent, "1"); err != nil {
+				t.Fatalf("delete() error = %v", err)
+			}
+
+			es := kafkatest.Decode[StatusEvent[StatusEventBody]](t, rec.Messages(EventTopicConfigurationStatus))
+			if len(es) != 2 {
+				t.Fatalf("produced %d events, want 2", len(es))
+			}
+			deleted := kafkatest.Single(t, es, func(e StatusEvent[StatusEventBody]) bool { return e.Type == EventTypeDeleted })
+			if deleted.TenantId != ts.parent || deleted.ResourceName != ops.name || deleted.ResourceId != "1" {
+				t.Errorf("DELETED event = %+v, want %s [1] of tenant %s", deleted, ops.name, ts.parent)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package promotion
+
+import (
+	"atlas-tenants/configuration/diff"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// Model represents the promotion of a single configuration resource type from a source tenant to a target tenant
+type Model struct {
+	sourceTenantId uuid.UUID
+	targetTenantId uuid.UUID
+	dryRun         bool
+	changes        diff.Model
+}
+
+// SourceTenantId returns the ID of the tenant the configuration is promoted from
+func (m Model) SourceTenantId() uuid.UUID {
+	return m.sourceTenantId
+}
+
+// TargetTenantId returns the ID of the tenant the configuration is promoted to
+func (m Model) TargetTenantId() uuid.UUID {
+	return m.targetTenantId
+}
+
+// DryRun returns true if the changes were only reported and not applied
+func (m Model) DryRun() bool {
+	return m.dryRun
+}
+
+// Changes returns the changes to the target tenant, where the target is the left side of the diff
+func (m Model) Changes() diff.Model {
+	return m.changes
+}
+
+// String returns a string representation of the promotion
+func (m Model) String() string {
+	return fmt.Sprintf("Source [%s] Target [%s] DryRun [%t] Changes [%s]", m.SourceTenantId().String(), m.TargetTenantId().String(), m.DryRun(), m.Changes().String())
+}
@@ -0,0 +1,325 @@
+package promotion
+
+import (
+	"atlas-tenants/configuration"
+	"atlas-tenants/configuration/diff"
+	"atlas-tenants/domain"
+	"atlas-tenants/kafka/message"
+	"atlas-tenants/kafka/producer"
+	"atlas-tenants/tenant"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSameTenant is returned when the source and target of a promotion are the same tenant
+	ErrSameTenant = domain.Validation("SAME_TENANT", "source and target tenant are the same").WithParameter("from")
+	// ErrUnknownResource is returned when a promotion requests a resource type which cannot be promoted
+	ErrUnknownResource = domain.Validation("UNKNOWN_RESOURCE", "unknown configuration resource").WithParameter("resources")
+	// ErrUnresolvedRouteReference is returned when a vessel references a route with no counterpart in the target tenant
+	ErrUnresolvedRouteReference = domain.Conflict("UNRESOLVED_ROUTE_REFERENCE", "vessel references a route unavailable in the target tenant")
+	// ErrInvalidDryRun is returned when the dryRun flag of a promotion is not a boolean
+	ErrInvalidDryRun = domain.Validation("INVALID_DRY_RUN", "dryRun is not a boolean").WithParameter("dryRun")
+)
+
+// ResourceNames are the configuration resource types which can be promoted, in the order they are applied
+var ResourceNames = configuration.ResourceNames
+
+// Processor defines the interface for configuration promotion operations
+type Processor interface {
+	// Promote replaces the given configuration resources of the target tenant with those of the source tenant.
+	// When dryRun is set, the changes are only reported.
+	Promote(mb *message.Buffer) func(targetTenantId uuid.UUID, sourceTenantId uuid.UUID, resourceNames []string, dryRun bool) ([]Model, error)
+
+	// PromoteAndEmit promotes configuration resources and emits the resulting change events
+	PromoteAndEmit(targetTenantId uuid.UUID, sourceTenantId uuid.UUID, resourceNames []string, dryRun bool) ([]Model, error)
+}
+
+// ProcessorImpl implements the Processor interface
+type ProcessorImpl struct {
+	l   logrus.FieldLogger
+	ctx context.Context
+	db  *gorm.DB
+	p   producer.Provider
+}
+
+// NewProcessor creates a new Processor. Events are produced after the database commit, so producing them is not
+// cancelled with ctx.
+func NewProcessor(l logrus.FieldLogger, ctx context.Context, db *gorm.DB) Processor {
+	return &ProcessorImpl{
+		l:   l,
+		ctx: ctx,
+		db:  db.WithContext(ctx),
+		p:   producer.ProviderImpl(l)(context.WithoutCancel(ctx)),
+	}
+}
+
+// Promote replaces the given configuration resources of the target tenant with those of the source tenant
+func (p *ProcessorImpl) Promote(mb *message.Buffer) func(targetTenantId uuid.UUID, sourceTenantId uuid.UUID, resourceNames []string, dryRun bool) ([]Model, error) {
+	return func(targetTenantId uuid.UUID, sourceTenantId uuid.UUID, resourceNames []string, dryRun bool) ([]Model, error) {
+		if targetTenantId == sourceTenantId {
+			return nil, ErrSameTenant
+		}
+		names, err := orderResourceNames(resourceNames)
+		if err != nil {
+			return nil, err
+		}
+
+		tp := tenant.NewProcessor(p.l, p.ctx, p.db)
+		if _, err = tp.GetById(targetTenantId); err != nil {
+			return nil, err
+		}
+		if _, err = tp.GetById(sourceTenantId); err != nil {
+			if errors.Is(err, tenant.ErrNotFound) {
+				return nil, tenant.ErrNotFound.WithParameter("from")
+			}
+			return nil, err
+		}
+
+		cp := configuration.NewProcessor(p.l, p.ctx, p.db)
+		source, err := resolvedResources(cp, sourceTenantId)
+		if err != nil {
+			return nil, err
+		}
+		targetModels, err := cp.ByTenantIdProvider(targetTenantId)()
+		if err != nil {
+			return nil, err
+		}
+		target, err := configuration.GroupResources(targetModels)
+		if err != nil {
+			return nil, err
+		}
+		revisions := make(map[string]uint32)
+		for _, m := range targetModels {
+			revisions[m.ResourceName()] = m.Revision()
+		}
+
+		routeIds := routeIdMapping(configuration.Unsuppressed(target["routes"]), source["routes"], contains(names, "routes"))
+
+		planned := make(map[string][]map[string]interface{})
+		replacements := make(map[string]configuration.Replacement)
+		results := make([]Model, 0, len(names))
+		for _, name := range names {
+			visible := configuration.Unsuppressed(target[name])
+			rs, err := plan(name, visible, source[name], routeIds)
+			if err != nil {
+				return nil, err
+			}
+			planned[name] = rs
+			replacements[name] = configuration.Replacement{Revision: revisions[name], Resources: keepSuppressions(rs, target[name])}
+			results = append(results, Model{
+				sourceTenantId: sourceTenantId,
+				targetTenantId: targetTenantId,
+				dryRun:         dryRun,
+				changes:        diff.Compare(name, targetTenantId, sourceTenantId, visible, rs),
+			})
+		}
+
+		if dryRun {
+			return results, nil
+		}
+
+		err = configuration.ReplaceConfigurations(p.db, targetTenantId, replacements)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range results {
+			err = putChangeEvents(p.ctx, mb, targetTenantId, r.Changes(), planned[r.Changes().ResourceName()])
+			if err != nil {
+				return nil, err
+			}
+			p.l.WithFields(logrus.Fields{
+				"sourceTenantId": sourceTenantId.String(),
+				"targetTenantId": targetTenantId.String(),
+				"resource":       r.Changes().ResourceName(),
+				"added":          len(r.Changes().Added()),
+				"removed":        len(r.Changes().Removed()),
+				"changed":        len(r.Changes().Changed()),
+			}).Info("Configuration promoted")
+		}
+		return results, nil
+	}
+}
+
+// PromoteAndEmit promotes configuration resources and emits the resulting change events
+func (p *ProcessorImpl) PromoteAndEmit(targetTenantId uuid.UUID, sourceTenantId uuid.UUID, resourceNames []string, dryRun bool) ([]Model, error) {
+	return message.EmitWithResult[[]Model, uuid.UUID](p.p)(func(mb *message.Buffer) func(uuid.UUID) ([]Model, error) {
+		return func(targetTenantId uuid.UUID) ([]Model, error) {
+			return p.Promote(mb)(targetTenantId, sourceTenantId, resourceNames, dryRun)
+		}
+	})(targetTenantId)
+}
+
+// orderResourceNames validates the requested resource names and orders them so routes are planned before vessels
+func orderResourceNames(resourceNames []string) ([]string, error) {
+	if len(resourceNames) == 0 {
+		return ResourceNames, nil
+	}
+	for _, name := range resourceNames {
+		if !contains(ResourceNames, name) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownResource, name)
+		}
+	}
+
+	results := make([]string, 0, len(resourceNames))
+	for _, name := range ResourceNames {
+		if contains(resourceNames, name) {
+			results = append(results, name)
+		}
+	}
+	return results, nil
+}
+
+// resolvedResources loads the resources a tenant resolves through its parent chain, keyed by resource name. Suppression
+// markers are left out, so a promotion copies only the resources the source tenant actually holds.
+func resolvedResources(cp configuration.Processor, tenantId uuid.UUID) (map[string][]map[string]interface{}, error) {
+	providers := map[string]func(uuid.UUID) model.Provider[[]map[string]interface{}]{
+		"routes":  cp.AllRoutesProvider,
+		"vessels": cp.AllVesselsProvider,
+	}
+	results := make(map[string][]map[string]interface{})
+	for name, provider := range providers {
+		rs, err := provider(tenantId)()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[name] = configuration.Unsuppressed(rs)
+	}
+	return results, nil
+}
+
+// keepSuppressions adds the suppression markers of the target tenant to the planned resources, unless the plan holds a
+// resource of the same ID, so the inherited resources the target suppresses stay hidden
+func keepSuppressions(planned []map[string]interface{}, target []map[string]interface{}) []map[string]interface{} {
+	ids := make(map[string]bool, len(planned))
+	for _, r := range planned {
+		id, _ := r["id"].(string)
+		ids[id] = true
+	}
+	results := append(make([]map[string]interface{}, 0, len(planned)), planned...)
+	for _, r := range target {
+		id, _ := r["id"].(string)
+		if configuration.IsSuppressed(r) && !ids[id] {
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// routeIdMapping maps the IDs of the source routes to the IDs they will have in the target tenant. Matched routes
+// keep the target ID. Unmatched routes keep their source ID when routes are promoted alongside, and are otherwise
+// absent from the mapping.
+func routeIdMapping(target []map[string]interface{}, source []map[string]interface{}, promoted bool) map[string]string {
+	results := make(map[string]string)
+	matched := diff.Match(source, target)
+	for i, s := range source {
+		sourceId, _ := s["id"].(string)
+		if matched[i] >= 0 {
+			results[sourceId], _ = target[matched[i]]["id"].(string)
+		} else if promoted {
+			results[sourceId] = sourceId
+		}
+	}
+	return results
+}
+
+// plan computes the resources the target tenant will hold once the source resources are promoted
+func plan(resourceName string, target []map[string]interface{}, source []map[string]interface{}, routeIds map[string]string) ([]map[string]interface{}, error) {
+	matched := diff.Match(source, target)
+	results := make([]map[string]interface{}, 0, len(source))
+	for i, s := range source {
+		r := copyResource(s)
+		if matched[i] >= 0 {
+			r["id"] = target[matched[i]]["id"]
+		}
+		if resourceName == "vessels" {
+			err := remapRouteReferences(r, routeIds)
+			if err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// remapRouteReferences rewrites the route references of a vessel to the IDs of the routes in the target tenant
+func remapRouteReferences(vessel map[string]interface{}, routeIds map[string]string) error {
+	attributes, ok := vessel["attributes"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for _, key := range []string{"routeAID", "routeBID"} {
+		routeId, _ := attributes[key].(string)
+		if routeId == "" {
+			continue
+		}
+		targetId, ok := routeIds[routeId]
+		if !ok {
+			return fmt.Errorf("%w: vessel [%v] %s [%s]", ErrUnresolvedRouteReference, vessel["id"], key, routeId)
+		}
+		attributes[key] = targetId
+	}
+	return nil
+}
+
+// putChangeEvents records a configuration status event for every resource changed by a promotion
+func putChangeEvents(ctx context.Context, mb *message.Buffer, tenantId uuid.UUID, changes diff.Model, after []map[string]interface{}) error {
+	afterById := make(map[string]map[string]interface{})
+	for _, r := range after {
+		id, _ := r["id"].(string)
+		afterById[id] = r
+	}
+
+	name := changes.ResourceName()
+	for _, r := range changes.Added() {
+		if err := mb.Put(configuration.EventTopicConfigurationStatus, configuration.CreateStatusEventProvider(ctx, tenantId, name, configuration.EventTypeCreated, r)); err != nil {
+			return err
+		}
+	}
+	for _, c := range changes.Changed() {
+		if err := mb.Put(configuration.EventTopicConfigurationStatus, configuration.CreateStatusEventProvider(ctx, tenantId, name, configuration.EventTypeUpdated, afterById[c.RightId()])); err != nil {
+			return err
+		}
+	}
+	for _, r := range changes.Removed() {
+		if err := mb.Put(configuration.EventTopicConfigurationStatus, configuration.CreateStatusEventProvider(ctx, tenantId, name, configuration.EventTypeDeleted, r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyResource copies a resource deeply enough that its attributes can be modified independently
+func copyResource(resource map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(resource))
+	for k, v := range resource {
+		result[k] = v
+	}
+	if attributes, ok := resource["attributes"].(map[string]interface{}); ok {
+		ac := make(map[string]interface{}, len(attributes))
+		for k, v := range attributes {
+			ac[k] = v
+		}
+		result["attributes"] = ac
+	}
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
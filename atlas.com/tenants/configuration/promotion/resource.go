@@ -0,0 +1,78 @@
+package promotion
+
+import (
+	"atlas-tenants/openapi"
+	"atlas-tenants/rest"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-rest/server"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jtumidanski/api2go/jsonapi"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+)
+
+// PromoteHandler handles POST /tenants/{tenantId}/configurations/promote?from={tenantId}&resources={resourceNames}&dryRun={bool}
+func PromoteHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+		return rest.ParseTenantId(d.Logger(), func(targetTenantId uuid.UUID) http.HandlerFunc {
+			return rest.ParseQueryTenantId(d.Logger(), "from", func(sourceTenantId uuid.UUID) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					dryRun := false
+					if value := r.URL.Query().Get("dryRun"); value != "" {
+						var err error
+						dryRun, err = strconv.ParseBool(value)
+						if err != nil {
+							d.Logger().WithError(err).Error("Unable to properly parse dryRun from query.")
+							rest.WriteError(d.Logger())(w)(ErrInvalidDryRun.Wrap(err))
+							return
+						}
+					}
+
+					processor := NewProcessor(d.Logger(), d.Context(), db)
+					ms, err := processor.PromoteAndEmit(targetTenantId, sourceTenantId, rest.ParseQueryList(r, "resources"), dryRun)
+					if err != nil {
+						d.Logger().WithError(err).Error("Failed to promote configuration")
+						rest.WriteError(d.Logger())(w)(err)
+						return
+					}
+
+					restModels, err := model.SliceMap(Transform)(model.FixedProvider(ms))(model.ParallelMap())()
+					if err != nil {
+						d.Logger().WithError(err).Error("Failed to transform configuration promotion")
+						rest.WriteError(d.Logger())(w)(err)
+						return
+					}
+
+					query := r.URL.Query()
+					queryParams := jsonapi.ParseQueryFields(&query)
+					server.MarshalResponse[[]RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(restModels)
+				}
+			})
+		})
+	}
+}
+
+// RegisterRoutes registers the configuration promotion routes
+func RegisterRoutes(db *gorm.DB) func(si jsonapi.ServerInformation) server.RouteInitializer {
+	return func(si jsonapi.ServerInformation) server.RouteInitializer {
+		return func(r *mux.Router, l logrus.FieldLogger) {
+			registerHandler := rest.RegisterHandler(l)(si)
+
+			r.HandleFunc("/tenants/{tenantId}/configurations/promote", registerHandler("promote_configuration", PromoteHandler(db))).Methods(http.MethodPost)
+		}
+	}
+}
+
+// Operations describes the configuration promotion routes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Name: "promote_configuration", Method: http.MethodPost, Path: "/tenants/{tenantId}/configurations/promote", Summary: "Promote configuration from another tenant", Tag: "configurations", Parameters: []openapi.Parameter{
+			openapi.QueryParameter("from", "Id of the source tenant", true),
+			openapi.QueryParameter("resources", "Comma separated resource names to promote", false),
+			openapi.QueryParameter("dryRun", "Set to true to report changes without applying them", false),
+		}, Response: RestModel{}, List: true},
+	}
+}
@@ -0,0 +1,48 @@
+package promotion
+
+import "atlas-tenants/configuration/diff"
+
+// RestModel is the JSON:API resource for configuration promotions
+type RestModel struct {
+	Id      string                   `json:"-"`
+	Source  string                   `json:"source"`
+	Target  string                   `json:"target"`
+	DryRun  bool                     `json:"dryRun"`
+	Added   []map[string]interface{} `json:"added"`
+	Removed []map[string]interface{} `json:"removed"`
+	Changed []diff.ChangeRestModel   `json:"changed"`
+}
+
+// GetID returns the resource ID
+func (r RestModel) GetID() string {
+	return r.Id
+}
+
+// SetID sets the resource ID
+func (r *RestModel) SetID(id string) error {
+	r.Id = id
+	return nil
+}
+
+// GetName returns the resource name
+func (r RestModel) GetName() string {
+	return "configurationPromotions"
+}
+
+// Transform converts a Model to a RestModel. The resource ID is the name of the promoted resource type.
+func Transform(m Model) (RestModel, error) {
+	drm, err := diff.Transform(m.Changes())
+	if err != nil {
+		return RestModel{}, err
+	}
+
+	return RestModel{
+		Id:      drm.Id,
+		Source:  m.SourceTenantId().String(),
+		Target:  m.TargetTenantId().String(),
+		DryRun:  m.DryRun(),
+		Added:   drm.Added,
+		Removed: drm.Removed,
+		Changed: drm.Changed,
+	}, nil
+}
@@ -3,6 +3,7 @@ package configuration
 import (
 	"atlas-tenants/database"
 	"encoding/json"
+	"errors"
 	"github.com/Chronicle20/atlas-model/model"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -27,132 +28,80 @@ func GetByTenantIdProvider(tenantID uuid.UUID) database.EntityProvider[[]Entity]
 	}
 }
 
-// GetRouteByIdProvider returns a provider for a specific route by ID
-func GetRouteByIdProvider(tenantID uuid.UUID, routeID string) func(db *gorm.DB) model.Provider[map[string]interface{}] {
-	return func(db *gorm.DB) model.Provider[map[string]interface{}] {
-		entityProvider := GetByTenantIdAndResourceNameProvider(tenantID, "routes")(db)
-		return model.Map(func(e Entity) (map[string]interface{}, error) {
-			var resourceData map[string]interface{}
-			if err := json.Unmarshal(e.ResourceData, &resourceData); err != nil {
-				return nil, err
-			}
-
-			// Check if it's an array of resources
-			if resources, ok := resourceData["data"].([]interface{}); ok {
-				for _, resource := range resources {
-					if resourceMap, ok := resource.(map[string]interface{}); ok {
-						if id, ok := resourceMap["id"].(string); ok && id == routeID {
-							return resourceMap, nil
-						}
-					}
+// GetParentIdProvider returns a provider for the ID of the tenant a tenant inherits configuration from.
+// uuid.Nil is provided when the tenant has no parent or is unknown.
+func GetParentIdProvider(tenantID uuid.UUID) func(db *gorm.DB) model.Provider[uuid.UUID] {
+	return func(db *gorm.DB) model.Provider[uuid.UUID] {
+		return func() (uuid.UUID, error) {
+			e, err := database.Query[tenantEntity](db, map[string]interface{}{"id": tenantID})()
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return uuid.Nil, nil
 				}
-				return nil, gorm.ErrRecordNotFound
+				return uuid.Nil, err
 			}
-
-			// Check if it's a single resource
-			if data, ok := resourceData["data"].(map[string]interface{}); ok {
-				if id, ok := data["id"].(string); ok && id == routeID {
-					return data, nil
-				}
+			if e.ParentID == nil {
+				return uuid.Nil, nil
 			}
-
-			return nil, gorm.ErrRecordNotFound
-		})(entityProvider)
+			return *e.ParentID, nil
+		}
 	}
 }
 
-// GetAllRoutesProvider returns a provider for all routes for a tenant
-func GetAllRoutesProvider(tenantID uuid.UUID) func(db *gorm.DB) model.Provider[[]map[string]interface{}] {
-	return func(db *gorm.DB) model.Provider[[]map[string]interface{}] {
-		entityProvider := GetByTenantIdAndResourceNameProvider(tenantID, "routes")(db)
-		return model.Map(func(e Entity) ([]map[string]interface{}, error) {
-			var resourceData map[string]interface{}
-			if err := json.Unmarshal(e.ResourceData, &resourceData); err != nil {
-				return nil, err
-			}
-
-			// Check if it's an array of resources
-			if resources, ok := resourceData["data"].([]interface{}); ok {
-				result := make([]map[string]interface{}, 0, len(resources))
-				for _, resource := range resources {
-					if resourceMap, ok := resource.(map[string]interface{}); ok {
-						result = append(result, resourceMap)
+// resourceById returns a transformer which selects the resource with the given ID from a configuration, providing
+// gorm.ErrRecordNotFound when there is none
+func resourceById(resourceID string) func(e Entity) (map[string]interface{}, error) {
+	return func(e Entity) (map[string]interface{}, error) {
+		var resourceData map[string]interface{}
+		if err := json.Unmarshal(e.ResourceData, &resourceData); err != nil {
+			return nil, err
+		}
+
+		// Check if it's an array of resources
+		if resources, ok := resourceData["data"].([]interface{}); ok {
+			for _, resource := range resources {
+				if resourceMap, ok := resource.(map[string]interface{}); ok {
+					if id, ok := resourceMap["id"].(string); ok && id == resourceID {
+						return resourceMap, nil
 					}
 				}
-				return result, nil
 			}
+			return nil, gorm.ErrRecordNotFound
+		}
 
-			// Check if it's a single resource
-			if data, ok := resourceData["data"].(map[string]interface{}); ok {
-				return []map[string]interface{}{data}, nil
+		// Check if it's a single resource
+		if data, ok := resourceData["data"].(map[string]interface{}); ok {
+			if id, ok := data["id"].(string); ok && id == resourceID {
+				return data, nil
 			}
+		}
 
-			return []map[string]interface{}{}, nil
-		})(entityProvider)
+		return nil, gorm.ErrRecordNotFound
 	}
 }
 
-// GetVesselByIdProvider returns a provider for a specific vessel by ID
-func GetVesselByIdProvider(tenantID uuid.UUID, vesselID string) func(db *gorm.DB) model.Provider[map[string]interface{}] {
-	return func(db *gorm.DB) model.Provider[map[string]interface{}] {
-		entityProvider := GetByTenantIdAndResourceNameProvider(tenantID, "vessels")(db)
-		return model.Map(func(e Entity) (map[string]interface{}, error) {
-			var resourceData map[string]interface{}
-			if err := json.Unmarshal(e.ResourceData, &resourceData); err != nil {
-				return nil, err
-			}
-
-			// Check if it's an array of resources
-			if resources, ok := resourceData["data"].([]interface{}); ok {
-				for _, resource := range resources {
-					if resourceMap, ok := resource.(map[string]interface{}); ok {
-						if id, ok := resourceMap["id"].(string); ok && id == vesselID {
-							return resourceMap, nil
-						}
-					}
-				}
-				return nil, gorm.ErrRecordNotFound
-			}
-
-			// Check if it's a single resource
-			if data, ok := resourceData["data"].(map[string]interface{}); ok {
-				if id, ok := data["id"].(string); ok && id == vesselID {
-					return data, nil
-				}
-			}
-
-			return nil, gorm.ErrRecordNotFound
-		})(entityProvider)
+// allResources returns the resources held by a configuration
+func allResources(e Entity) ([]map[string]interface{}, error) {
+	var resourceData map[string]interface{}
+	if err := json.Unmarshal(e.ResourceData, &resourceData); err != nil {
+		return nil, err
 	}
-}
-
-// GetAllVesselsProvider returns a provider for all vessels for a tenant
-func GetAllVesselsProvider(tenantID uuid.UUID) func(db *gorm.DB) model.Provider[[]map[string]interface{}] {
-	return func(db *gorm.DB) model.Provider[[]map[string]interface{}] {
-		entityProvider := GetByTenantIdAndResourceNameProvider(tenantID, "vessels")(db)
-		return model.Map(func(e Entity) ([]map[string]interface{}, error) {
-			var resourceData map[string]interface{}
-			if err := json.Unmarshal(e.ResourceData, &resourceData); err != nil {
-				return nil, err
-			}
 
-			// Check if it's an array of resources
-			if resources, ok := resourceData["data"].([]interface{}); ok {
-				result := make([]map[string]interface{}, 0, len(resources))
-				for _, resource := range resources {
-					if resourceMap, ok := resource.(map[string]interface{}); ok {
-						result = append(result, resourceMap)
-					}
-				}
-				return result, nil
-			}
-
-			// Check if it's a single resource
-			if data, ok := resourceData["data"].(map[string]interface{}); ok {
-				return []map[string]interface{}{data}, nil
+	// Check if it's an array of resources
+	if resources, ok := resourceData["data"].([]interface{}); ok {
+		result := make([]map[string]interface{}, 0, len(resources))
+		for _, resource := range resources {
+			if resourceMap, ok := resource.(map[string]interface{}); ok {
+				result = append(result, resourceMap)
 			}
+		}
+		return result, nil
+	}
 
-			return []map[string]interface{}{}, nil
-		})(entityProvider)
+	// Check if it's a single resource
+	if data, ok := resourceData["data"].(map[string]interface{}); ok {
+		return []map[string]interface{}{data}, nil
 	}
+
+	return []map[string]interface{}{}, nil
 }
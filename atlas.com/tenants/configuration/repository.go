@@ -0,0 +1,72 @@
+package configuration
+
+import (
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Repository stores the configurations of tenants. Providers report a missing configuration as gorm.ErrRecordNotFound.
+type Repository interface {
+	// ByTenantIdAndResourceNameProvider returns a provider for a configuration by tenant ID and resource name
+	ByTenantIdAndResourceNameProvider(tenantID uuid.UUID, resourceName string) model.Provider[Entity]
+
+	// ByTenantIdProvider returns a provider for all configurations for a tenant
+	ByTenantIdProvider(tenantID uuid.UUID) model.Provider[[]Entity]
+
+	// ParentIdProvider returns a provider for the ID of the tenant a tenant inherits configuration from. uuid.Nil is
+	// provided when the tenant has no parent or is unknown.
+	ParentIdProvider(tenantID uuid.UUID) model.Provider[uuid.UUID]
+
+	// Create stores a new configuration at its first revision, returning the stored configuration. ErrRevisionConflict
+	// is returned when the tenant already holds a configuration of the resource.
+	Create(e Entity) (Entity, error)
+
+	// Update stores the changes to an existing configuration as the revision after the one it was read at, returning
+	// the stored configuration. ErrRevisionConflict is returned when the configuration was changed after it was read.
+	Update(e Entity) (Entity, error)
+
+	// DeleteResource removes a single resource from a configuration, returning ErrResourceNotFound when it does not
+	// exist
+	DeleteResource(tenantID uuid.UUID, resourceName string, resourceID string) error
+}
+
+// GormRepository stores configurations in the database
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository creates a repository backed by the database
+func NewGormRepository(db *gorm.DB) Repository {
+	return &GormRepository{db: db}
+}
+
+// ByTenantIdAndResourceNameProvider returns a provider for a configuration by tenant ID and resource name
+func (r *GormRepository) ByTenantIdAndResourceNameProvider(tenantID uuid.UUID, resourceName string) model.Provider[Entity] {
+	return GetByTenantIdAndResourceNameProvider(tenantID, resourceName)(r.db)
+}
+
+// ByTenantIdProvider returns a provider for all configurations for a tenant
+func (r *GormRepository) ByTenantIdProvider(tenantID uuid.UUID) model.Provider[[]Entity] {
+	return GetByTenantIdProvider(tenantID)(r.db)
+}
+
+// ParentIdProvider returns a provider for the ID of the tenant a tenant inherits configuration from
+func (r *GormRepository) ParentIdProvider(tenantID uuid.UUID) model.Provider[uuid.UUID] {
+	return GetParentIdProvider(tenantID)(r.db)
+}
+
+// Create stores a new configuration
+func (r *GormRepository) Create(e Entity) (Entity, error) {
+	return CreateConfiguration(r.db, e)
+}
+
+// Update stores the changes to an existing configuration
+func (r *GormRepository) Update(e Entity) (Entity, error) {
+	return UpdateConfiguration(r.db, e)
+}
+
+// DeleteResource removes a single resource from a configuration
+func (r *GormRepository) DeleteResource(tenantID uuid.UUID, resourceName string, resourceID string) error {
+	return DeleteConfiguration(r.db, tenantID, resourceName, resourceID)
+}
@@ -1,8 +1,14 @@
 package configuration
 
 import (
+	"atlas-tenants/database"
+	"atlas-tenants/domain"
+	"atlas-tenants/idempotency"
+	"atlas-tenants/openapi"
 	"atlas-tenants/rest"
 	"errors"
+	"fmt"
+	"github.com/Chronicle20/atlas-model/model"
 	"github.com/Chronicle20/atlas-rest/server"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -12,6 +18,53 @@ import (
 	"net/http"
 )
 
+var (
+	// ErrUnknownInclude is returned when a configuration listing requests the inclusion of an unsupported resource type
+	ErrUnknownInclude = domain.Validation("UNKNOWN_INCLUDE", "unknown include").WithParameter("include")
+	// ErrTenantNotFound is returned when a configuration listing names a tenant which does not exist. It has the code of
+	// the tenant package's error, which this package cannot import.
+	ErrTenantNotFound = domain.NotFound("TENANT_NOT_FOUND", "tenant not found")
+)
+
+// GetAllConfigurationsHandler handles GET /tenants/{tenantId}/configurations
+func GetAllConfigurationsHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+		return rest.ParseTenantId(d.Logger(), func(tenantId uuid.UUID) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				includes := rest.ParseQueryList(r, "include")
+				for _, include := range includes {
+					if include != "routes" && include != "vessels" {
+						rest.WriteError(d.Logger())(w)(fmt.Errorf("%w: %s", ErrUnknownInclude, include))
+						return
+					}
+				}
+
+				_, err := database.Query[tenantEntity](db.WithContext(d.Context()), map[string]interface{}{"id": tenantId})()
+				if err != nil {
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						err = ErrTenantNotFound.Wrap(err)
+					}
+					d.Logger().WithError(err).Error("Failed to get tenant")
+					rest.WriteError(d.Logger())(w)(err)
+					return
+				}
+
+				processor := NewProcessor(d.Logger(), d.Context(), db)
+				restModels, err := model.SliceMap(TransformIncluding(includes...))(processor.ByTenantIdProvider(tenantId))(model.ParallelMap())()
+				if err != nil {
+					d.Logger().WithError(err).Error("Failed to get configurations")
+					rest.WriteError(d.Logger())(w)(err)
+					return
+				}
+
+				query := r.URL.Query()
+				queryParams := jsonapi.ParseQueryFields(&query)
+				server.MarshalResponse[[]RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(restModels)
+			}
+		})
+	}
+}
+
 // GetAllRoutesHandler handles GET /tenants/{tenantId}/configurations/routes
 func GetAllRoutesHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
 	return func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
@@ -19,7 +72,12 @@ func GetAllRoutesHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 			return func(w http.ResponseWriter, r *http.Request) {
 				processor := NewProcessor(d.Logger(), d.Context(), db)
 
-				routes, err := processor.GetAllRoutes(tenantId)
+				routesProvider := processor.AllRoutesProvider(tenantId)
+				if !resolvedRequested(r) {
+					routesProvider = processor.AllLocalRoutesProvider(tenantId)
+				}
+
+				routes, err := routesProvider()
 				if err != nil {
 					if errors.Is(err, gorm.ErrRecordNotFound) {
 						// If no routes exist, return an empty array instead of an error
@@ -27,7 +85,7 @@ func GetAllRoutesHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 						routes = []map[string]interface{}{}
 					} else {
 						d.Logger().WithError(err).Error("Failed to get routes")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 				}
@@ -37,7 +95,7 @@ func GetAllRoutesHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 					rm, err := TransformRoute(route)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to transform route")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 					restModels = append(restModels, rm)
@@ -59,17 +117,22 @@ func GetRouteByIdHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 				return func(w http.ResponseWriter, r *http.Request) {
 					processor := NewProcessor(d.Logger(), d.Context(), db)
 
-					route, err := processor.GetRouteById(tenantId, routeId)
+					routeProvider := processor.RouteByIdProvider(tenantId, routeId)
+					if !resolvedRequested(r) {
+						routeProvider = processor.LocalRouteByIdProvider(tenantId, routeId)
+					}
+
+					route, err := routeProvider()
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to get route")
-						w.WriteHeader(http.StatusNotFound)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
 					rm, err := TransformRoute(route)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to transform route")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -90,15 +153,16 @@ func CreateRouteHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Han
 				route, err := ExtractRoute(model)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to extract route data")
-					w.WriteHeader(http.StatusBadRequest)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
+				assignId(route)
 
 				processor := NewProcessor(d.Logger(), d.Context(), db)
 				_, err = processor.CreateRouteAndEmit(tenantId, route)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to create route")
-					w.WriteHeader(http.StatusInternalServerError)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
@@ -112,14 +176,14 @@ func CreateRouteHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Han
 				createdRoute, err := processor.GetRouteById(tenantId, routeId)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to get created route")
-					w.WriteHeader(http.StatusInternalServerError)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
 				rm, err := TransformRoute(createdRoute)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to transform route")
-					w.WriteHeader(http.StatusInternalServerError)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
@@ -141,7 +205,7 @@ func UpdateRouteHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Han
 					route, err := ExtractRoute(model)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to extract route data")
-						w.WriteHeader(http.StatusBadRequest)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -149,7 +213,7 @@ func UpdateRouteHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Han
 					_, err = processor.UpdateRouteAndEmit(tenantId, routeId, route)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to update route")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -157,14 +221,14 @@ func UpdateRouteHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Han
 					updatedRoute, err := processor.GetRouteById(tenantId, routeId)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to get updated route")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
 					rm, err := TransformRoute(updatedRoute)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to transform route")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -187,7 +251,7 @@ func DeleteRouteHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Han
 					err := processor.DeleteRouteAndEmit(tenantId, routeId)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to delete route")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -205,7 +269,12 @@ func GetAllVesselsHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.H
 			return func(w http.ResponseWriter, r *http.Request) {
 				processor := NewProcessor(d.Logger(), d.Context(), db)
 
-				vessels, err := processor.GetAllVessels(tenantId)
+				vesselsProvider := processor.AllVesselsProvider(tenantId)
+				if !resolvedRequested(r) {
+					vesselsProvider = processor.AllLocalVesselsProvider(tenantId)
+				}
+
+				vessels, err := vesselsProvider()
 				if err != nil {
 					if errors.Is(err, gorm.ErrRecordNotFound) {
 						// If no vessels exist, return an empty array instead of an error
@@ -213,7 +282,7 @@ func GetAllVesselsHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.H
 						vessels = []map[string]interface{}{}
 					} else {
 						d.Logger().WithError(err).Error("Failed to get vessels")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 				}
@@ -223,7 +292,7 @@ func GetAllVesselsHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.H
 					rm, err := TransformVessel(vessel)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to transform vessel")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 					restModels = append(restModels, rm)
@@ -245,17 +314,22 @@ func GetVesselByIdHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.H
 				return func(w http.ResponseWriter, r *http.Request) {
 					processor := NewProcessor(d.Logger(), d.Context(), db)
 
-					vessel, err := processor.GetVesselById(tenantId, vesselId)
+					vesselProvider := processor.VesselByIdProvider(tenantId, vesselId)
+					if !resolvedRequested(r) {
+						vesselProvider = processor.LocalVesselByIdProvider(tenantId, vesselId)
+					}
+
+					vessel, err := vesselProvider()
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to get vessel")
-						w.WriteHeader(http.StatusNotFound)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
 					rm, err := TransformVessel(vessel)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to transform vessel")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -276,15 +350,16 @@ func CreateVesselHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 				vessel, err := ExtractVessel(model)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to extract vessel data")
-					w.WriteHeader(http.StatusBadRequest)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
+				assignId(vessel)
 
 				processor := NewProcessor(d.Logger(), d.Context(), db)
 				_, err = processor.CreateVesselAndEmit(tenantId, vessel)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to create vessel")
-					w.WriteHeader(http.StatusInternalServerError)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
@@ -298,14 +373,14 @@ func CreateVesselHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 				createdVessel, err := processor.GetVesselById(tenantId, vesselId)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to get created vessel")
-					w.WriteHeader(http.StatusInternalServerError)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
 				rm, err := TransformVessel(createdVessel)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to transform vessel")
-					w.WriteHeader(http.StatusInternalServerError)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
@@ -327,7 +402,7 @@ func UpdateVesselHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 					vessel, err := ExtractVessel(model)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to extract vessel data")
-						w.WriteHeader(http.StatusBadRequest)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -335,7 +410,7 @@ func UpdateVesselHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 					_, err = processor.UpdateVesselAndEmit(tenantId, vesselId, vessel)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to update vessel")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -343,14 +418,14 @@ func UpdateVesselHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 					updatedVessel, err := processor.GetVesselById(tenantId, vesselId)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to get updated vessel")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
 					rm, err := TransformVessel(updatedVessel)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to transform vessel")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -373,7 +448,7 @@ func DeleteVesselHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 					err := processor.DeleteVesselAndEmit(tenantId, vesselId)
 					if err != nil {
 						d.Logger().WithError(err).Error("Failed to delete vessel")
-						w.WriteHeader(http.StatusInternalServerError)
+						rest.WriteError(d.Logger())(w)(err)
 						return
 					}
 
@@ -384,6 +459,18 @@ func DeleteVesselHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 	}
 }
 
+// assignId gives a resource created without an ID a new one
+func assignId(resource map[string]interface{}) {
+	if id, _ := resource["id"].(string); id == "" {
+		resource["id"] = uuid.New().String()
+	}
+}
+
+// resolvedRequested returns false when the client asked for the tenant's local overrides only via ?resolved=false
+func resolvedRequested(r *http.Request) bool {
+	return r.URL.Query().Get("resolved") != "false"
+}
+
 // RegisterRoutes registers the configuration routes
 func RegisterRoutes(db *gorm.DB) func(si jsonapi.ServerInformation) server.RouteInitializer {
 	return func(si jsonapi.ServerInformation) server.RouteInitializer {
@@ -392,19 +479,42 @@ func RegisterRoutes(db *gorm.DB) func(si jsonapi.ServerInformation) server.Route
 			registerRouteInputHandler := rest.RegisterInputHandler[RouteRestModel](l)(si)
 			registerVesselInputHandler := rest.RegisterInputHandler[VesselRestModel](l)(si)
 
+			r.HandleFunc("/tenants/{tenantId}/configurations", registerHandler("get_all_configurations", GetAllConfigurationsHandler(db))).Methods(http.MethodGet)
+
 			// Route endpoints
 			r.HandleFunc("/tenants/{tenantId}/configurations/routes", registerHandler("get_all_routes", GetAllRoutesHandler(db))).Methods(http.MethodGet)
 			r.HandleFunc("/tenants/{tenantId}/configurations/routes/{routeId}", registerHandler("get_route_by_id", GetRouteByIdHandler(db))).Methods(http.MethodGet)
-			r.HandleFunc("/tenants/{tenantId}/configurations/routes", registerRouteInputHandler("create_route", CreateRouteHandler(db))).Methods(http.MethodPost)
+			r.HandleFunc("/tenants/{tenantId}/configurations/routes", registerRouteInputHandler("create_route", idempotency.Handler[RouteRestModel](db)(CreateRouteHandler(db)))).Methods(http.MethodPost)
 			r.HandleFunc("/tenants/{tenantId}/configurations/routes/{routeId}", registerRouteInputHandler("update_route", UpdateRouteHandler(db))).Methods(http.MethodPatch)
 			r.HandleFunc("/tenants/{tenantId}/configurations/routes/{routeId}", registerHandler("delete_route", DeleteRouteHandler(db))).Methods(http.MethodDelete)
 
 			// Vessel endpoints
 			r.HandleFunc("/tenants/{tenantId}/configurations/vessels", registerHandler("get_all_vessels", GetAllVesselsHandler(db))).Methods(http.MethodGet)
 			r.HandleFunc("/tenants/{tenantId}/configurations/vessels/{vesselId}", registerHandler("get_vessel_by_id", GetVesselByIdHandler(db))).Methods(http.MethodGet)
-			r.HandleFunc("/tenants/{tenantId}/configurations/vessels", registerVesselInputHandler("create_vessel", CreateVesselHandler(db))).Methods(http.MethodPost)
+			r.HandleFunc("/tenants/{tenantId}/configurations/vessels", registerVesselInputHandler("create_vessel", idempotency.Handler[VesselRestModel](db)(CreateVesselHandler(db)))).Methods(http.MethodPost)
 			r.HandleFunc("/tenants/{tenantId}/configurations/vessels/{vesselId}", registerVesselInputHandler("update_vessel", UpdateVesselHandler(db))).Methods(http.MethodPatch)
 			r.HandleFunc("/tenants/{tenantId}/configurations/vessels/{vesselId}", registerHandler("delete_vessel", DeleteVesselHandler(db))).Methods(http.MethodDelete)
 		}
 	}
 }
+
+// Operations describes the configuration routes
+func Operations() []openapi.Operation {
+	resolved := openapi.QueryParameter("resolved", "Set to false to return only the tenant's local overrides", false)
+	idempotencyKey := openapi.HeaderParameter(idempotency.Header, "Key making the request safe to retry")
+	return []openapi.Operation{
+		{Name: "get_all_configurations", Method: http.MethodGet, Path: "/tenants/{tenantId}/configurations", Summary: "List a tenant's configuration resources", Tag: "configurations", Response: RestModel{}, List: true},
+
+		{Name: "get_all_routes", Method: http.MethodGet, Path: "/tenants/{tenantId}/configurations/routes", Summary: "List routes", Tag: "routes", Parameters: []openapi.Parameter{resolved}, Response: RouteRestModel{}, List: true},
+		{Name: "get_route_by_id", Method: http.MethodGet, Path: "/tenants/{tenantId}/configurations/routes/{routeId}", Summary: "Get a route", Tag: "routes", Parameters: []openapi.Parameter{resolved}, Response: RouteRestModel{}},
+		{Name: "create_route", Method: http.MethodPost, Path: "/tenants/{tenantId}/configurations/routes", Summary: "Create a route", Tag: "routes", Parameters: []openapi.Parameter{idempotencyKey}, Request: RouteRestModel{}, Response: RouteRestModel{}, Status: http.StatusCreated},
+		{Name: "update_route", Method: http.MethodPatch, Path: "/tenants/{tenantId}/configurations/routes/{routeId}", Summary: "Update a route", Tag: "routes", Request: RouteRestModel{}, Response: RouteRestModel{}},
+		{Name: "delete_route", Method: http.MethodDelete, Path: "/tenants/{tenantId}/configurations/routes/{routeId}", Summary: "Delete a route", Tag: "routes", Status: http.StatusNoContent},
+
+		{Name: "get_all_vessels", Method: http.MethodGet, Path: "/tenants/{tenantId}/configurations/vessels", Summary: "List vessels", Tag: "vessels", Parameters: []openapi.Parameter{resolved}, Response: VesselRestModel{}, List: true},
+		{Name: "get_vessel_by_id", Method: http.MethodGet, Path: "/tenants/{tenantId}/configurations/vessels/{vesselId}", Summary: "Get a vessel", Tag: "vessels", Parameters: []openapi.Parameter{resolved}, Response: VesselRestModel{}},
+		{Name: "create_vessel", Method: http.MethodPost, Path: "/tenants/{tenantId}/configurations/vessels", Summary: "Create a vessel", Tag: "vessels", Parameters: []openapi.Parameter{idempotencyKey}, Request: VesselRestModel{}, Response: VesselRestModel{}, Status: http.StatusCreated},
+		{Name: "update_vessel", Method: http.MethodPatch, Path: "/tenants/{tenantId}/configurations/vessels/{vesselId}", Summary: "Update a vessel", Tag: "vessels", Request: VesselRestModel{}, Response: VesselRestModel{}},
+		{Name: "delete_vessel", Method: http.MethodDelete, Path: "/tenants/{tenantId}/configurations/vessels/{vesselId}", Summary: "Delete a vessel", Tag: "vessels", Status: http.StatusNoContent},
+	}
+}
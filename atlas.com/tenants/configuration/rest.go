@@ -2,9 +2,135 @@ package configuration
 
 import (
 	"encoding/json"
+	"github.com/jtumidanski/api2go/jsonapi"
 	"gorm.io/gorm"
+	"time"
 )
 
+// RestModel is the JSON:API resource summarizing a configuration resource type held by a tenant
+type RestModel struct {
+	Id           string            `json:"-"`
+	Count        int               `json:"count"`
+	LastModified time.Time         `json:"lastModified"`
+	Revision     uint32            `json:"revision"`
+	Routes       []RouteRestModel  `json:"-"`
+	Vessels      []VesselRestModel `json:"-"`
+	included     bool
+}
+
+// GetID returns the resource ID
+func (r RestModel) GetID() string {
+	return r.Id
+}
+
+// SetID sets the resource ID
+func (r *RestModel) SetID(id string) error {
+	r.Id = id
+	return nil
+}
+
+// GetName returns the resource name
+func (r RestModel) GetName() string {
+	return "configurations"
+}
+
+// GetReferences returns the relationship to the items of the resource type
+func (r RestModel) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         r.Id,
+			Name:         r.Id,
+			IsNotLoaded:  !r.included,
+			Relationship: jsonapi.ToManyRelationship,
+		},
+	}
+}
+
+// GetReferencedIDs returns the IDs of the embedded items
+func (r RestModel) GetReferencedIDs() []jsonapi.ReferenceID {
+	var result []jsonapi.ReferenceID
+	for _, rm := range r.Routes {
+		result = append(result, jsonapi.ReferenceID{ID: rm.GetID(), Type: rm.GetName(), Name: r.Id, Relationship: jsonapi.ToManyRelationship})
+	}
+	for _, vm := range r.Vessels {
+		result = append(result, jsonapi.ReferenceID{ID: vm.GetID(), Type: vm.GetName(), Name: r.Id, Relationship: jsonapi.ToManyRelationship})
+	}
+	return result
+}
+
+// GetReferencedStructs returns the embedded items
+func (r RestModel) GetReferencedStructs() []jsonapi.MarshalIdentifier {
+	var result []jsonapi.MarshalIdentifier
+	for _, rm := range r.Routes {
+		result = append(result, rm)
+	}
+	for _, vm := range r.Vessels {
+		result = append(result, vm)
+	}
+	return result
+}
+
+// Transform converts a Model to a RestModel. The resource ID is the name of the resource type.
+func Transform(m Model) (RestModel, error) {
+	return TransformIncluding()(m)
+}
+
+// TransformIncluding returns a transformer which also embeds the items of the named resource types, leaving out the
+// markers suppressing inherited resources
+func TransformIncluding(includes ...string) func(m Model) (RestModel, error) {
+	return func(m Model) (RestModel, error) {
+		resources, err := m.Resources()
+		if err != nil {
+			return RestModel{}, err
+		}
+
+		rm := RestModel{
+			Id:           m.ResourceName(),
+			Count:        countUnsuppressed(resources),
+			LastModified: m.LastModified(),
+			Revision:     m.Revision(),
+		}
+
+		for _, include := range includes {
+			if include != m.ResourceName() {
+				continue
+			}
+			rm.included = true
+			for _, resource := range resources {
+				if IsSuppressed(resource) {
+					continue
+				}
+				switch m.ResourceName() {
+				case "routes":
+					route, err := TransformRoute(resource)
+					if err != nil {
+						return RestModel{}, err
+					}
+					rm.Routes = append(rm.Routes, route)
+				case "vessels":
+					vessel, err := TransformVessel(resource)
+					if err != nil {
+						return RestModel{}, err
+					}
+					rm.Vessels = append(rm.Vessels, vessel)
+				}
+			}
+		}
+		return rm, nil
+	}
+}
+
+// countUnsuppressed returns the number of resources, not counting those suppressing an inherited resource
+func countUnsuppressed(resources []map[string]interface{}) int {
+	count := 0
+	for _, resource := range resources {
+		if !IsSuppressed(resource) {
+			count++
+		}
+	}
+	return count
+}
+
 // RouteRestModel is the JSON:API resource for routes
 type RouteRestModel struct {
 	Id                     string   `json:"-"`
@@ -18,6 +144,7 @@ type RouteRestModel struct {
 	PreDepartureDuration   uint32   `json:"preDepartureDuration"`
 	TravelDuration         uint32   `json:"travelDuration"`
 	CycleInterval          uint32   `json:"cycleInterval"`
+	Suppressed             bool     `json:"suppressed,omitempty"`
 }
 
 // GetID returns the resource ID
@@ -96,6 +223,8 @@ func TransformRoute(data map[string]interface{}) (RouteRestModel, error) {
 		cycleInterval = uint32(val)
 	}
 
+	suppressed, _ := attributes["suppressed"].(bool)
+
 	return RouteRestModel{
 		Id:                     id,
 		Name:                   name,
@@ -108,26 +237,31 @@ func TransformRoute(data map[string]interface{}) (RouteRestModel, error) {
 		PreDepartureDuration:   preDepartureDuration,
 		TravelDuration:         travelDuration,
 		CycleInterval:          cycleInterval,
+		Suppressed:             suppressed,
 	}, nil
 }
 
 // ExtractRoute converts a RouteRestModel to a map[string]interface{}
 func ExtractRoute(r RouteRestModel) (map[string]interface{}, error) {
+	attributes := map[string]interface{}{
+		"name":                   r.Name,
+		"startMapId":             r.StartMapId,
+		"stagingMapId":           r.StagingMapId,
+		"enRouteMapIds":          r.EnRouteMapIds,
+		"destinationMapId":       r.DestinationMapId,
+		"observationMapId":       r.ObservationMapId,
+		"boardingWindowDuration": r.BoardingWindowDuration,
+		"preDepartureDuration":   r.PreDepartureDuration,
+		"travelDuration":         r.TravelDuration,
+		"cycleInterval":          r.CycleInterval,
+	}
+	if r.Suppressed {
+		attributes["suppressed"] = true
+	}
 	return map[string]interface{}{
-		"type": "routes",
-		"id":   r.Id,
-		"attributes": map[string]interface{}{
-			"name":                   r.Name,
-			"startMapId":             r.StartMapId,
-			"stagingMapId":           r.StagingMapId,
-			"enRouteMapIds":          r.EnRouteMapIds,
-			"destinationMapId":       r.DestinationMapId,
-			"observationMapId":       r.ObservationMapId,
-			"boardingWindowDuration": r.BoardingWindowDuration,
-			"preDepartureDuration":   r.PreDepartureDuration,
-			"travelDuration":         r.TravelDuration,
-			"cycleInterval":          r.CycleInterval,
-		},
+		"type":       "routes",
+		"id":         r.Id,
+		"attributes": attributes,
 	}, nil
 }
 
@@ -183,6 +317,7 @@ type VesselRestModel struct {
 	RouteAID        string `json:"routeAID"`
 	RouteBID        string `json:"routeBID"`
 	TurnaroundDelay uint32 `json:"turnaroundDelay"`
+	Suppressed      bool   `json:"suppressed,omitempty"`
 }
 
 // GetID returns the resource ID
@@ -201,6 +336,45 @@ func (v VesselRestModel) GetName() string {
 	return "vessels"
 }
 
+// GetReferences returns the relationships of the vessel to the routes it travels
+func (v VesselRestModel) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         "routes",
+			Name:         "routeA",
+			Relationship: jsonapi.ToOneRelationship,
+		},
+		{
+			Type:         "routes",
+			Name:         "routeB",
+			Relationship: jsonapi.ToOneRelationship,
+		},
+	}
+}
+
+// GetReferencedIDs returns the IDs of the routes the vessel travels
+func (v VesselRestModel) GetReferencedIDs() []jsonapi.ReferenceID {
+	var result []jsonapi.ReferenceID
+	if v.RouteAID != "" {
+		result = append(result, jsonapi.ReferenceID{ID: v.RouteAID, Type: "routes", Name: "routeA", Relationship: jsonapi.ToOneRelationship})
+	}
+	if v.RouteBID != "" {
+		result = append(result, jsonapi.ReferenceID{ID: v.RouteBID, Type: "routes", Name: "routeB", Relationship: jsonapi.ToOneRelationship})
+	}
+	return result
+}
+
+// SetToOneReferenceID sets the route of the vessel from a routeA or routeB relationship
+func (v *VesselRestModel) SetToOneReferenceID(name, ID string) error {
+	switch name {
+	case "routeA":
+		v.RouteAID = ID
+	case "routeB":
+		v.RouteBID = ID
+	}
+	return nil
+}
+
 // TransformVessel converts a map[string]interface{} to a VesselRestModel
 func TransformVessel(data map[string]interface{}) (VesselRestModel, error) {
 	id, _ := data["id"].(string)
@@ -221,26 +395,33 @@ func TransformVessel(data map[string]interface{}) (VesselRestModel, error) {
 		turnaroundDelay = uint32(val)
 	}
 
+	suppressed, _ := attributes["suppressed"].(bool)
+
 	return VesselRestModel{
 		Id:              id,
 		Name:            name,
 		RouteAID:        routeAID,
 		RouteBID:        routeBID,
 		TurnaroundDelay: turnaroundDelay,
+		Suppressed:      suppressed,
 	}, nil
 }
 
 // ExtractVessel converts a VesselRestModel to a map[string]interface{}
 func ExtractVessel(v VesselRestModel) (map[string]interface{}, error) {
+	attributes := map[string]interface{}{
+		"name":            v.Name,
+		"routeAID":        v.RouteAID,
+		"routeBID":        v.RouteBID,
+		"turnaroundDelay": v.TurnaroundDelay,
+	}
+	if v.Suppressed {
+		attributes["suppressed"] = true
+	}
 	return map[string]interface{}{
-		"type": "vessels",
-		"id":   v.Id,
-		"attributes": map[string]interface{}{
-			"name":            v.Name,
-			"routeAID":        v.RouteAID,
-			"routeBID":        v.RouteBID,
-			"turnaroundDelay": v.TurnaroundDelay,
-		},
+		"type":       "vessels",
+		"id":         v.Id,
+		"attributes": attributes,
 	}, nil
 }
 
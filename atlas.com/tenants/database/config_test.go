@@ -0,0 +1,170 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+// environmentKeys are the variables read by FromEnvironment, cleared before each case so the host environment does not
+// leak into it
+var environmentKeys = []string{
+	"DB_DRIVER", "DATABASE_URL", "DB_USER", "DB_PASSWORD", "DB_HOST", "DB_PORT", "DB_NAME", "DB_SSLMODE",
+	"DB_SSLROOTCERT", "DB_SSLCERT", "DB_SSLKEY", "DB_APPLICATION_NAME", "DB_STATEMENT_TIMEOUT", "DB_MAX_OPEN_CONNS",
+	"DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "DB_CONN_MAX_IDLE_TIME",
+}
+
+// setEnvironment sets exactly the given database variables for the test
+func setEnvironment(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, k := range environmentKeys {
+		t.Setenv(k, env[k])
+	}
+}
+
+func TestFromEnvironmentPostgres(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantDSN      string
+		wantRedacted string
+	}{
+		{
+			name:         "variables",
+			env:          map[string]string{"DB_HOST": "db", "DB_PORT": "5432", "DB_USER": "atlas", "DB_PASSWORD": "secret", "DB_NAME": "tenants"},
+			wantDSN:      "host=db port=5432 user=atlas password=secret dbname=tenants sslmode=disable TimeZone=UTC",
+			wantRedacted: "host=db port=5432 user=atlas password=******** dbname=tenants sslmode=disable TimeZone=UTC",
+		},
+		{
+			name:         "url",
+			env:          map[string]string{"DATABASE_URL": "postgres://atlas:secret@db:5433/tenants?sslmode=require&application_name=tenants&statement_timeout=5s&connect_timeout=10"},
+			wantDSN:      "host=db port=5433 user=atlas password=secret dbname=tenants sslmode=require application_name=tenants statement_timeout=5000 TimeZone=UTC connect_timeout=10",
+			wantRedacted: "host=db port=5433 user=atlas password=******** dbname=tenants sslmode=require application_name=tenants statement_timeout=5000 TimeZone=UTC connect_timeout=10",
+		},
+		{
+			name: "variables override url",
+			env: map[string]string{
+				"DATABASE_URL":         "postgresql://atlas:secret@db:5433/tenants?sslmode=require",
+				"DB_HOST":              "replica",
+				"DB_PORT":              "6432",
+				"DB_PASSWORD":          "rotated",
+				"DB_SSLMODE":           "prefer",
+				"DB_STATEMENT_TIMEOUT": "250",
+			},
+			wantDSN:      "host=replica port=6432 user=atlas password=rotated dbname=tenants sslmode=prefer statement_timeout=250 TimeZone=UTC",
+			wantRedacted: "host=replica port=6432 user=atlas password=******** dbname=tenants sslmode=prefer statement_timeout=250 TimeZone=UTC",
+		},
+		{
+			name:         "blank variable keeps url",
+			env:          map[string]string{"DATABASE_URL": "postgres://atlas@db/tenants", "DB_HOST": "  "},
+			wantDSN:      "host=db user=atlas dbname=tenants sslmode=disable TimeZone=UTC",
+			wantRedacted: "host=db user=atlas dbname=tenants sslmode=disable TimeZone=UTC",
+		},
+		{
+			name:         "quoted password",
+			env:          map[string]string{"DB_HOST": "db", "DB_PASSWORD": "it's secret"},
+			wantDSN:      `host=db password='it\'s secret' sslmode=disable TimeZone=UTC`,
+			wantRedacted: "host=db password=******** sslmode=disable TimeZone=UTC",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnvironment(t, tt.env)
+
+			s, _, err := FromEnvironment()
+			if err != nil {
+				t.Fatalf("FromEnvironment() error = %v", err)
+			}
+			if s.Driver() != DriverPostgres {
+				t.Fatalf("Driver() = %s, want %s", s.Driver(), DriverPostgres)
+			}
+			if got := s.dsn.Build(); got != tt.wantDSN {
+				t.Errorf("Build() = %s, want %s", got, tt.wantDSN)
+			}
+			if got := s.Redacted(); got != tt.wantRedacted {
+				t.Errorf("Redacted() = %s, want %s", got, tt.wantRedacted)
+			}
+		})
+	}
+}
+
+func TestFromEnvironmentSQLite(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantPath string
+	}{
+		{name: "url", env: map[string]string{"DATABASE_URL": "sqlite:tenants.db"}, wantPath: "tenants.db"},
+		{name: "absolute url", env: map[string]string{"DATABASE_URL": "sqlite:///tmp/tenants.db"}, wantPath: "/tmp/tenants.db"},
+		{name: "name overrides url", env: map[string]string{"DATABASE_URL": "sqlite:tenants.db", "DB_NAME": "other.db"}, wantPath: "other.db"},
+		{name: "driver and name", env: map[string]string{"DB_DRIVER": DriverSQLite, "DB_NAME": "tenants.db", "DB_HOST": "ignored"}, wantPath: "tenants.db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnvironment(t, tt.env)
+
+			s, _, err := FromEnvironment()
+			if err != nil {
+				t.Fatalf("FromEnvironment() error = %v", err)
+			}
+			if s.Driver() != DriverSQLite || s.path != tt.wantPath {
+				t.Fatalf("FromEnvironment() = %s at %s, want %s at %s", s.Driver(), s.path, DriverSQLite, tt.wantPath)
+			}
+			if got := s.Redacted(); !strings.HasPrefix(got, tt.wantPath+"?_pragma=") {
+				t.Errorf("Redacted() = %s, want the path with its pragmas", got)
+			}
+		})
+	}
+}
+
+func TestFromEnvironmentErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "unknown driver", env: map[string]string{"DB_DRIVER": "mysql"}, wantErr: "invalid DB_DRIVER"},
+		{name: "no host", env: map[string]string{"DB_NAME": "tenants"}, wantErr: "no database host"},
+		{name: "url scheme", env: map[string]string{"DATABASE_URL": "mysql://db/tenants"}, wantErr: "invalid DATABASE_URL"},
+		{name: "url port", env: map[string]string{"DATABASE_URL": "postgres://db:0/tenants"}, wantErr: "invalid DATABASE_URL"},
+		{name: "port", env: map[string]string{"DB_HOST": "db", "DB_PORT": "postgres"}, wantErr: "invalid DB_PORT"},
+		{name: "sslmode", env: map[string]string{"DB_HOST": "db", "DB_SSLMODE": "always"}, wantErr: "invalid sslmode"},
+		{name: "certificate without key", env: map[string]string{"DB_HOST": "db", "DB_SSLCERT": "client.crt"}, wantErr: "requires both"},
+		{name: "missing root certificate", env: map[string]string{"DB_HOST": "db", "DB_SSLROOTCERT": "/nonexistent/root.crt"}, wantErr: "unable to read root certificate"},
+		{name: "statement timeout", env: map[string]string{"DB_HOST": "db", "DB_STATEMENT_TIMEOUT": "soon"}, wantErr: "invalid DB_STATEMENT_TIMEOUT"},
+		{name: "sqlite driver with postgres url", env: map[string]string{"DB_DRIVER": DriverSQLite, "DATABASE_URL": "postgres://db/tenants"}, wantErr: "invalid DATABASE_URL"},
+		{name: "sqlite without file", env: map[string]string{"DB_DRIVER": DriverSQLite}, wantErr: "no SQLite database file"},
+		{name: "idle exceeds open", env: map[string]string{"DB_HOST": "db", "DB_MAX_OPEN_CONNS": "5", "DB_MAX_IDLE_CONNS": "10"}, wantErr: "exceeds DB_MAX_OPEN_CONNS"},
+		{name: "lifetime", env: map[string]string{"DB_HOST": "db", "DB_CONN_MAX_LIFETIME": "-1m"}, wantErr: "invalid DB_CONN_MAX_LIFETIME"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnvironment(t, tt.env)
+
+			_, _, err := FromEnvironment()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("FromEnvironment() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFromEnvironmentURLErrorHidesPassword(t *testing.T) {
+	setEnvironment(t, map[string]string{"DATABASE_URL": "postgres://atlas:secret@db:bad port/tenants"})
+
+	_, _, err := FromEnvironment()
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Fatalf("FromEnvironment() error = %v, want an error without the password", err)
+	}
+}
+
+func TestFromEnvironmentPool(t *testing.T) {
+	setEnvironment(t, map[string]string{"DB_HOST": "db", "DB_MAX_OPEN_CONNS": "20", "DB_MAX_IDLE_CONNS": "5", "DB_CONN_MAX_LIFETIME": "30m", "DB_CONN_MAX_IDLE_TIME": "5m"})
+
+	_, pool, err := FromEnvironment()
+	if err != nil {
+		t.Fatalf("FromEnvironment() error = %v", err)
+	}
+	if pool.MaxOpenConns != 20 || pool.MaxIdleConns != 5 || pool.ConnMaxLifetime.Minutes() != 30 || pool.ConnMaxIdleTime.Minutes() != 5 {
+		t.Errorf("pool = %+v, want 20 open and 5 idle connections, living 30m and idle 5m", pool)
+	}
+}
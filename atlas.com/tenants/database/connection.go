@@ -2,29 +2,48 @@ package database
 
 import (
 	"atlas-tenants/retry"
-	"fmt"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
+const redacted = "********"
+
 type DSNBuilder struct {
-	user         string
-	password     string
-	host         string
-	port         uint16
-	databaseName string
+	user             string
+	password         string
+	host             string
+	port             uint16
+	databaseName     string
+	sslMode          string
+	sslRootCert      string
+	sslCert          string
+	sslKey           string
+	applicationName  string
+	statementTimeout time.Duration
+	params           map[string]string
 }
 
 func NewDSNBuilder() *DSNBuilder {
 	return &DSNBuilder{
-		user:         "",
-		password:     "",
-		host:         "",
-		port:         0,
-		databaseName: "",
+		user:             "",
+		password:         "",
+		host:             "",
+		port:             0,
+		databaseName:     "",
+		sslMode:          "disable",
+		sslRootCert:      "",
+		sslCert:          "",
+		sslKey:           "",
+		applicationName:  "",
+		statementTimeout: 0,
+		params:           map[string]string{"TimeZone": "UTC"},
 	}
 }
 
@@ -53,13 +72,120 @@ func (d *DSNBuilder) SetDatabaseName(value string) *DSNBuilder {
 	return d
 }
 
+func (d *DSNBuilder) SetSSLMode(value string) *DSNBuilder {
+	d.sslMode = value
+	return d
+}
+
+func (d *DSNBuilder) SetSSLRootCert(path string) *DSNBuilder {
+	d.sslRootCert = path
+	return d
+}
+
+func (d *DSNBuilder) SetSSLCert(path string) *DSNBuilder {
+	d.sslCert = path
+	return d
+}
+
+func (d *DSNBuilder) SetSSLKey(path string) *DSNBuilder {
+	d.sslKey = path
+	return d
+}
+
+func (d *DSNBuilder) SetApplicationName(value string) *DSNBuilder {
+	d.applicationName = value
+	return d
+}
+
+func (d *DSNBuilder) SetStatementTimeout(value time.Duration) *DSNBuilder {
+	d.statementTimeout = value
+	return d
+}
+
+// SetParam sets a connection parameter without a dedicated setter, such as connect_timeout
+func (d *DSNBuilder) SetParam(key string, value string) *DSNBuilder {
+	d.params[key] = value
+	return d
+}
+
+// Build returns the keyword/value connection string
 func (d *DSNBuilder) Build() string {
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=UTC", d.host, d.user, d.password, d.databaseName, d.port)
+	return d.build(d.password)
+}
+
+// Redacted returns the connection string with the password masked, for logging
+func (d *DSNBuilder) Redacted() string {
+	if d.password == "" {
+		return d.build("")
+	}
+	return d.build(redacted)
+}
+
+func (d *DSNBuilder) build(password string) string {
+	port := ""
+	if d.port != 0 {
+		port = strconv.Itoa(int(d.port))
+	}
+	statementTimeout := ""
+	if d.statementTimeout > 0 {
+		statementTimeout = strconv.FormatInt(d.statementTimeout.Milliseconds(), 10)
+	}
+
+	kvs := [][2]string{
+		{"host", d.host},
+		{"port", port},
+		{"user", d.user},
+		{"password", password},
+		{"dbname", d.databaseName},
+		{"sslmode", d.sslMode},
+		{"sslrootcert", d.sslRootCert},
+		{"sslcert", d.sslCert},
+		{"sslkey", d.sslKey},
+		{"application_name", d.applicationName},
+		{"statement_timeout", statementTimeout},
+	}
+	keys := make([]string, 0, len(d.params))
+	for k := range d.params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kvs = append(kvs, [2]string{k, d.params[k]})
+	}
+
+	parts := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		if kv[1] == "" {
+			continue
+		}
+		parts = append(parts, kv[0]+"="+quoteDSNValue(kv[1]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes a connection string value containing spaces, quotes or backslashes
+func quoteDSNValue(value string) string {
+	if !strings.ContainsAny(value, " '\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// PoolConfiguration bounds the connections held by the pool. Zero values keep the database/sql defaults.
+type PoolConfiguration struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
 type Configuration struct {
-	dsn        string
-	migrations []Migrator
+	ctx             context.Context
+	connectTimeout  time.Duration
+	source          Source
+	pool            PoolConfiguration
+	applicationName string
+	migrations      []Migrator
 }
 
 type Configurator func(c *Configuration)
@@ -70,61 +196,81 @@ func SetMigrations(migrations ...Migrator) Configurator {
 	}
 }
 
-type Migrator func(db *gorm.DB) error
-
-func Connect(l logrus.FieldLogger, configurators ...Configurator) *gorm.DB {
-	dsnBuilder := NewDSNBuilder()
-	user, ok := os.LookupEnv("DB_USER")
-	if ok {
-		dsnBuilder = dsnBuilder.SetUser(user)
-	}
-
-	password, ok := os.LookupEnv("DB_PASSWORD")
-	if ok {
-		dsnBuilder = dsnBuilder.SetPassword(password)
+// SetApplicationName sets the application_name reported to the server, unless DB_APPLICATION_NAME overrides it
+func SetApplicationName(name string) Configurator {
+	return func(c *Configuration) {
+		c.applicationName = name
 	}
+}
 
-	host, ok := os.LookupEnv("DB_HOST")
-	if ok {
-		dsnBuilder = dsnBuilder.SetHost(host)
+// SetContext sets the context which stops connection attempts when it ends
+func SetContext(ctx context.Context) Configurator {
+	return func(c *Configuration) {
+		c.ctx = ctx
 	}
+}
 
-	portStr, ok := os.LookupEnv("DB_PORT")
-	if ok {
-		port, err := strconv.Atoi(portStr)
-		if err == nil {
-			dsnBuilder = dsnBuilder.SetPort(uint16(port))
-		}
+// SetConnectTimeout sets how long connecting may be retried before startup fails
+func SetConnectTimeout(timeout time.Duration) Configurator {
+	return func(c *Configuration) {
+		c.connectTimeout = timeout
 	}
+}
 
-	databaseName, ok := os.LookupEnv("DB_NAME")
-	if ok {
-		dsnBuilder = dsnBuilder.SetDatabaseName(databaseName)
-	}
+type Migrator func(db *gorm.DB) error
 
+func Connect(l logrus.FieldLogger, configurators ...Configurator) *gorm.DB {
 	c := &Configuration{
-		dsn:        dsnBuilder.Build(),
-		migrations: make([]Migrator, 0),
+		ctx:            context.Background(),
+		connectTimeout: time.Minute,
+		migrations:     make([]Migrator, 0),
 	}
 	for _, configurator := range configurators {
 		configurator(c)
 	}
 
-	var db *gorm.DB
-	tryToConnect := func(attempt int) (bool, error) {
-		var err error
-		db, err = gorm.Open(postgres.Open(dsnBuilder.Build()), &gorm.Config{})
-		if err != nil {
-			return true, err
-		}
-		return false, err
+	source, pool, err := FromEnvironment()
+	if err != nil {
+		l.WithError(err).Fatalf("Invalid database configuration.")
+	}
+	source.setApplicationName(c.applicationName)
+	c.source = source
+	c.pool = pool
+
+	l.WithFields(logrus.Fields{
+		"driver":             c.source.Driver(),
+		"dsn":                c.source.Redacted(),
+		"max_open_conns":     c.pool.MaxOpenConns,
+		"max_idle_conns":     c.pool.MaxIdleConns,
+		"conn_max_lifetime":  c.pool.ConnMaxLifetime.String(),
+		"conn_max_idle_time": c.pool.ConnMaxIdleTime.String(),
+	}).Infof("Connecting to database.")
+
+	tryToConnect := func(ctx context.Context, attempt int) (*gorm.DB, error) {
+		// Translated errors let callers recognize constraint violations, such as gorm.ErrDuplicatedKey, on every driver
+		return gorm.Open(c.source.Dialector(), &gorm.Config{TranslateError: true})
+	}
+	retryable := retryableConnectError
+	if c.source.Driver() == DriverSQLite {
+		// a local file which cannot be opened will not become available
+		retryable = func(error) bool { return false }
 	}
 
-	err := retry.Try(tryToConnect, 10)
+	db, err := retry.Try(c.ctx, tryToConnect,
+		retry.MaxAttempts(0),
+		retry.InitialInterval(500*time.Millisecond),
+		retry.MaxInterval(5*time.Second),
+		retry.MaxElapsedTime(c.connectTimeout),
+		retry.RetryIf(retryable),
+		retry.Logger(l))
 	if err != nil {
 		l.WithError(err).Fatalf("Failed to connect to database.")
 	}
 
+	if err = configurePool(db, c.pool); err != nil {
+		l.WithError(err).Fatalf("Configuring connection pool.")
+	}
+
 	// Migrate the schema
 	for _, m := range c.migrations {
 		err = m(db)
@@ -134,3 +280,33 @@ func Connect(l logrus.FieldLogger, configurators ...Configurator) *gorm.DB {
 	}
 	return db
 }
+
+// retryableConnectError returns false for failures another attempt will not fix, such as rejected credentials or a
+// missing database
+func retryableConnectError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return true
+	}
+	return !strings.HasPrefix(pgErr.Code, "28") && pgErr.Code != "3D000"
+}
+
+func configurePool(db *gorm.DB, pool PoolConfiguration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if pool.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+	return nil
+}
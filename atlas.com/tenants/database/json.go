@@ -0,0 +1,48 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// JSON is a JSON document column, stored as jsonb on PostgreSQL and as text on SQLite
+type JSON json.RawMessage
+
+// Value stores the document as text, which every dialect accepts for its JSON column type
+func (j JSON) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan reads the document, which drivers return as either text or bytes
+func (j *JSON) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(JSON(nil), v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return fmt.Errorf("unable to scan %T into JSON", src)
+	}
+	return nil
+}
+
+// GormDataType returns the general data type of the column
+func (JSON) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType returns the column type for the dialect
+func (JSON) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if db.Dialector.Name() == DriverPostgres {
+		return "jsonb"
+	}
+	return "text"
+}
@@ -0,0 +1,271 @@
+package database
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SchemaVersionTable records the versioned migrations applied to the database
+const SchemaVersionTable = "schema_version"
+
+// migrationLockId is the advisory lock held while migrating, so only one replica migrates at a time
+const migrationLockId = 7_461_726_173
+
+// createSchemaVersionTable creates the schema version table in the SQL dialect of the database
+func createSchemaVersionTable(dialect string) string {
+	timestamp := "timestamp with time zone"
+	if dialect == DriverSQLite {
+		timestamp = "timestamp"
+	}
+	return `CREATE TABLE IF NOT EXISTS ` + SchemaVersionTable + ` (
+	version    bigint NOT NULL PRIMARY KEY,
+	name       text   NOT NULL,
+	applied_at ` + timestamp + ` NOT NULL
+)`
+}
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a numbered schema change with the SQL applying and reverting it
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a migration and whether it has been applied
+type MigrationState struct {
+	Migration Migration
+	AppliedAt *time.Time
+}
+
+// MigrationSource returns the migrations written in the SQL dialect of a database, as named by its gorm dialector
+type MigrationSource func(dialect string) ([]Migration, error)
+
+// Applied returns true if the migration has been applied
+func (s MigrationState) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+type schemaVersionEntity struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaVersionEntity) TableName() string {
+	return SchemaVersionTable
+}
+
+// AutoMigrate returns false when DB_AUTO_MIGRATE disables applying migrations at startup
+func AutoMigrate() bool {
+	if v, ok := os.LookupEnv("DB_AUTO_MIGRATE"); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return true
+}
+
+// LoadMigrations reads migrations from files named {version}_{name}.up.sql and {version}_{name}.down.sql, ordered by
+// version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := migrationFilePattern.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration file [%s] is not named {version}_{name}.(up|down).sql", e.Name())
+		}
+		version, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[uint(version)]
+		if !ok {
+			mg = &Migration{Version: uint(version), Name: m[2]}
+			byVersion[uint(version)] = mg
+		}
+		if mg.Name != m[2] {
+			return nil, fmt.Errorf("migration version [%d] has files named [%s] and [%s]", version, mg.Name, m[2])
+		}
+		if m[3] == "up" {
+			mg.Up = string(body)
+		} else {
+			mg.Down = string(body)
+		}
+	}
+
+	results := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migration [%d_%s] has no up file", mg.Version, mg.Name)
+		}
+		results = append(results, *mg)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Version < results[j].Version })
+	return results, nil
+}
+
+// VersionedMigrator applies every pending migration from the source, in the dialect of the database
+func VersionedMigrator(l logrus.FieldLogger, source MigrationSource) Migrator {
+	return func(db *gorm.DB) error {
+		migrations, err := source(db.Dialector.Name())
+		if err != nil {
+			return err
+		}
+		_, err = MigrateUp(l, db, migrations, 0)
+		return err
+	}
+}
+
+// MigrateUp applies up to steps pending migrations, or all of them when steps is 0, and returns how many were applied.
+// The migrations are applied in one transaction holding the migration lock.
+func MigrateUp(l logrus.FieldLogger, db *gorm.DB, migrations []Migration, steps int) (int, error) {
+	count := 0
+	err := migrate(db, func(tx *gorm.DB, applied map[uint]schemaVersionEntity) error {
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if steps > 0 && count >= steps {
+				break
+			}
+			l.Infof("Applying migration [%d_%s].", m.Version, m.Name)
+			if err := tx.Exec(m.Up).Error; err != nil {
+				return fmt.Errorf("applying migration [%d_%s]: %w", m.Version, m.Name, err)
+			}
+			if err := tx.Create(&schemaVersionEntity{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error; err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// MigrateDown reverts up to steps of the most recently applied migrations, and returns how many were reverted
+func MigrateDown(l logrus.FieldLogger, db *gorm.DB, migrations []Migration, steps int) (int, error) {
+	count := 0
+	err := migrate(db, func(tx *gorm.DB, applied map[uint]schemaVersionEntity) error {
+		for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration [%d_%s] cannot be reverted", m.Version, m.Name)
+			}
+			l.Infof("Reverting migration [%d_%s].", m.Version, m.Name)
+			if err := tx.Exec(m.Down).Error; err != nil {
+				return fmt.Errorf("reverting migration [%d_%s]: %w", m.Version, m.Name, err)
+			}
+			if err := tx.Delete(&schemaVersionEntity{}, m.Version).Error; err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// MigrationStatus returns every migration and when it was applied
+func MigrationStatus(db *gorm.DB, migrations []Migration) ([]MigrationState, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationState{Migration: m}
+		if e, ok := applied[m.Version]; ok {
+			at := e.AppliedAt
+			s.AppliedAt = &at
+		}
+		results = append(results, s)
+	}
+	return results, nil
+}
+
+// PendingMigrations returns the number of migrations not yet applied
+func PendingMigrations(db *gorm.DB, migrations []Migration) (int, error) {
+	states, err := MigrationStatus(db, migrations)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range states {
+		if !s.Applied() {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// migrate runs fn in a transaction holding the migration lock, with the migrations applied so far
+func migrate(db *gorm.DB, fn func(tx *gorm.DB, applied map[uint]schemaVersionEntity) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lockMigrations(tx); err != nil {
+			return err
+		}
+		if err := tx.Exec(createSchemaVersionTable(tx.Dialector.Name())).Error; err != nil {
+			return err
+		}
+		applied, err := appliedMigrations(tx)
+		if err != nil {
+			return err
+		}
+		return fn(tx, applied)
+	})
+}
+
+// lockMigrations takes a transaction scoped advisory lock, which is released when the transaction ends
+func lockMigrations(tx *gorm.DB) error {
+	if tx.Dialector.Name() != DriverPostgres {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockId).Error
+}
+
+func appliedMigrations(db *gorm.DB) (map[uint]schemaVersionEntity, error) {
+	results := make(map[uint]schemaVersionEntity)
+	if !db.Migrator().HasTable(&schemaVersionEntity{}) {
+		return results, nil
+	}
+	var es []schemaVersionEntity
+	if err := db.Order("version").Find(&es).Error; err != nil {
+		return nil, err
+	}
+	for _, e := range es {
+		results[e.Version] = e
+	}
+	return results, nil
+}
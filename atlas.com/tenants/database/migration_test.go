@@ -0,0 +1,177 @@
+package database
+
+import (
+	"github.com/sirupsen/logrus/hooks/test"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// testMigrations creates two tables and then an index, each with the SQL reverting it
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_one", Up: "CREATE TABLE one (id integer PRIMARY KEY)", Down: "DROP TABLE one"},
+	{Version: 2, Name: "create_two", Up: "CREATE TABLE two (id integer PRIMARY KEY, name text)", Down: "DROP TABLE two"},
+	{Version: 3, Name: "index_two", Up: "CREATE INDEX idx_two_name ON two (name)", Down: "DROP INDEX idx_two_name"},
+}
+
+// openSQLite opens an empty SQLite database file, closed when the test ends
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(SQLiteSource(filepath.Join(t.TempDir(), "migrations.db")).Dialector(), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// expectPending fails the test unless the number of pending migrations is want
+func expectPending(t *testing.T, db *gorm.DB, migrations []Migration, want int) {
+	t.Helper()
+	pending, err := PendingMigrations(db, migrations)
+	if err != nil {
+		t.Fatalf("PendingMigrations() error = %v", err)
+	}
+	if pending != want {
+		t.Fatalf("PendingMigrations() = %d, want %d", pending, want)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        fstest.MapFS
+		wantVersions []uint
+		wantErr      bool
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"0010_add_index.up.sql":    {Data: []byte("CREATE INDEX")},
+				"0002_create_two.up.sql":   {Data: []byte("CREATE TABLE two")},
+				"0002_create_two.down.sql": {Data: []byte("DROP TABLE two")},
+				"0001_create_one.up.sql":   {Data: []byte("CREATE TABLE one")},
+				"0001_create_one.down.sql": {Data: []byte("DROP TABLE one")},
+				"0010_add_index.down.sql":  {Data: []byte("DROP INDEX")},
+			},
+			wantVersions: []uint{1, 2, 10},
+		},
+		{name: "misnamed file", files: fstest.MapFS{"0001_create_one.sql": {Data: []byte("CREATE TABLE one")}}, wantErr: true},
+		{name: "missing up file", files: fstest.MapFS{"0001_create_one.down.sql": {Data: []byte("DROP TABLE one")}}, wantErr: true},
+		{
+			name: "names differ",
+			files: fstest.MapFS{
+				"0001_create_one.up.sql":   {Data: []byte("CREATE TABLE one")},
+				"0001_create_two.down.sql": {Data: []byte("DROP TABLE two")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, err := LoadMigrations(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadMigrations() error = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(ms) != len(tt.wantVersions) {
+				t.Fatalf("LoadMigrations() = %d migrations, want %d", len(ms), len(tt.wantVersions))
+			}
+			for i, m := range ms {
+				if m.Version != tt.wantVersions[i] || m.Up == "" || m.Down == "" {
+					t.Errorf("migration %d = %+v, want version %d with up and down SQL", i, m, tt.wantVersions[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := openSQLite(t)
+
+	expectPending(t, db, testMigrations, 3)
+
+	if n, err := MigrateUp(l, db, testMigrations, 1); err != nil || n != 1 {
+		t.Fatalf("MigrateUp(1) = %d, %v, want 1", n, err)
+	}
+	expectPending(t, db, testMigrations, 2)
+	if !db.Migrator().HasTable("one") || db.Migrator().HasTable("two") {
+		t.Fatal("after one step, want table one and not table two")
+	}
+
+	if n, err := MigrateUp(l, db, testMigrations, 0); err != nil || n != 2 {
+		t.Fatalf("MigrateUp(0) = %d, %v, want the 2 pending migrations", n, err)
+	}
+	expectPending(t, db, testMigrations, 0)
+	if n, err := MigrateUp(l, db, testMigrations, 0); err != nil || n != 0 {
+		t.Fatalf("MigrateUp(0) when current = %d, %v, want 0", n, err)
+	}
+
+	if n, err := MigrateDown(l, db, testMigrations, 2); err != nil || n != 2 {
+		t.Fatalf("MigrateDown(2) = %d, %v, want 2", n, err)
+	}
+	expectPending(t, db, testMigrations, 2)
+	if !db.Migrator().HasTable("one") || db.Migrator().HasTable("two") {
+		t.Fatal("after reverting two steps, want table one and not table two")
+	}
+	states, err := MigrationStatus(db, testMigrations)
+	if err != nil {
+		t.Fatalf("MigrationStatus() error = %v", err)
+	}
+	if !states[0].Applied() || states[1].Applied() || states[2].Applied() {
+		t.Errorf("MigrationStatus() = %+v, want only version 1 applied", states)
+	}
+
+	if n, err := MigrateDown(l, db, testMigrations, 5); err != nil || n != 1 {
+		t.Fatalf("MigrateDown(5) = %d, %v, want only the 1 applied migration", n, err)
+	}
+	expectPending(t, db, testMigrations, 3)
+	if db.Migrator().HasTable("one") {
+		t.Error("after reverting every migration, table one still exists")
+	}
+}
+
+func TestMigrateUpRollsBackFailure(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := openSQLite(t)
+	migrations := []Migration{
+		testMigrations[0],
+		{Version: 2, Name: "broken", Up: "CREATE TABLE", Down: "DROP TABLE two"},
+	}
+
+	if _, err := MigrateUp(l, db, migrations, 0); err == nil {
+		t.Fatal("MigrateUp() of a broken migration succeeded")
+	}
+	expectPending(t, db, migrations, 2)
+	if db.Migrator().HasTable("one") {
+		t.Error("migration before the broken one was not rolled back")
+	}
+}
+
+func TestMigrateDownIrreversible(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := openSQLite(t)
+	migrations := []Migration{
+		testMigrations[0],
+		{Version: 2, Name: "create_two", Up: testMigrations[1].Up},
+	}
+	if _, err := MigrateUp(l, db, migrations, 0); err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+
+	if _, err := MigrateDown(l, db, migrations, 2); err == nil {
+		t.Fatal("MigrateDown() of a migration without down SQL succeeded")
+	}
+	expectPending(t, db, migrations, 0)
+	if !db.Migrator().HasTable("two") {
+		t.Error("irreversible migration was reverted")
+	}
+}
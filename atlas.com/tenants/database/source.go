@@ -0,0 +1,71 @@
+package database
+
+import (
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"strings"
+)
+
+const (
+	// DriverPostgres selects PostgreSQL, which the service is deployed with
+	DriverPostgres = "postgres"
+	// DriverSQLite selects a SQLite database file, for local development and tests
+	DriverSQLite = "sqlite"
+)
+
+// sqlitePragmas let concurrent requests wait for the write lock instead of failing, and let readers run during writes
+var sqlitePragmas = []string{"busy_timeout(5000)", "journal_mode(WAL)"}
+
+// Source is the database the service connects to
+type Source struct {
+	driver string
+	dsn    *DSNBuilder
+	path   string
+}
+
+// PostgresSource creates a source for the PostgreSQL database described by the connection string
+func PostgresSource(dsn *DSNBuilder) Source {
+	return Source{driver: DriverPostgres, dsn: dsn}
+}
+
+// SQLiteSource creates a source for the SQLite database file at path
+func SQLiteSource(path string) Source {
+	return Source{driver: DriverSQLite, path: path}
+}
+
+// Driver returns the name of the driver, which is also the name gorm reports for its dialect
+func (s Source) Driver() string {
+	return s.driver
+}
+
+// Dialector returns the gorm dialector opening the database
+func (s Source) Dialector() gorm.Dialector {
+	if s.driver == DriverSQLite {
+		return sqlite.Open(s.sqliteDSN())
+	}
+	return postgres.Open(s.dsn.Build())
+}
+
+// Redacted describes the database for logging, with any password masked
+func (s Source) Redacted() string {
+	if s.driver == DriverSQLite {
+		return s.sqliteDSN()
+	}
+	return s.dsn.Redacted()
+}
+
+// setApplicationName sets the application_name reported to PostgreSQL, unless the environment already set one
+func (s Source) setApplicationName(name string) {
+	if s.dsn != nil && s.dsn.applicationName == "" {
+		s.dsn.SetApplicationName(name)
+	}
+}
+
+func (s Source) sqliteDSN() string {
+	separator := "?"
+	if strings.Contains(s.path, "?") {
+		separator = "&"
+	}
+	return s.path + separator + "_pragma=" + strings.Join(sqlitePragmas, "&_pragma=")
+}
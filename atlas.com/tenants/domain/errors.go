@@ -0,0 +1,141 @@
+package domain
+
+// Kind classifies a domain error by the way callers are expected to react to it
+type Kind string
+
+const (
+	KindNotFound        Kind = "NOT_FOUND"
+	KindConflict        Kind = "CONFLICT"
+	KindValidation      Kind = "VALIDATION"
+	KindPrecondition    Kind = "PRECONDITION"
+	KindUnprocessable   Kind = "UNPROCESSABLE"
+	KindUnauthenticated Kind = "UNAUTHENTICATED"
+	KindForbidden       Kind = "FORBIDDEN"
+	KindCancelled       Kind = "CANCELLED"
+	KindUnavailable     Kind = "UNAVAILABLE"
+)
+
+// Error is a typed domain error. Errors are identified by their code, so errors.Is matches any two errors sharing a
+// code regardless of the detail, source or cause attached to them.
+type Error struct {
+	kind      Kind
+	code      string
+	detail    string
+	pointer   string
+	parameter string
+	cause     error
+}
+
+// NotFound creates an error for a resource which does not exist
+func NotFound(code string, detail string) *Error {
+	return &Error{kind: KindNotFound, code: code, detail: detail}
+}
+
+// Conflict creates an error for a request which conflicts with the current state of a resource
+func Conflict(code string, detail string) *Error {
+	return &Error{kind: KindConflict, code: code, detail: detail}
+}
+
+// Validation creates an error for a request carrying invalid input
+func Validation(code string, detail string) *Error {
+	return &Error{kind: KindValidation, code: code, detail: detail}
+}
+
+// Precondition creates an error for a request whose precondition does not hold
+func Precondition(code string, detail string) *Error {
+	return &Error{kind: KindPrecondition, code: code, detail: detail}
+}
+
+// Unprocessable creates an error for a well formed request which cannot be processed as sent
+func Unprocessable(code string, detail string) *Error {
+	return &Error{kind: KindUnprocessable, code: code, detail: detail}
+}
+
+// Unauthenticated creates an error for a request without valid credentials
+func Unauthenticated(code string, detail string) *Error {
+	return &Error{kind: KindUnauthenticated, code: code, detail: detail}
+}
+
+// Forbidden creates an error for a request whose credentials do not permit the operation
+func Forbidden(code string, detail string) *Error {
+	return &Error{kind: KindForbidden, code: code, detail: detail}
+}
+
+// Cancelled creates an error for a request abandoned by its caller before it completed
+func Cancelled(code string, detail string) *Error {
+	return &Error{kind: KindCancelled, code: code, detail: detail}
+}
+
+// Unavailable creates an error for a request which cannot be served right now, and may succeed when retried
+func Unavailable(code string, detail string) *Error {
+	return &Error{kind: KindUnavailable, code: code, detail: detail}
+}
+
+// Kind returns the kind of the error
+func (e *Error) Kind() Kind {
+	return e.kind
+}
+
+// Code returns the stable, machine readable code of the error
+func (e *Error) Code() string {
+	return e.code
+}
+
+// Detail returns the human readable explanation of the error
+func (e *Error) Detail() string {
+	return e.detail
+}
+
+// Pointer returns the JSON pointer of the request document member which caused the error, if any
+func (e *Error) Pointer() string {
+	return e.pointer
+}
+
+// Parameter returns the name of the query parameter which caused the error, if any
+func (e *Error) Parameter() string {
+	return e.parameter
+}
+
+// WithDetail returns a copy of the error with the given detail
+func (e *Error) WithDetail(detail string) *Error {
+	c := *e
+	c.detail = detail
+	return &c
+}
+
+// WithPointer returns a copy of the error attributed to the request document member at the given JSON pointer
+func (e *Error) WithPointer(pointer string) *Error {
+	c := *e
+	c.pointer = pointer
+	return &c
+}
+
+// WithParameter returns a copy of the error attributed to the given query parameter
+func (e *Error) WithParameter(parameter string) *Error {
+	c := *e
+	c.parameter = parameter
+	return &c
+}
+
+// Wrap returns a copy of the error caused by cause
+func (e *Error) Wrap(cause error) *Error {
+	c := *e
+	c.cause = cause
+	return &c
+}
+
+// Error returns the detail of the error
+func (e *Error) Error() string {
+	return e.detail
+}
+
+// Unwrap returns the cause of the error
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is returns true if target is a domain error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.code == e.code
+}
@@ -0,0 +1,1017 @@
+package main
+
+import (
+	"atlas-tenants/auth"
+	"atlas-tenants/configuration"
+	"atlas-tenants/database"
+	"atlas-tenants/idempotency"
+	"atlas-tenants/kafka/kafkatest"
+	"atlas-tenants/kafka/producer"
+	"atlas-tenants/migrations"
+	"atlas-tenants/replay"
+	"atlas-tenants/rest"
+	"atlas-tenants/tenant"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer serves the REST API over a SQLite database, recording produced events in memory
+type testServer struct {
+	t        *testing.T
+	router   *mux.Router
+	db       *gorm.DB
+	events   *producer.Memory
+	shutdown func()
+	// header holds the credentials sent with each request
+	header http.Header
+}
+
+// newTestServer creates a test server which grants every request the admin role
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newSecuredTestServer(t, auth.NewConfig(auth.Anonymous(auth.RoleAdmin), auth.DefaultPolicy()))
+}
+
+// newSecuredTestServer creates a test server whose handlers authenticate and authorize requests with the config
+func newSecuredTestServer(t *testing.T, c auth.Config) *testServer {
+	t.Helper()
+	rest.ConfigureSecurity(c)
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DB_DRIVER", database.DriverSQLite)
+	t.Setenv("DB_NAME", filepath.Join(t.TempDir(), "tenants.db"))
+	events := kafkatest.Record(t)
+
+	l, _ := test.NewNullLogger()
+	db := database.Connect(l, database.SetMigrations(database.VersionedMigrator(l, migrations.For)))
+	db.Logger = logger.Discard
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	// Replays run until the test ends, and finish before the database is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	shutdown := func() {
+		cancel()
+		wg.Wait()
+	}
+	t.Cleanup(shutdown)
+	rn := replay.NewRunner(l, ctx, wg, db)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix(strings.TrimSuffix(GetServer().GetPrefix(), "/")).Subrouter()
+	for _, ri := range routeInitializers(db, rn) {
+		ri(api, l)
+	}
+	return &testServer{t: t, router: router, db: db, events: events, shutdown: shutdown}
+}
+
+// do serves a request with an optional JSON:API body, and returns the response
+func (s *testServer) do(method string, path string, body string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/vnd.api+json")
+	}
+	for k, v := range s.header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	return w
+}
+
+// expect serves a request and fails the test unless it returns the status
+func (s *testServer) expect(method string, path string, body string, status int) *httptest.ResponseRecorder {
+	s.t.Helper()
+	w := s.do(method, path, body)
+	if w.Code != status {
+		s.t.Fatalf("%s %s returned %d, want %d: %s", method, path, w.Code, status, w.Body)
+	}
+	return w
+}
+
+// createTenant creates a tenant and returns its ID
+func (s *testServer) createTenant(name string) string {
+	s.t.Helper()
+	return resourceDocument(s.t, s.expect(http.MethodPost, "/api/tenants", tenantBody(name, ""), http.StatusCreated)).Data.Id
+}
+
+// createResource creates a route or vessel of a tenant and returns its ID
+func (s *testServer) createResource(tenantId string, resourceName string, body string) string {
+	s.t.Helper()
+	return resourceDocument(s.t, s.expect(http.MethodPost, "/api/tenants/"+tenantId+"/configurations/"+resourceName, body, http.StatusCreated)).Data.Id
+}
+
+// awaitReplay polls an event replay until it finishes, and returns its final progress
+func (s *testServer) awaitReplay(replayId string) resource {
+	s.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r := resourceDocument(s.t, s.expect(http.MethodGet, "/api/admin/events/replay/"+replayId, "", http.StatusOK)).Data
+		if r.Attributes["finishedAt"] != nil {
+			return r
+		}
+		if time.Now().After(deadline) {
+			s.t.Fatalf("replay %s did not finish: %+v", replayId, r)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// resource is a JSON:API resource object
+type resource struct {
+	Type       string                 `json:"type"`
+	Id         string                 `json:"id"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+type singleDocument struct {
+	Data resource `json:"data"`
+}
+
+type listDocument struct {
+	Data []resource `json:"data"`
+}
+
+type errorDocument struct {
+	Errors []struct {
+		Status string `json:"status"`
+		Code   string `json:"code"`
+	} `json:"errors"`
+}
+
+func decode[D any](t *testing.T, w *httptest.ResponseRecorder) D {
+	t.Helper()
+	var d D
+	if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+		t.Fatalf("unable to decode response [%s]: %v", w.Body, err)
+	}
+	return d
+}
+
+func resourceDocument(t *testing.T, w *httptest.ResponseRecorder) singleDocument {
+	t.Helper()
+	return decode[singleDocument](t, w)
+}
+
+func listOf(t *testing.T, w *httptest.ResponseRecorder) []resource {
+	t.Helper()
+	d := decode[listDocument](t, w)
+	if d.Data == nil {
+		t.Fatalf("response [%s] has no data array", w.Body)
+	}
+	return d.Data
+}
+
+// errorCode returns the code of the single error in an error response
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	d := decode[errorDocument](t, w)
+	if len(d.Errors) != 1 || d.Errors[0].Status != fmt.Sprint(w.Code) {
+		t.Fatalf("response [%s] is not a single error with status %d", w.Body, w.Code)
+	}
+	return d.Errors[0].Code
+}
+
+func tenantBody(name string, parentId string) string {
+	parent := ""
+	if parentId != "" {
+		parent = fmt.Sprintf(`,"parentId":%q`, parentId)
+	}
+	return fmt.Sprintf(`{"data":{"type":"tenants","attributes":{"name":%q,"region":"GMS","majorVersion":83,"minorVersion":1%s}}}`, name, parent)
+}
+
+func routeBody(name string, travelDuration int) string {
+	return fmt.Sprintf(`{"data":{"type":"routes","attributes":{"name":%q,"startMapId":101000300,"stagingMapId":101000301,"enRouteMapIds":[200090010,200090011],"destinationMapId":200000100,"observationMapId":200090012,"boardingWindowDuration":4,"preDepartureDuration":1,"travelDuration":%d,"cycleInterval":40}}}`, name, travelDuration)
+}
+
+func vesselBody(name string, routeAID string, routeBID string) string {
+	return fmt.Sprintf(`{"data":{"type":"vessels","attributes":{"name":%q,"routeAID":%q,"routeBID":%q,"turnaroundDelay":0}}}`, name, routeAID, routeBID)
+}
+
+func TestTenantLifecycle(t *testing.T) {
+	t.Setenv(tenant.EnvEventFormat, tenant.EventFormatEnvelope)
+	s := newTestServer(t)
+
+	if got := listOf(t, s.expect(http.MethodGet, "/api/tenants", "", http.StatusOK)); len(got) != 0 {
+		t.Fatalf("GET /api/tenants = %v, want an empty collection", got)
+	}
+
+	created := resourceDocument(t, s.expect(http.MethodPost, "/api/tenants", tenantBody("Atlas", ""), http.StatusCreated)).Data
+	if _, err := uuid.Parse(created.Id); err != nil || created.Type != "tenants" || created.Attributes["name"] != "Atlas" || created.Attributes["region"] != "GMS" {
+		t.Fatalf("POST /api/tenants = %+v, want a tenants resource named Atlas", created)
+	}
+	path := "/api/tenants/" + created.Id
+
+	got := resourceDocument(t, s.expect(http.MethodGet, path, "", http.StatusOK)).Data
+	if got.Id != created.Id || got.Attributes["majorVersion"] != float64(83) || got.Attributes["minorVersion"] != float64(1) {
+		t.Errorf("GET %s = %+v, want the created tenant", path, got)
+	}
+	if all := listOf(t, s.expect(http.MethodGet, "/api/tenants", "", http.StatusOK)); len(all) != 1 || all[0].Id != created.Id {
+		t.Errorf("GET /api/tenants = %+v, want the created tenant", all)
+	}
+
+	child := resourceDocument(t, s.expect(http.MethodPost, "/api/tenants", tenantBody("Child", created.Id), http.StatusCreated)).Data
+	if child.Attributes["parentId"] != created.Id {
+		t.Errorf("POST /api/tenants with parent = %+v, want parentId %s", child, created.Id)
+	}
+
+	rename := strings.Replace(tenantBody("Renamed", ""), `"type":"tenants"`, `"type":"tenants","id":"`+created.Id+`"`, 1)
+	updated := resourceDocument(t, s.expect(http.MethodPatch, path, rename, http.StatusOK)).Data
+	if updated.Id != created.Id || updated.Attributes["name"] != "Renamed" {
+		t.Errorf("PATCH %s = %+v, want the renamed tenant", path, updated)
+	}
+	// Repeating the update changes nothing, so it emits no event
+	if again := resourceDocument(t, s.expect(http.MethodPatch, path, rename, http.StatusOK)).Data; again.Attributes["name"] != "Renamed" {
+		t.Errorf("repeated PATCH %s = %+v, want the renamed tenant", path, again)
+	}
+
+	// The parent is deleted only once its child is
+	if code := errorCode(t, s.expect(http.MethodDelete, path, "", http.StatusConflict)); code != "TENANT_HAS_CHILDREN" {
+		t.Errorf("DELETE %s with a child code = %s, want TENANT_HAS_CHILDREN", path, code)
+	}
+	s.expect(http.MethodDelete, "/api/tenants/"+child.Id, "", http.StatusNoContent)
+	w := s.expect(http.MethodDelete, path, "", http.StatusNoContent)
+	if w.Body.Len() != 0 {
+		t.Errorf("DELETE %s body = %s, want none", path, w.Body)
+	}
+	if code := errorCode(t, s.expect(http.MethodGet, path, "", http.StatusNotFound)); code != "TENANT_NOT_FOUND" {
+		t.Errorf("GET %s after delete code = %s, want TENANT_NOT_FOUND", path, code)
+	}
+
+	es := kafkatest.Decode[tenant.Event[json.RawMessage]](t, s.events.Messages(tenant.EventTopicTenantStatus))
+	var types []string
+	for _, e := range es {
+		types = append(types, e.Type)
+		if e.Actor.Subject != "anonymous" || e.Actor.Method != auth.MethodNone {
+			t.Errorf("%s event actor = %+v, want the anonymous principal of the request", e.Type, e.Actor)
+		}
+	}
+	if strings.Join(types, ",") != "CREATED,CREATED,UPDATED,DELETED,DELETED" {
+		t.Errorf("emitted tenant events %v, want CREATED, CREATED, UPDATED, DELETED, DELETED", types)
+	}
+}
+
+// signToken returns a JWT with the claims, signed with HS256 and the key
+func signToken(t *testing.T, claims map[string]interface{}, key string) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("unable to encode token segment: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := segment(map[string]interface{}{"alg": "HS256", "typ": "JWT"}) + "." + segment(claims)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthentication(t *testing.T) {
+	t.Setenv("AUTH_API_KEYS", "admin:admin-key:admin,viewer:viewer-key:viewer")
+	t.Setenv("AUTH_JWT_KEYS", "primary:secret")
+	t.Setenv("AUTH_DISABLED", "")
+	t.Setenv("AUTH_ROLES", "")
+	t.Setenv(tenant.EnvEventFormat, tenant.EventFormatEnvelope)
+	l, _ := test.NewNullLogger()
+	c, err := auth.FromEnvironment(l)
+	if err != nil {
+		t.Fatalf("FromEnvironment() error = %v", err)
+	}
+	s := newSecuredTestServer(t, c)
+	apiKey := func(key string) http.Header {
+		h := http.Header{}
+		h.Set(auth.APIKeyHeader, key)
+		return h
+	}
+
+	s.header = nil
+	if code := errorCode(t, s.expect(http.MethodGet, "/api/tenants", "", http.StatusUnauthorized)); code != "UNAUTHENTICATED" {
+		t.Errorf("GET /api/tenants without credentials code = %s, want UNAUTHENTICATED", code)
+	}
+	s.header = apiKey("guess")
+	if code := errorCode(t, s.expect(http.MethodGet, "/api/tenants", "", http.StatusUnauthorized)); code != "INVALID_CREDENTIALS" {
+		t.Errorf("GET /api/tenants with an unknown key code = %s, want INVALID_CREDENTIALS", code)
+	}
+
+	s.header = apiKey("admin-key")
+	scoped := s.createTenant("scoped")
+	other := s.createTenant("other")
+	es := kafkatest.Decode[tenant.Event[json.RawMessage]](t, s.events.Messages(tenant.EventTopicTenantStatus))
+	if len(es) == 0 || es[0].Actor.Subject != "admin" || es[0].Actor.Method != auth.MethodAPIKey {
+		t.Errorf("tenant events = %+v, want the admin key as actor", es)
+	}
+
+	s.header = apiKey("viewer-key")
+	if got := listOf(t, s.expect(http.MethodGet, "/api/tenants", "", http.StatusOK)); len(got) != 2 {
+		t.Errorf("GET /api/tenants as viewer = %+v, want both tenants", got)
+	}
+	if code := errorCode(t, s.expect(http.MethodPost, "/api/tenants", tenantBody("denied", ""), http.StatusForbidden)); code != "FORBIDDEN" {
+		t.Errorf("POST /api/tenants as viewer code = %s, want FORBIDDEN", code)
+	}
+
+	// A principal limited to one tenant sees only that tenant, and cannot make another its parent
+	token := signToken(t, map[string]interface{}{"sub": "scoped", "role": "admin", "tenants": []string{scoped}, "exp": time.Now().Add(time.Hour).Unix()}, "secret")
+	s.header = http.Header{"Authorization": {"Bearer " + token}}
+	if got := listOf(t, s.expect(http.MethodGet, "/api/tenants", "", http.StatusOK)); len(got) != 1 || got[0].Id != scoped {
+		t.Errorf("GET /api/tenants as scoped principal = %+v, want only %s", got, scoped)
+	}
+	if code := errorCode(t, s.expect(http.MethodGet, "/api/tenants/"+other, "", http.StatusForbidden)); code != "TENANT_FORBIDDEN" {
+		t.Errorf("GET /api/tenants/%s as scoped principal code = %s, want TENANT_FORBIDDEN", other, code)
+	}
+	reparent := strings.Replace(tenantBody("scoped", other), `"type":"tenants"`, `"type":"tenants","id":"`+scoped+`"`, 1)
+	if code := errorCode(t, s.expect(http.MethodPatch, "/api/tenants/"+scoped, reparent, http.StatusForbidden)); code != "TENANT_FORBIDDEN" {
+		t.Errorf("PATCH /api/tenants/%s with parent %s code = %s, want TENANT_FORBIDDEN", scoped, other, code)
+	}
+	s.expect(http.MethodPatch, "/api/tenants/"+scoped, strings.Replace(tenantBody("renamed", ""), `"type":"tenants"`, `"type":"tenants","id":"`+scoped+`"`, 1), http.StatusOK)
+}
+
+func TestTenantErrors(t *testing.T) {
+	s := newTestServer(t)
+	existing := s.createTenant("existing")
+	unknown := uuid.NewString()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "get bad uuid", method: http.MethodGet, path: "/api/tenants/not-a-uuid", status: http.StatusBadRequest, code: "INVALID_TENANT_ID"},
+		{name: "get missing", method: http.MethodGet, path: "/api/tenants/" + unknown, status: http.StatusNotFound, code: "TENANT_NOT_FOUND"},
+		{name: "get unknown include", method: http.MethodGet, path: "/api/tenants/" + existing + "?include=widgets", status: http.StatusBadRequest, code: "UNKNOWN_INCLUDE"},
+		{name: "create invalid body", method: http.MethodPost, path: "/api/tenants", body: `{"data":`, status: http.StatusBadRequest, code: "INVALID_REQUEST_BODY"},
+		{name: "create unknown parent", method: http.MethodPost, path: "/api/tenants", body: tenantBody("orphan", unknown), status: http.StatusBadRequest, code: "PARENT_NOT_FOUND"},
+		{name: "update bad uuid", method: http.MethodPatch, path: "/api/tenants/not-a-uuid", body: tenantBody("renamed", ""), status: http.StatusBadRequest, code: "INVALID_TENANT_ID"},
+		{name: "update missing", method: http.MethodPatch, path: "/api/tenants/" + unknown, body: tenantBody("renamed", ""), status: http.StatusNotFound, code: "TENANT_NOT_FOUND"},
+		{name: "update own parent", method: http.MethodPatch, path: "/api/tenants/" + existing, body: tenantBody("renamed", existing), status: http.StatusBadRequest, code: "PARENT_CYCLE"},
+		{name: "delete bad uuid", method: http.MethodDelete, path: "/api/tenants/not-a-uuid", status: http.StatusBadRequest, code: "INVALID_TENANT_ID"},
+		{name: "delete missing", method: http.MethodDelete, path: "/api/tenants/" + unknown, status: http.StatusNotFound, code: "TENANT_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.expect(tt.method, tt.path, tt.body, tt.status)
+			if code := errorCode(t, w); code != tt.code {
+				t.Errorf("%s %s code = %s, want %s", tt.method, tt.path, code, tt.code)
+			}
+		})
+	}
+	if n := len(s.events.Messages(tenant.EventTopicTenantStatus)); n != 1 {
+		t.Errorf("emitted %d tenant events, want only the CREATED event of the existing tenant", n)
+	}
+}
+
+func TestTenantSnapshots(t *testing.T) {
+	s := newTestServer(t)
+	kept := s.createTenant("kept")
+	deleted := s.createTenant("deleted")
+	s.expect(http.MethodDelete, "/api/tenants/"+deleted, "", http.StatusNoContent)
+	s.events.Reset()
+
+	got := resourceDocument(t, s.expect(http.MethodPost, "/api/admin/tenants/snapshot", "", http.StatusOK)).Data
+	if got.Type != "tenantSnapshots" || got.Attributes["published"] != float64(1) || got.Attributes["tombstones"] != float64(1) {
+		t.Errorf("POST /api/admin/tenants/snapshot = %+v, want 1 snapshot and 1 tombstone", got)
+	}
+
+	values := make(map[string][]byte)
+	for _, msg := range s.events.Messages(tenant.EventTopicTenantSnapshot) {
+		values[string(msg.Key)] = msg.Value
+	}
+	if len(values) != 2 || values[kept] == nil {
+		t.Errorf("republished snapshot messages = %v, want a snapshot of %s", values, kept)
+	}
+	if v, ok := values[deleted]; !ok || v != nil {
+		t.Errorf("republished snapshot messages = %v, want a tombstone for %s", values, deleted)
+	}
+	if n := len(s.events.Messages(tenant.EventTopicTenantStatus)); n != 0 {
+		t.Errorf("reconciliation emitted %d tenant status events, want none", n)
+	}
+}
+
+func TestTenantInclude(t *testing.T) {
+	s := newTestServer(t)
+	tenantId := s.createTenant("tenant")
+	routeId := s.createResource(tenantId, "routes", routeBody("Ellinia to Orbis Ferry", 15))
+	vesselId := s.createResource(tenantId, "vessels", vesselBody("Orbis Ferry", routeId, ""))
+
+	type includedDocument struct {
+		Data struct {
+			Relationships map[string]struct {
+				Data []resource `json:"data"`
+			} `json:"relationships"`
+		} `json:"data"`
+		Included []resource `json:"included"`
+	}
+	d := decode[includedDocument](t, s.expect(http.MethodGet, "/api/tenants/"+tenantId+"?include=routes,vessels", "", http.StatusOK))
+
+	if rs := d.Data.Relationships["routes"].Data; len(rs) != 1 || rs[0].Id != routeId {
+		t.Errorf("routes relationship = %+v, want route %s", rs, routeId)
+	}
+	if vs := d.Data.Relationships["vessels"].Data; len(vs) != 1 || vs[0].Id != vesselId {
+		t.Errorf("vessels relationship = %+v, want vessel %s", vs, vesselId)
+	}
+	if len(d.Included) != 2 {
+		t.Errorf("included = %+v, want the route and the vessel", d.Included)
+	}
+}
+
+// resourceType describes how to create and address the routes or vessels of a tenant
+type resourceType struct {
+	name     string
+	notFound string
+	body     func(s *testServer, tenantId string, name string) string
+}
+
+var resourceTypes = []resourceType{
+	{
+		name:     "routes",
+		notFound: "ROUTE_NOT_FOUND",
+		body: func(_ *testServer, _ string, name string) string {
+			return routeBody(name, 15)
+		},
+	},
+	{
+		name:     "vessels",
+		notFound: "VESSEL_NOT_FOUND",
+		body: func(s *testServer, tenantId string, name string) string {
+			routeId := s.createResource(tenantId, "routes", routeBody("route for "+name, 15))
+			return vesselBody(name, routeId, "")
+		},
+	},
+}
+
+func TestResourceLifecycle(t *testing.T) {
+	for _, rt := range resourceTypes {
+		t.Run(rt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			tenantId := s.createTenant("tenant")
+			collection := "/api/tenants/" + tenantId + "/configurations/" + rt.name
+
+			if got := listOf(t, s.expect(http.MethodGet, collection, "", http.StatusOK)); len(got) != 0 {
+				t.Fatalf("GET %s = %v, want an empty collection", collection, got)
+			}
+
+			created := resourceDocument(t, s.expect(http.MethodPost, collection, rt.body(s, tenantId, "original"), http.StatusCreated)).Data
+			if created.Id == "" || created.Type != rt.name || created.Attributes["name"] != "original" {
+				t.Fatalf("POST %s = %+v, want a %s resource with an ID", collection, created, rt.name)
+			}
+			path := collection + "/" + created.Id
+
+			if got := resourceDocument(t, s.expect(http.MethodGet, path, "", http.StatusOK)).Data; got.Id != created.Id || got.Attributes["name"] != "original" {
+				t.Errorf("GET %s = %+v, want the created resource", path, got)
+			}
+			if all := listOf(t, s.expect(http.MethodGet, collection, "", http.StatusOK)); len(all) != 1 || all[0].Id != created.Id {
+				t.Errorf("GET %s = %+v, want the created resource", collection, all)
+			}
+
+			update := strings.Replace(rt.body(s, tenantId, "renamed"), `"attributes"`, `"id":"`+created.Id+`","attributes"`, 1)
+			updated := resourceDocument(t, s.expect(http.MethodPatch, path, update, http.StatusOK)).Data
+			if updated.Id != created.Id || updated.Attributes["name"] != "renamed" {
+				t.Errorf("PATCH %s = %+v, want the renamed resource", path, updated)
+			}
+
+			w := s.expect(http.MethodDelete, path, "", http.StatusNoContent)
+			if w.Body.Len() != 0 {
+				t.Errorf("DELETE %s body = %s, want none", path, w.Body)
+			}
+			if code := errorCode(t, s.expect(http.MethodGet, path, "", http.StatusNotFound)); code != rt.notFound {
+				t.Errorf("GET %s after delete code = %s, want %s", path, code, rt.notFound)
+			}
+
+			es := kafkatest.Decode[configuration.StatusEvent[configuration.StatusEventBody]](t, s.events.Messages(configuration.EventTopicConfigurationStatus))
+			deleted := kafkatest.Single(t, es, func(e configuration.StatusEvent[configuration.StatusEventBody]) bool {
+				return e.Type == configuration.EventTypeDeleted
+			})
+			if deleted.ResourceName != rt.name || deleted.ResourceId != created.Id || deleted.TenantId.String() != tenantId {
+				t.Errorf("DELETED event = %+v, want %s [%s] of tenant %s", deleted, rt.name, created.Id, tenantId)
+			}
+		})
+	}
+}
+
+func TestResourceErrors(t *testing.T) {
+	for _, rt := range resourceTypes {
+		t.Run(rt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			tenantId := s.createTenant("tenant")
+			body := rt.body(s, tenantId, "resource")
+			collection := "/api/tenants/" + tenantId + "/configurations/" + rt.name
+			missing := collection + "/" + uuid.NewString()
+
+			tests := []struct {
+				name   string
+				method string
+				path   string
+				body   string
+				status int
+				code   string
+			}{
+				{name: "list bad uuid", method: http.MethodGet, path: "/api/tenants/not-a-uuid/configurations/" + rt.name, status: http.StatusBadRequest, code: "INVALID_TENANT_ID"},
+				{name: "get bad uuid", method: http.MethodGet, path: "/api/tenants/not-a-uuid/configurations/" + rt.name + "/id", status: http.StatusBadRequest, code: "INVALID_TENANT_ID"},
+				{name: "get missing", method: http.MethodGet, path: missing, status: http.StatusNotFound, code: rt.notFound},
+				{name: "create bad uuid", method: http.MethodPost, path: "/api/tenants/not-a-uuid/configurations/" + rt.name, body: body, status: http.StatusBadRequest, code: "INVALID_TENANT_ID"},
+				{name: "create invalid body", method: http.MethodPost, path: collection, body: `{"data":`, status: http.StatusBadRequest, code: "INVALID_REQUEST_BODY"},
+				{name: "update missing", method: http.MethodPatch, path: missing, body: body, status: http.StatusNotFound, code: rt.notFound},
+				{name: "delete bad uuid", method: http.MethodDelete, path: "/api/tenants/not-a-uuid/configurations/" + rt.name + "/id", status: http.StatusBadRequest, code: "INVALID_TENANT_ID"},
+				{name: "delete missing", method: http.MethodDelete, path: missing, status: http.StatusNotFound, code: rt.notFound},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					w := s.expect(tt.method, tt.path, tt.body, tt.status)
+					if code := errorCode(t, w); code != tt.code {
+						t.Errorf("%s %s code = %s, want %s", tt.method, tt.path, code, tt.code)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestConfigurations(t *testing.T) {
+	s := newTestServer(t)
+	tenantId := s.createTenant("tenant")
+	path := "/api/tenants/" + tenantId + "/configurations"
+
+	if got := listOf(t, s.expect(http.MethodGet, path, "", http.StatusOK)); len(got) != 0 {
+		t.Fatalf("GET %s = %v, want an empty collection", path, got)
+	}
+	s.createResource(tenantId, "routes", routeBody("first", 15))
+	s.createResource(tenantId, "routes", routeBody("second", 15))
+
+	got := listOf(t, s.expect(http.MethodGet, path, "", http.StatusOK))
+	if len(got) != 1 || got[0].Id != "routes" || got[0].Attributes["count"] != float64(2) || got[0].Attributes["revision"] != float64(2) {
+		t.Errorf("GET %s = %+v, want routes with 2 items at revision 2", path, got)
+	}
+
+	// Suppressing an inherited route stores a marker, which is not an item of the child
+	child := s.createTenant("child")
+	s.expect(http.MethodPatch, "/api/tenants/"+child, strings.Replace(tenantBody("child", tenantId), `"type":"tenants"`, `"type":"tenants","id":"`+child+`"`, 1), http.StatusOK)
+	inherited := s.createResource(tenantId, "routes", routeBody("third", 15))
+	s.createResource(child, "routes", routeBody("own", 15))
+	s.expect(http.MethodDelete, "/api/tenants/"+child+"/configurations/routes/"+inherited, "", http.StatusNoContent)
+	childPath := "/api/tenants/" + child + "/configurations"
+	if got = listOf(t, s.expect(http.MethodGet, childPath, "", http.StatusOK)); len(got) != 1 || got[0].Attributes["count"] != float64(1) {
+		t.Errorf("GET %s = %+v, want routes with 1 item", childPath, got)
+	}
+	type includedDocument struct {
+		Included []resource `json:"included"`
+	}
+	if d := decode[includedDocument](t, s.expect(http.MethodGet, childPath+"?include=routes", "", http.StatusOK)); len(d.Included) != 1 || d.Included[0].Attributes["name"] != "own" {
+		t.Errorf("GET %s?include=routes included = %+v, want only the child's own route", childPath, d.Included)
+	}
+
+	errorCode(t, s.expect(http.MethodGet, "/api/tenants/not-a-uuid/configurations", "", http.StatusBadRequest))
+	if code := errorCode(t, s.expect(http.MethodGet, path+"?include=routes,widgets", "", http.StatusBadRequest)); code != "UNKNOWN_INCLUDE" {
+		t.Errorf("GET %s?include=routes,widgets code = %s, want UNKNOWN_INCLUDE", path, code)
+	}
+	if code := errorCode(t, s.expect(http.MethodGet, "/api/tenants/"+uuid.NewString()+"/configurations", "", http.StatusNotFound)); code != "TENANT_NOT_FOUND" {
+		t.Errorf("GET configurations of an unknown tenant code = %s, want TENANT_NOT_FOUND", code)
+	}
+}
+
+func TestDiffAndPromotion(t *testing.T) {
+	s := newTestServer(t)
+	left := s.createTenant("left")
+	right := s.createTenant("right")
+	s.createResource(left, "routes", routeBody("Ellinia to Orbis Ferry", 15))
+	s.createResource(right, "routes", routeBody("Ellinia to Orbis Ferry", 10))
+
+	type change struct {
+		Differences []struct {
+			Attribute string      `json:"attribute"`
+			Left      interface{} `json:"left"`
+			Right     interface{} `json:"right"`
+		} `json:"differences"`
+	}
+	changes := func(r resource) []change {
+		b, _ := json.Marshal(r.Attributes["changed"])
+		var cs []change
+		_ = json.Unmarshal(b, &cs)
+		return cs
+	}
+
+	diff := listOf(t, s.expect(http.MethodGet, "/api/configurations/diff?left="+left+"&right="+right, "", http.StatusOK))
+	if len(diff) != 1 || diff[0].Id != "routes" {
+		t.Fatalf("diff = %+v, want the routes resource type", diff)
+	}
+	if cs := changes(diff[0]); len(cs) != 1 || len(cs[0].Differences) != 1 || cs[0].Differences[0].Attribute != "travelDuration" {
+		t.Errorf("diff changes = %+v, want travelDuration", cs)
+	}
+	errorCode(t, s.expect(http.MethodGet, "/api/configurations/diff?left=not-a-uuid&right="+right, "", http.StatusBadRequest))
+	for _, path := range []string{"/api/configurations/diff?left=" + uuid.NewString() + "&right=" + right, "/api/configurations/diff?left=" + left + "&right=" + uuid.NewString()} {
+		if code := errorCode(t, s.expect(http.MethodGet, path, "", http.StatusNotFound)); code != "TENANT_NOT_FOUND" {
+			t.Errorf("GET %s code = %s, want TENANT_NOT_FOUND", path, code)
+		}
+	}
+	if code := errorCode(t, s.expect(http.MethodGet, "/api/configurations/diff?left="+left+"&right="+right+"&resource=routes,ships", "", http.StatusBadRequest)); code != "UNKNOWN_RESOURCE" {
+		t.Errorf("diff of an unknown resource code = %s, want UNKNOWN_RESOURCE", code)
+	}
+
+	promote := "/api/tenants/" + left + "/configurations/promote?from=" + right + "&resources=routes"
+	dryRun := listOf(t, s.expect(http.MethodPost, promote+"&dryRun=true", "", http.StatusOK))
+	if len(dryRun) != 1 || len(changes(dryRun[0])) != 1 || dryRun[0].Attributes["dryRun"] != true {
+		t.Errorf("dry run promotion = %+v, want one changed route", dryRun)
+	}
+	if n := len(s.events.Messages(configuration.EventTopicConfigurationStatus)); n != 2 {
+		t.Errorf("dry run emitted events, have %d, want only the 2 CREATED events", n)
+	}
+
+	s.expect(http.MethodPost, promote, "", http.StatusOK)
+	after := listOf(t, s.expect(http.MethodGet, "/api/configurations/diff?left="+left+"&right="+right, "", http.StatusOK))
+	if len(after) != 1 || len(changes(after[0])) != 0 {
+		t.Errorf("diff after promotion = %+v, want no changes", after)
+	}
+	errorCode(t, s.expect(http.MethodPost, "/api/tenants/"+left+"/configurations/promote?from="+left, "", http.StatusBadRequest))
+
+	// A promotion between unknown tenants leaves the configuration untouched
+	for _, path := range []string{"/api/tenants/" + left + "/configurations/promote?from=" + uuid.NewString(), "/api/tenants/" + uuid.NewString() + "/configurations/promote?from=" + right} {
+		if code := errorCode(t, s.expect(http.MethodPost, path, "", http.StatusNotFound)); code != "TENANT_NOT_FOUND" {
+			t.Errorf("POST %s code = %s, want TENANT_NOT_FOUND", path, code)
+		}
+	}
+	if got := listOf(t, s.expect(http.MethodGet, "/api/tenants/"+left+"/configurations/routes", "", http.StatusOK)); len(got) != 1 {
+		t.Errorf("routes after failed promotions = %+v, want the promoted route", got)
+	}
+}
+
+func TestDiffLeavesOutSuppressions(t *testing.T) {
+	s := newTestServer(t)
+	parent := s.createTenant("parent")
+	child := resourceDocument(t, s.expect(http.MethodPost, "/api/tenants", tenantBody("child", parent), http.StatusCreated)).Data.Id
+	other := s.createTenant("other")
+	suppressed := s.createResource(parent, "routes", routeBody("suppressed", 15))
+	s.expect(http.MethodDelete, "/api/tenants/"+child+"/configurations/routes/"+suppressed, "", http.StatusNoContent)
+
+	// The child holds only the marker suppressing the parent's route, which is not a route of the child
+	diff := listOf(t, s.expect(http.MethodGet, "/api/configurations/diff?left="+child+"&right="+other+"&resource=routes", "", http.StatusOK))
+	if len(diff) != 1 || len(diff[0].Attributes["removed"].([]interface{})) != 0 || len(diff[0].Attributes["added"].([]interface{})) != 0 {
+		t.Errorf("diff = %+v, want no routes removed or added", diff)
+	}
+}
+
+func TestPromotionFromChild(t *testing.T) {
+	s := newTestServer(t)
+	parent := s.createTenant("parent")
+	child := s.createTenant("child")
+	target := s.createTenant("target")
+	s.expect(http.MethodPatch, "/api/tenants/"+child, strings.Replace(tenantBody("child", parent), `"type":"tenants"`, `"type":"tenants","id":"`+child+`"`, 1), http.StatusOK)
+	inherited := s.createResource(parent, "routes", routeBody("inherited", 15))
+	suppressed := s.createResource(parent, "routes", routeBody("suppressed", 15))
+	own := s.createResource(child, "routes", routeBody("own", 10))
+	s.expect(http.MethodDelete, "/api/tenants/"+child+"/configurations/routes/"+suppressed, "", http.StatusNoContent)
+
+	// The child's resolved routes are promoted, without the marker suppressing the parent's route
+	s.expect(http.MethodPost, "/api/tenants/"+target+"/configurations/promote?from="+child+"&resources=routes", "", http.StatusOK)
+	got := make(map[string]bool)
+	for _, r := range listOf(t, s.expect(http.MethodGet, "/api/tenants/"+target+"/configurations/routes", "", http.StatusOK)) {
+		got[r.Id] = true
+	}
+	if want := map[string]bool{inherited: true, own: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("promoted routes = %v, want the inherited %s and own %s", got, inherited, own)
+	}
+}
+
+func TestReplaceConfigurationsRevisionConflict(t *testing.T) {
+	s := newTestServer(t)
+	tenantId := s.createTenant("tenant")
+	s.createResource(tenantId, "routes", routeBody("first", 15))
+	id := uuid.MustParse(tenantId)
+	routes := []map[string]interface{}{{"type": "routes", "id": uuid.NewString(), "attributes": map[string]interface{}{"name": "replaced"}}}
+
+	// The routes were written after being read as absent, and again after being read at their first revision
+	for _, revision := range []uint32{0, 2} {
+		err := configuration.ReplaceConfigurations(s.db, id, map[string]configuration.Replacement{"routes": {Revision: revision, Resources: routes}})
+		if !errors.Is(err, configuration.ErrRevisionConflict) {
+			t.Errorf("ReplaceConfigurations() at revision %d error = %v, want %v", revision, err, configuration.ErrRevisionConflict)
+		}
+	}
+	if err := configuration.ReplaceConfigurations(s.db, id, map[string]configuration.Replacement{"routes": {Revision: 1, Resources: routes}}); err != nil {
+		t.Fatalf("ReplaceConfigurations() at the stored revision error = %v", err)
+	}
+	if got := listOf(t, s.expect(http.MethodGet, "/api/tenants/"+tenantId+"/configurations/routes", "", http.StatusOK)); len(got) != 1 || got[0].Attributes["name"] != "replaced" {
+		t.Errorf("routes after replacement = %+v, want only the replacement", got)
+	}
+}
+
+func TestCreateConfigurationDuplicate(t *testing.T) {
+	s := newTestServer(t)
+	id := uuid.MustParse(s.createTenant("tenant"))
+	e := configuration.Entity{TenantID: id, ResourceName: "routes", ResourceData: database.JSON(`{"data":[]}`)}
+
+	// A second configuration of the resource is one created concurrently after the first was read as absent
+	e.ID = uuid.New()
+	if _, err := configuration.CreateConfiguration(s.db, e); err != nil {
+		t.Fatalf("CreateConfiguration() error = %v", err)
+	}
+	e.ID = uuid.New()
+	if _, err := configuration.CreateConfiguration(s.db, e); !errors.Is(err, configuration.ErrRevisionConflict) {
+		t.Errorf("CreateConfiguration() of a duplicate error = %v, want %v", err, configuration.ErrRevisionConflict)
+	}
+}
+
+func TestEventReplay(t *testing.T) {
+	s := newTestServer(t)
+	child := resourceDocument(t, s.expect(http.MethodPost, "/api/tenants", tenantBody("b-child", ""), http.StatusCreated)).Data.Id
+	parent := s.createTenant("c-parent")
+	routeId := s.createResource(parent, "routes", routeBody("Ellinia to Orbis Ferry", 15))
+	suppressedId := s.createResource(parent, "routes", routeBody("Orbis to Leafre", 20))
+	vesselId := s.createResource(parent, "vessels", vesselBody("Orbis Ferry", routeId, ""))
+	// The child sorts before its parent by name, so replaying it after the parent shows the parent order is kept
+	s.expect(http.MethodPatch, "/api/tenants/"+child, strings.Replace(tenantBody("b-child", parent), `"type":"tenants"`, `"type":"tenants","id":"`+child+`"`, 1), http.StatusOK)
+	localRouteId := s.createResource(child, "routes", routeBody("Orbis to Ludibrium", 10))
+	s.expect(http.MethodDelete, "/api/tenants/"+child+"/configurations/routes/"+suppressedId, "", http.StatusNoContent)
+	s.events.Reset()
+
+	started := resourceDocument(t, s.expect(http.MethodPost, "/api/admin/events/replay", "", http.StatusAccepted)).Data
+	if started.Type != "eventReplays" || started.Attributes["status"] != replay.StatusRunning || started.Attributes["tenantId"] != nil {
+		t.Errorf("POST /api/admin/events/replay = %+v, want a running replay of every tenant", started)
+	}
+	done := s.awaitReplay(started.Id)
+	wantEmitted := map[string]interface{}{"tenant": float64(2), "routes": float64(4), "vessels": float64(2)}
+	if done.Attributes["status"] != replay.StatusCompleted || done.Attributes["tenantsReplayed"] != float64(2) || !reflect.DeepEqual(done.Attributes["emitted"], wantEmitted) {
+		t.Errorf("finished replay = %+v, want 2 tenants replayed with their resolved resources", done)
+	}
+
+	es := kafkatest.Decode[tenant.Event[tenant.StatusEventBody]](t, s.events.Messages(tenant.EventTopicTenantStatus))
+	if len(es) != 2 || es[0].TenantId.String() != parent || es[1].TenantId.String() != child || es[0].Type != tenant.EventTypeCreated {
+		t.Errorf("replayed tenant events = %+v, want CREATED events of the parent and then the child", es)
+	}
+	cs := kafkatest.Decode[configuration.StatusEvent[configuration.StatusEventBody]](t, s.events.Messages(configuration.EventTopicConfigurationStatus))
+	var got []string
+	for _, e := range cs {
+		got = append(got, e.TenantId.String()+"/"+e.ResourceName+"/"+e.ResourceId)
+	}
+	// The child replays the route it inherits and its own route, but not the route it suppresses
+	want := []string{
+		parent + "/routes/" + routeId, parent + "/routes/" + suppressedId, parent + "/vessels/" + vesselId,
+		child + "/routes/" + routeId, child + "/routes/" + localRouteId, child + "/vessels/" + vesselId,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed configuration events = %v, want %v", got, want)
+	}
+	for _, e := range cs {
+		if e.Type != configuration.EventTypeCreated {
+			t.Errorf("replayed %s event of %s, want only CREATED events", e.Type, e.ResourceId)
+		}
+	}
+
+	s.events.Reset()
+	one := resourceDocument(t, s.expect(http.MethodPost, "/api/admin/events/replay?tenantId="+child+"&types=routes", "", http.StatusAccepted)).Data
+	if done = s.awaitReplay(one.Id); done.Attributes["tenantId"] != child || done.Attributes["tenantCount"] != float64(1) {
+		t.Errorf("replay of %s = %+v, want only that tenant", child, done)
+	}
+	if n, m := len(s.events.Messages(tenant.EventTopicTenantStatus)), len(s.events.Messages(configuration.EventTopicConfigurationStatus)); n != 0 || m != 2 {
+		t.Errorf("replay of routes emitted %d tenant and %d configuration events, want 0 and 2", n, m)
+	}
+}
+
+func TestEventReplayErrors(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{name: "unknown type", method: http.MethodPost, path: "/api/admin/events/replay?types=tenant,widgets", status: http.StatusBadRequest, code: "UNKNOWN_REPLAY_TYPE"},
+		{name: "bad tenant uuid", method: http.MethodPost, path: "/api/admin/events/replay?tenantId=not-a-uuid", status: http.StatusBadRequest, code: "INVALID_TENANT_ID"},
+		{name: "unknown tenant", method: http.MethodPost, path: "/api/admin/events/replay?tenantId=" + uuid.NewString(), status: http.StatusNotFound, code: "TENANT_NOT_FOUND"},
+		{name: "unknown replay", method: http.MethodGet, path: "/api/admin/events/replay/" + uuid.NewString(), status: http.StatusNotFound, code: "REPLAY_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := errorCode(t, s.expect(tt.method, tt.path, "", tt.status)); code != tt.code {
+				t.Errorf("%s %s code = %s, want %s", tt.method, tt.path, code, tt.code)
+			}
+		})
+	}
+}
+
+func TestEventReplayCancelled(t *testing.T) {
+	t.Setenv("EVENT_REPLAY_RATE", "1")
+	s := newTestServer(t)
+	for _, name := range []string{"first", "second", "third"} {
+		s.createTenant(name)
+	}
+
+	started := resourceDocument(t, s.expect(http.MethodPost, "/api/admin/events/replay?types=tenant", "", http.StatusAccepted)).Data
+	s.shutdown()
+
+	done := resourceDocument(t, s.expect(http.MethodGet, "/api/admin/events/replay/"+started.Id, "", http.StatusOK)).Data
+	if done.Attributes["status"] != replay.StatusCancelled || done.Attributes["finishedAt"] == nil || done.Attributes["tenantsReplayed"] == float64(3) {
+		t.Errorf("replay after shutdown = %+v, want it cancelled before every tenant was replayed", done)
+	}
+}
+
+// postWithKey serves a POST carrying an idempotency key
+func (s *testServer) postWithKey(path string, body string, key string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	header := s.header
+	defer func() { s.header = header }()
+	s.header = header.Clone()
+	if s.header == nil {
+		s.header = http.Header{}
+	}
+	s.header.Set(idempotency.Header, key)
+	return s.do(http.MethodPost, path, body)
+}
+
+// failWrites makes inserts and updates of the table fail with the error until the returned function is called
+func (s *testServer) failWrites(table string, err error) func() {
+	s.t.Helper()
+	name := "fail_" + table
+	fail := func(db *gorm.DB) {
+		if db.Statement.Table == table {
+			_ = db.AddError(err)
+		}
+	}
+	if cerr := s.db.Callback().Create().Before("gorm:create").Register(name, fail); cerr != nil {
+		s.t.Fatalf("unable to register callback: %v", cerr)
+	}
+	if cerr := s.db.Callback().Update().Before("gorm:update").Register(name, fail); cerr != nil {
+		s.t.Fatalf("unable to register callback: %v", cerr)
+	}
+	return func() {
+		_ = s.db.Callback().Create().Remove(name)
+		_ = s.db.Callback().Update().Remove(name)
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		setup func(s *testServer) (path string, body func(name string) string)
+	}{
+		{name: "tenants", table: "tenants", setup: func(s *testServer) (string, func(string) string) {
+			return "/api/tenants", func(name string) string { return tenantBody(name, "") }
+		}},
+		{name: "routes", table: "configurations", setup: func(s *testServer) (string, func(string) string) {
+			tenantId := s.createTenant("tenant")
+			return "/api/tenants/" + tenantId + "/configurations/routes", func(name string) string { return routeBody(name, 15) }
+		}},
+		{name: "vessels", table: "configurations", setup: func(s *testServer) (string, func(string) string) {
+			tenantId := s.createTenant("tenant")
+			routeA := s.createResource(tenantId, "routes", routeBody("a", 15))
+			routeB := s.createResource(tenantId, "routes", routeBody("b", 15))
+			return "/api/tenants/" + tenantId + "/configurations/vessels", func(name string) string { return vesselBody(name, routeA, routeB) }
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			path, body := tt.setup(s)
+			count := func() int {
+				t.Helper()
+				return len(listOf(t, s.expect(http.MethodGet, path, "", http.StatusOK)))
+			}
+			before := count()
+
+			// A repeated request receives the stored response without creating another resource
+			first := s.postWithKey(path, body("first"), "replayed")
+			if first.Code != http.StatusCreated || first.Header().Get(idempotency.ReplayedHeader) != "" {
+				t.Fatalf("first request returned %d, replayed %q: %s", first.Code, first.Header().Get(idempotency.ReplayedHeader), first.Body)
+			}
+			second := s.postWithKey(path, body("first"), "replayed")
+			if second.Code != http.StatusCreated || second.Header().Get(idempotency.ReplayedHeader) != "true" {
+				t.Fatalf("repeated request returned %d, replayed %q: %s", second.Code, second.Header().Get(idempotency.ReplayedHeader), second.Body)
+			}
+			if second.Body.String() != first.Body.String() || second.Header().Get("Location") != first.Header().Get("Location") {
+				t.Errorf("repeated response = %s at %s, want %s at %s", second.Body, second.Header().Get("Location"), first.Body, first.Header().Get("Location"))
+			}
+			if got := count(); got != before+1 {
+				t.Errorf("%d resources after a repeated request, want %d", got, before+1)
+			}
+
+			// A different request with the key is rejected
+			if w := s.postWithKey(path, body("other"), "replayed"); w.Code != http.StatusUnprocessableEntity || errorCode(t, w) != "IDEMPOTENCY_KEY_REUSED" {
+				t.Errorf("request reusing the key returned %d: %s", w.Code, w.Body)
+			}
+
+			// A request whose key is held by one in progress is rejected
+			hash := sha256.Sum256([]byte(http.MethodPost + " " + path + "\n" + body("pending")))
+			if _, err := idempotency.ReserveKey(s.db, idempotency.Subject(auth.NewBuilder("anonymous").SetMethod(auth.MethodNone).Build()), "pending", hex.EncodeToString(hash[:]), time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("ReserveKey() error = %v", err)
+			}
+			if w := s.postWithKey(path, body("pending"), "pending"); w.Code != http.StatusConflict || errorCode(t, w) != "IDEMPOTENCY_KEY_IN_PROGRESS" {
+				t.Errorf("request with a key in progress returned %d: %s", w.Code, w.Body)
+			}
+
+			// The key of a failed or abandoned request is released, so the request may be retried
+			for _, failure := range []struct {
+				err    error
+				status int
+			}{
+				{err: errors.New("database unavailable"), status: http.StatusInternalServerError},
+				{err: context.Canceled, status: rest.StatusClientClosedRequest},
+			} {
+				key := fmt.Sprintf("failed-%d", failure.status)
+				restore := s.failWrites(tt.table, failure.err)
+				w := s.postWithKey(path, body(key), key)
+				restore()
+				if w.Code != failure.status {
+					t.Fatalf("failing request returned %d, want %d: %s", w.Code, failure.status, w.Body)
+				}
+				if w = s.postWithKey(path, body(key), key); w.Code != http.StatusCreated || w.Header().Get(idempotency.ReplayedHeader) != "" {
+					t.Errorf("retry after %d returned %d, replayed %q: %s", failure.status, w.Code, w.Header().Get(idempotency.ReplayedHeader), w.Body)
+				}
+			}
+
+			// An expired key may be used for another request
+			t.Setenv("IDEMPOTENCY_KEY_TTL", "1ms")
+			if w := s.postWithKey(path, body("expiring"), "expiring"); w.Code != http.StatusCreated {
+				t.Fatalf("request with an expiring key returned %d: %s", w.Code, w.Body)
+			}
+			time.Sleep(10 * time.Millisecond)
+			if w := s.postWithKey(path, body("after expiry"), "expiring"); w.Code != http.StatusCreated || w.Header().Get(idempotency.ReplayedHeader) != "" {
+				t.Errorf("request with an expired key returned %d, replayed %q: %s", w.Code, w.Header().Get(idempotency.ReplayedHeader), w.Body)
+			}
+		})
+	}
+}
+
+func TestTenantMetrics(t *testing.T) {
+	s := newTestServer(t)
+	parent := s.createTenant("parent")
+	s.createResource(parent, "routes", routeBody("first", 15))
+	child := resourceDocument(t, s.expect(http.MethodPost, "/api/tenants", tenantBody("child", parent), http.StatusCreated)).Data.Id
+	s.createResource(child, "routes", routeBody("second", 15))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	l, _ := test.NewNullLogger()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(tenant.NewCollector(l, ctx, wg, s.db, time.Hour))
+
+	// gauges gathers the values of the collector's gauges, keyed by name and label values
+	gauges := func() map[string]float64 {
+		t.Helper()
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Gather() error = %v", err)
+		}
+		results := make(map[string]float64)
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				key := mf.GetName()
+				for _, lp := range m.GetLabel() {
+					key += " " + lp.GetValue()
+				}
+				results[key] = m.GetGauge().GetValue()
+			}
+		}
+		return results
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	got := gauges()
+	for len(got) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("collector did not refresh")
+		}
+		time.Sleep(10 * time.Millisecond)
+		got = gauges()
+	}
+
+	// The child resolves the route it inherits and its own
+	want := map[string]float64{
+		"atlas_tenants_tenants":                                   2,
+		"atlas_tenants_configuration_resources routes " + parent:  1,
+		"atlas_tenants_configuration_resources vessels " + parent: 0,
+		"atlas_tenants_configuration_resources routes " + child:   2,
+		"atlas_tenants_configuration_resources vessels " + child:  0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("gauges = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckTimeout bounds how long a single readiness check may take
+const CheckTimeout = 2 * time.Second
+
+// ErrDraining is reported while the service is shutting down
+var ErrDraining = errors.New("service is shutting down")
+
+// Check reports an error when a dependency of the service is not usable
+type Check func(ctx context.Context) error
+
+// Result is the outcome of a named check
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Checker evaluates the readiness of the service
+type Checker struct {
+	mu       sync.RWMutex
+	names    []string
+	checks   map[string]Check
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker without any checks
+func NewChecker() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+// AddCheck adds or replaces a named check
+func (c *Checker) AddCheck(name string, check Check) *Checker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+	return c
+}
+
+// Drain marks the service not ready, regardless of its checks
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs every check in parallel, and returns true when the service is not draining and every check passed
+func (c *Checker) Ready(ctx context.Context) (bool, []Result) {
+	c.mu.RLock()
+	names := append([]string{}, c.names...)
+	checks := make([]Check, len(names))
+	for i, n := range names {
+		checks[i] = c.checks[n]
+	}
+	c.mu.RUnlock()
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+			defer cancel()
+			results[i] = Result{Name: names[i], Err: checks[i](cctx)}
+		}(i)
+	}
+	wg.Wait()
+
+	ready := !c.draining.Load()
+	for _, r := range results {
+		if r.Err != nil {
+			ready = false
+		}
+	}
+	if c.draining.Load() {
+		results = append(results, Result{Name: "teardown", Err: ErrDraining})
+	}
+	return ready, results
+}
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReady(t *testing.T) {
+	failed := errors.New("unreachable")
+	tests := []struct {
+		name        string
+		checks      map[string]Check
+		drain       bool
+		wantReady   bool
+		wantResults []Result
+	}{
+		{name: "no checks", wantReady: true, wantResults: []Result{}},
+		{name: "passing", checks: map[string]Check{"database": func(context.Context) error { return nil }}, wantReady: true, wantResults: []Result{{Name: "database"}}},
+		{name: "failing", checks: map[string]Check{"kafka": func(context.Context) error { return failed }}, wantResults: []Result{{Name: "kafka", Err: failed}}},
+		{name: "draining", checks: map[string]Check{"database": func(context.Context) error { return nil }}, drain: true, wantResults: []Result{{Name: "database"}, {Name: "teardown", Err: ErrDraining}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker()
+			for name, check := range tt.checks {
+				c.AddCheck(name, check)
+			}
+			if tt.drain {
+				c.Drain()
+			}
+
+			ready, results := c.Ready(context.Background())
+			if ready != tt.wantReady || !reflect.DeepEqual(results, tt.wantResults) {
+				t.Errorf("Ready() = %t, %v, want %t, %v", ready, results, tt.wantReady, tt.wantResults)
+			}
+		})
+	}
+}
+
+func TestReadyBoundsChecks(t *testing.T) {
+	c := NewChecker().AddCheck("slow", func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("check has no deadline")
+		}
+		return nil
+	})
+	if ready, results := c.Ready(context.Background()); !ready {
+		t.Errorf("Ready() = %t, %v, want every check given a deadline", ready, results)
+	}
+}
+
+func TestAddCheckReplaces(t *testing.T) {
+	c := NewChecker().
+		AddCheck("database", func(context.Context) error { return ErrPending }).
+		AddCheck("kafka", func(context.Context) error { return nil }).
+		AddCheck("database", func(context.Context) error { return nil })
+
+	ready, results := c.Ready(context.Background())
+	if want := []Result{{Name: "database"}, {Name: "kafka"}}; !ready || !reflect.DeepEqual(results, want) {
+		t.Errorf("Ready() = %t, %v, want %v in the order the checks were first added", ready, results, want)
+	}
+}
+
+func TestFlag(t *testing.T) {
+	f := &Flag{}
+	if err := f.Check(context.Background()); !errors.Is(err, ErrPending) {
+		t.Errorf("Check() before Set() error = %v, want %v", err, ErrPending)
+	}
+	f.Set()
+	if err := f.Check(context.Background()); err != nil {
+		t.Errorf("Check() after Set() error = %v", err)
+	}
+}
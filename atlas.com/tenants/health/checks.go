@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrPending is reported by a Flag which has not been set
+var ErrPending = errors.New("pending")
+
+// ErrNoBrokers is reported when no Kafka broker is configured
+var ErrNoBrokers = errors.New("no kafka brokers configured")
+
+// Flag is a check which passes once set, used for a step with no dependency to probe, such as the service finishing
+// its startup
+type Flag struct {
+	set atomic.Bool
+}
+
+// Set marks the step complete
+func (f *Flag) Set() {
+	f.set.Store(true)
+}
+
+// Check passes once the flag is set
+func (f *Flag) Check(_ context.Context) error {
+	if !f.set.Load() {
+		return ErrPending
+	}
+	return nil
+}
+
+// DatabaseCheck pings the database through its connection pool
+func DatabaseCheck(db *gorm.DB) Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// KafkaCheck passes when at least one of the brokers accepts a connection
+func KafkaCheck(brokers []string) Check {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, b := range brokers {
+			for _, addr := range strings.Split(b, ",") {
+				addr = strings.TrimSpace(addr)
+				if addr == "" {
+					continue
+				}
+				conn, err := kafka.DialContext(ctx, "tcp", addr)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				_ = conn.Close()
+				return nil
+			}
+		}
+		if len(errs) == 0 {
+			return ErrNoBrokers
+		}
+		return errors.Join(errs...)
+	}
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultPort is the port the health server listens on when HEALTH_PORT is not set
+const DefaultPort = "8081"
+
+type checkRestModel struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type statusRestModel struct {
+	Status string                    `json:"status"`
+	Checks map[string]checkRestModel `json:"checks,omitempty"`
+}
+
+// Port returns the health server port from HEALTH_PORT
+func Port() string {
+	if p, ok := os.LookupEnv("HEALTH_PORT"); ok && p != "" {
+		return p
+	}
+	return DefaultPort
+}
+
+// Handler serves /health/live and /health/ready. Other operational endpoints may be added to the returned mux.
+func Handler(c *Checker) *http.ServeMux {
+	m := http.NewServeMux()
+	m.HandleFunc("GET /health/live", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, statusRestModel{Status: "live"})
+	})
+	m.HandleFunc("GET /health/ready", func(w http.ResponseWriter, r *http.Request) {
+		ready, results := c.Ready(r.Context())
+		rm := statusRestModel{Status: "ready", Checks: make(map[string]checkRestModel)}
+		for _, res := range results {
+			if res.Err != nil {
+				rm.Checks[res.Name] = checkRestModel{Status: "failed", Error: res.Err.Error()}
+				continue
+			}
+			rm.Checks[res.Name] = checkRestModel{Status: "ok"}
+		}
+		if !ready {
+			rm.Status = "not ready"
+			writeStatus(w, http.StatusServiceUnavailable, rm)
+			return
+		}
+		writeStatus(w, http.StatusOK, rm)
+	})
+	return m
+}
+
+func writeStatus(w http.ResponseWriter, status int, rm statusRestModel) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(rm)
+}
+
+// Serve runs the health server until the context is cancelled
+func Serve(l logrus.FieldLogger, ctx context.Context, wg *sync.WaitGroup, port string, h http.Handler) {
+	s := &http.Server{Addr: ":" + port, Handler: h, ReadHeaderTimeout: 5 * time.Second}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		l.Infof("Starting health server on port %s.", port)
+		if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.WithError(err).Error("Health server stopped unexpectedly.")
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Shutdown(sctx); err != nil {
+			l.WithError(err).Error("Unable to shut down health server.")
+		}
+		l.Infoln("Health server shut down.")
+	}()
+}
@@ -0,0 +1,77 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		checker    func() *Checker
+		wantStatus int
+		wantBody   statusRestModel
+	}{
+		{name: "live", path: "/health/live", checker: NewChecker, wantStatus: http.StatusOK, wantBody: statusRestModel{Status: "live"}},
+		{name: "live while draining", path: "/health/live", checker: func() *Checker {
+			c := NewChecker()
+			c.Drain()
+			return c
+		}, wantStatus: http.StatusOK, wantBody: statusRestModel{Status: "live"}},
+		{name: "ready", path: "/health/ready", checker: func() *Checker {
+			return NewChecker().AddCheck("database", func(context.Context) error { return nil })
+		}, wantStatus: http.StatusOK, wantBody: statusRestModel{Status: "ready", Checks: map[string]checkRestModel{"database": {Status: "ok"}}}},
+		{name: "not ready", path: "/health/ready", checker: func() *Checker {
+			return NewChecker().
+				AddCheck("startup", (&Flag{}).Check).
+				AddCheck("kafka", func(context.Context) error { return errors.New("unreachable") })
+		}, wantStatus: http.StatusServiceUnavailable, wantBody: statusRestModel{Status: "not ready", Checks: map[string]checkRestModel{
+			"startup": {Status: "failed", Error: ErrPending.Error()},
+			"kafka":   {Status: "failed", Error: "unreachable"},
+		}}},
+		{name: "draining", path: "/health/ready", checker: func() *Checker {
+			c := NewChecker()
+			c.Drain()
+			return c
+		}, wantStatus: http.StatusServiceUnavailable, wantBody: statusRestModel{Status: "not ready", Checks: map[string]checkRestModel{
+			"teardown": {Status: "failed", Error: ErrDraining.Error()},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Handler(tt.checker()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %s, want application/json", ct)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("Cache-Control = %s, want no-store", cc)
+			}
+			var got statusRestModel
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("unable to decode body [%s]: %v", w.Body, err)
+			}
+			if !reflect.DeepEqual(got, tt.wantBody) {
+				t.Errorf("body = %+v, want %+v", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandlerRejectsOtherMethods(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler(NewChecker()).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/health/ready", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,56 @@
+package idempotency
+
+import (
+	"atlas-tenants/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ReserveKey records that a request with the key is in progress. It returns false if the key is already recorded.
+// Expired records are removed first, so an expired key may be reused.
+func ReserveKey(db *gorm.DB, subject string, key string, requestHash string, expiresAt time.Time) (bool, error) {
+	reserved := false
+	err := database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+		err := tx.Where("expires_at < ?", time.Now()).Delete(&Entity{}).Error
+		if err != nil {
+			return err
+		}
+
+		e := Entity{
+			Subject:     subject,
+			Key:         key,
+			RequestHash: requestHash,
+			ExpiresAt:   expiresAt,
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&e)
+		if res.Error != nil {
+			return res.Error
+		}
+		reserved = res.RowsAffected == 1
+		return nil
+	})
+	return reserved, err
+}
+
+// CompleteKey stores the response produced for the request made with the key
+func CompleteKey(db *gorm.DB, subject string, key string, statusCode int, contentType string, location string, body []byte) error {
+	return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+		return tx.Model(&Entity{}).
+			Where("subject = ? AND key = ?", subject, key).
+			Updates(map[string]interface{}{
+				"completed":     true,
+				"status_code":   statusCode,
+				"content_type":  contentType,
+				"location":      location,
+				"response_body": body,
+			}).Error
+	})
+}
+
+// ReleaseKey removes the record of the key, so the request may be retried
+func ReleaseKey(db *gorm.DB, subject string, key string) error {
+	return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+		return tx.Where("subject = ? AND key = ?", subject, key).Delete(&Entity{}).Error
+	})
+}
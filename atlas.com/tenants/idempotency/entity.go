@@ -0,0 +1,24 @@
+package idempotency
+
+import (
+	"time"
+)
+
+// Entity represents a request made with an idempotency key, and the response it produced
+type Entity struct {
+	Subject      string `gorm:"primaryKey"`
+	Key          string `gorm:"primaryKey"`
+	RequestHash  string `gorm:"not null"`
+	Completed    bool   `gorm:"not null;default:false"`
+	StatusCode   int
+	ContentType  string
+	Location     string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
+
+// TableName overrides the table name
+func (Entity) TableName() string {
+	return "idempotency_keys"
+}
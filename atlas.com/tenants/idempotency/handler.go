@@ -0,0 +1,180 @@
+package idempotency
+
+import (
+	"atlas-tenants/auth"
+	"atlas-tenants/domain"
+	"atlas-tenants/rest"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	// Header is the request header carrying the idempotency key
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from an earlier request
+	ReplayedHeader = "Idempotent-Replayed"
+	// DefaultTTL is how long keys are kept when IDEMPOTENCY_KEY_TTL is not set
+	DefaultTTL   = 24 * time.Hour
+	maxKeyLength = 255
+)
+
+var (
+	// ErrKeyReused is returned when a key is reused with a different request
+	ErrKeyReused = domain.Unprocessable("IDEMPOTENCY_KEY_REUSED", "idempotency key was already used for a different request")
+	// ErrKeyInProgress is returned when a key is reused while the original request is still being processed
+	ErrKeyInProgress = domain.Conflict("IDEMPOTENCY_KEY_IN_PROGRESS", "a request with the idempotency key is in progress")
+	// ErrKeyTooLong is returned when a key exceeds the maximum length
+	ErrKeyTooLong = domain.Validation("IDEMPOTENCY_KEY_TOO_LONG", "idempotency key exceeds 255 characters")
+)
+
+// Subject returns the subject a principal's idempotency keys are recorded under. It is qualified by the authentication
+// method, so principals authenticated differently, and the consumers recording commands, never share keys.
+func Subject(p auth.Principal) string {
+	return p.Method() + ":" + p.Subject()
+}
+
+// TTL returns how long idempotency keys are kept, from the IDEMPOTENCY_KEY_TTL environment variable
+func TTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultTTL
+}
+
+// Handler returns a decorator which makes an input handler idempotent for requests carrying an Idempotency-Key
+// header. The first request with a key is processed and its response stored. Later requests with the key and the same
+// method, path and body receive the stored response. Keys are scoped to the authenticated principal.
+func Handler[M any](db *gorm.DB) func(next rest.InputHandler[M]) rest.InputHandler[M] {
+	return func(next rest.InputHandler[M]) rest.InputHandler[M] {
+		return func(d *rest.HandlerDependency, c *rest.HandlerContext, model M) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				key := r.Header.Get(Header)
+				if key == "" {
+					next(d, c, model)(w, r)
+					return
+				}
+				if len(key) > maxKeyLength {
+					rest.WriteError(d.Logger())(w)(ErrKeyTooLong)
+					return
+				}
+
+				subject := Subject(d.Principal())
+				l := d.Logger().WithField("idempotencyKey", key)
+
+				requestHash, err := hashRequest(r)
+				if err != nil {
+					rest.WriteError(l)(w)(rest.ErrInvalidRequestBody.Wrap(err))
+					return
+				}
+
+				tx := db.WithContext(d.Context())
+				reserved, err := ReserveKey(tx, subject, key, requestHash, time.Now().Add(TTL()))
+				if err != nil {
+					l.WithError(err).Error("Unable to reserve idempotency key.")
+					rest.WriteError(l)(w)(err)
+					return
+				}
+				if !reserved {
+					e, err := GetByKeyProvider(subject, key)(tx)()
+					if err != nil {
+						l.WithError(err).Error("Unable to retrieve idempotency key.")
+						rest.WriteError(l)(w)(err)
+						return
+					}
+					if e.RequestHash != requestHash {
+						rest.WriteError(l)(w)(ErrKeyReused)
+						return
+					}
+					if !e.Completed {
+						rest.WriteError(l)(w)(ErrKeyInProgress)
+						return
+					}
+					l.Debug("Replaying response for idempotency key.")
+					replay(w, e)
+					return
+				}
+
+				rec := &recorder{ResponseWriter: w}
+				next(d, c, model)(rec, r)
+
+				// The outcome is recorded even when the request context has ended
+				tx = db.WithContext(context.WithoutCancel(d.Context()))
+				if rec.status >= http.StatusInternalServerError || rec.status == rest.StatusClientClosedRequest {
+					// Failed and abandoned requests may be retried with the same key
+					if err = ReleaseKey(tx, subject, key); err != nil {
+						l.WithError(err).Error("Unable to release idempotency key.")
+					}
+					return
+				}
+				if err = CompleteKey(tx, subject, key, rec.statusCode(), w.Header().Get("Content-Type"), w.Header().Get("Location"), rec.body.Bytes()); err != nil {
+					l.WithError(err).Error("Unable to store response for idempotency key.")
+				}
+			}
+		}
+	}
+}
+
+// hashRequest computes the digest identifying a request by its method, path and body
+func hashRequest(r *http.Request) (string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// replay writes the stored response of an earlier request
+func replay(w http.ResponseWriter, e Entity) {
+	if e.ContentType != "" {
+		w.Header().Set("Content-Type", e.ContentType)
+	}
+	if e.Location != "" {
+		w.Header().Set("Location", e.Location)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(e.StatusCode)
+	_, _ = w.Write(e.ResponseBody)
+}
+
+// recorder captures the response written by a handler while passing it through
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records and writes the status code
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records and writes the body
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,14 @@
+package idempotency
+
+import (
+	"atlas-tenants/database"
+	"github.com/Chronicle20/atlas-model/model"
+	"gorm.io/gorm"
+)
+
+// GetByKeyProvider returns a provider for the record of an idempotency key used by a subject
+func GetByKeyProvider(subject string, key string) database.EntityProvider[Entity] {
+	return func(db *gorm.DB) model.Provider[Entity] {
+		return database.Query[Entity](db, map[string]interface{}{"subject": subject, "key": key})
+	}
+}
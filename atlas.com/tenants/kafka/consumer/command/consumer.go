@@ -0,0 +1,276 @@
+package command
+
+import (
+	"atlas-tenants/auth"
+	"atlas-tenants/configuration"
+	"atlas-tenants/domain"
+	consumer2 "atlas-tenants/kafka/consumer"
+	message2 "atlas-tenants/kafka/message"
+	"atlas-tenants/kafka/producer"
+	"atlas-tenants/rest"
+	"atlas-tenants/tenant"
+	"context"
+	"encoding/json"
+	"github.com/Chronicle20/atlas-kafka/consumer"
+	"github.com/Chronicle20/atlas-kafka/handler"
+	"github.com/Chronicle20/atlas-kafka/message"
+	"github.com/Chronicle20/atlas-kafka/topic"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const consumerName = "tenant_command"
+
+var (
+	// ErrUnknownResource is reported when a configuration command names a resource type other than routes or vessels
+	ErrUnknownResource = domain.Validation("UNKNOWN_RESOURCE", "unknown configuration resource").WithPointer("/resourceName")
+	// ErrInvalidAttributes is reported when the attributes of a configuration command do not describe its resource
+	ErrInvalidAttributes = domain.Validation("INVALID_ATTRIBUTES", "command attributes do not describe the resource").WithPointer("/body/attributes")
+)
+
+// InitConsumers registers the consumer of the tenant command topic
+func InitConsumers(l logrus.FieldLogger) func(func(config consumer.Config, decorators ...model.Decorator[consumer.Config])) func(consumerGroupId string) {
+	return func(rf func(config consumer.Config, decorators ...model.Decorator[consumer.Config])) func(consumerGroupId string) {
+		return func(consumerGroupId string) {
+			rf(consumer2.NewConfig(l)(consumerName)(tenant.EnvCommandTopic)(consumerGroupId), consumer.SetHeaderParsers(consumer.SpanHeaderParser))
+		}
+	}
+}
+
+// InitHandlers registers the handlers of the tenant and configuration commands sent to the tenant command topic
+func InitHandlers(l logrus.FieldLogger) func(db *gorm.DB) func(rf func(topic string, handler handler.Handler) (string, error)) {
+	return func(db *gorm.DB) func(rf func(topic string, handler handler.Handler) (string, error)) {
+		return func(rf func(topic string, handler handler.Handler) (string, error)) {
+			t, _ := topic.EnvProvider(l)(tenant.EnvCommandTopic)()
+			hs := []handler.Handler{
+				message.AdaptHandler(message.PersistentConfig(handleCreateTenant(db))),
+				message.AdaptHandler(message.PersistentConfig(handleUpdateTenant(db))),
+				message.AdaptHandler(message.PersistentConfig(handleDeleteTenant(db))),
+				message.AdaptHandler(message.PersistentConfig(handleCreateResource(db))),
+				message.AdaptHandler(message.PersistentConfig(handleUpdateResource(db))),
+				message.AdaptHandler(message.PersistentConfig(handleDeleteResource(db))),
+			}
+			for _, h := range hs {
+				if _, err := rf(t, h); err != nil {
+					l.WithError(err).Error("Unable to register tenant command handler.")
+				}
+			}
+		}
+	}
+}
+
+// handleCreateTenant creates the tenant described by a CREATE command
+func handleCreateTenant(db *gorm.DB) message.Handler[tenant.Command[tenant.CommandAttributesBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c tenant.Command[tenant.CommandAttributesBody]) {
+		if c.Type != tenant.CommandTypeCreate {
+			return
+		}
+		ctx = commandContext(ctx, c.CommandId)
+		deduplicated(l, ctx, db, c.CommandId, c, func(ctx context.Context) {
+			m, err := tenant.Extract(tenantRestModel(c.Body))
+			if err == nil {
+				_, err = tenant.NewProcessor(l, ctx, db).CreateAndEmit(m.Name(), m.Region(), m.MajorVersion(), m.MinorVersion(), m.ParentId())
+			}
+			if err != nil {
+				tenantCommandFailed(l, ctx, c.TenantId)(err)
+			}
+		})
+	}
+}
+
+// handleUpdateTenant updates the tenant named by an UPDATE command
+func handleUpdateTenant(db *gorm.DB) message.Handler[tenant.Command[tenant.CommandAttributesBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c tenant.Command[tenant.CommandAttributesBody]) {
+		if c.Type != tenant.CommandTypeUpdate {
+			return
+		}
+		ctx = commandContext(ctx, c.CommandId)
+		deduplicated(l, ctx, db, c.CommandId, c, func(ctx context.Context) {
+			m, err := tenant.Extract(tenantRestModel(c.Body))
+			if err == nil {
+				_, err = tenant.NewProcessor(l, ctx, db).UpdateAndEmit(c.TenantId, m.Name(), m.Region(), m.MajorVersion(), m.MinorVersion(), m.ParentId())
+			}
+			if err != nil {
+				tenantCommandFailed(l, ctx, c.TenantId)(err)
+			}
+		})
+	}
+}
+
+// handleDeleteTenant deletes the tenant named by a DELETE command
+func handleDeleteTenant(db *gorm.DB) message.Handler[tenant.Command[tenant.CommandDeleteBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c tenant.Command[tenant.CommandDeleteBody]) {
+		if c.Type != tenant.CommandTypeDelete {
+			return
+		}
+		ctx = commandContext(ctx, c.CommandId)
+		deduplicated(l, ctx, db, c.CommandId, c, func(ctx context.Context) {
+			if err := tenant.NewProcessor(l, ctx, db).DeleteAndEmit(c.TenantId); err != nil {
+				tenantCommandFailed(l, ctx, c.TenantId)(err)
+			}
+		})
+	}
+}
+
+// commandContext returns the context a command is applied in, whose events are caused by the command principal and
+// correlated with the command
+func commandContext(ctx context.Context, commandId uuid.UUID) context.Context {
+	p := auth.NewBuilder(consumerName).SetMethod(auth.MethodCommand).SetRole(auth.RoleAdmin).Build()
+	return message2.WithCommandId(auth.WithPrincipal(ctx, p), commandId)
+}
+
+// tenantRestModel converts the attributes of a tenant command to the model accepted by the REST API, so commands are
+// validated as requests are
+func tenantRestModel(b tenant.CommandAttributesBody) tenant.RestModel {
+	return tenant.RestModel{
+		Name:         b.Name,
+		Region:       b.Region,
+		MajorVersion: b.MajorVersion,
+		MinorVersion: b.MinorVersion,
+		ParentId:     b.ParentId,
+	}
+}
+
+// handleCreateResource creates the route or vessel described by a CREATE_RESOURCE command
+func handleCreateResource(db *gorm.DB) message.Handler[configuration.Command[configuration.CommandResourceBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c configuration.Command[configuration.CommandResourceBody]) {
+		if c.Type != configuration.CommandTypeCreateResource {
+			return
+		}
+		ctx = commandContext(ctx, c.CommandId)
+		deduplicated(l, ctx, db, c.CommandId, c, func(ctx context.Context) {
+			p := configuration.NewProcessor(l, ctx, db)
+			if c.ResourceId == "" {
+				c.ResourceId = uuid.New().String()
+			}
+			err := withResource(c, func(resource map[string]interface{}) error {
+				var err error
+				if c.ResourceName == "vessels" {
+					_, err = p.CreateVesselAndEmit(c.TenantId, resource)
+				} else {
+					_, err = p.CreateRouteAndEmit(c.TenantId, resource)
+				}
+				return err
+			})
+			if err != nil {
+				resourceCommandFailed(l, ctx, c.TenantId, c.ResourceName, c.ResourceId)(err)
+			}
+		})
+	}
+}
+
+// handleUpdateResource updates the route or vessel named by an UPDATE_RESOURCE command
+func handleUpdateResource(db *gorm.DB) message.Handler[configuration.Command[configuration.CommandResourceBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c configuration.Command[configuration.CommandResourceBody]) {
+		if c.Type != configuration.CommandTypeUpdateResource {
+			return
+		}
+		ctx = commandContext(ctx, c.CommandId)
+		deduplicated(l, ctx, db, c.CommandId, c, func(ctx context.Context) {
+			p := configuration.NewProcessor(l, ctx, db)
+			err := withResource(c, func(resource map[string]interface{}) error {
+				var err error
+				if c.ResourceName == "vessels" {
+					_, err = p.UpdateVesselAndEmit(c.TenantId, c.ResourceId, resource)
+				} else {
+					_, err = p.UpdateRouteAndEmit(c.TenantId, c.ResourceId, resource)
+				}
+				return err
+			})
+			if err != nil {
+				resourceCommandFailed(l, ctx, c.TenantId, c.ResourceName, c.ResourceId)(err)
+			}
+		})
+	}
+}
+
+// handleDeleteResource deletes the route or vessel named by a DELETE_RESOURCE command
+func handleDeleteResource(db *gorm.DB) message.Handler[configuration.Command[configuration.CommandDeleteBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c configuration.Command[configuration.CommandDeleteBody]) {
+		if c.Type != configuration.CommandTypeDeleteResource {
+			return
+		}
+		ctx = commandContext(ctx, c.CommandId)
+		deduplicated(l, ctx, db, c.CommandId, c, func(ctx context.Context) {
+			p := configuration.NewProcessor(l, ctx, db)
+			var err error
+			switch c.ResourceName {
+			case "routes":
+				err = p.DeleteRouteAndEmit(c.TenantId, c.ResourceId)
+			case "vessels":
+				err = p.DeleteVesselAndEmit(c.TenantId, c.ResourceId)
+			default:
+				err = ErrUnknownResource
+			}
+			if err != nil {
+				resourceCommandFailed(l, ctx, c.TenantId, c.ResourceName, c.ResourceId)(err)
+			}
+		})
+	}
+}
+
+// withResource converts the attributes of a resource command to a resource, as the REST API converts request bodies,
+// and applies f to it
+func withResource(c configuration.Command[configuration.CommandResourceBody], f func(resource map[string]interface{}) error) error {
+	var resource map[string]interface{}
+	var err error
+	switch c.ResourceName {
+	case "routes":
+		rm := configuration.RouteRestModel{}
+		if err = unmarshalAttributes(c.Body.Attributes, &rm); err != nil {
+			return err
+		}
+		rm.Id = c.ResourceId
+		resource, err = configuration.ExtractRoute(rm)
+	case "vessels":
+		rm := configuration.VesselRestModel{}
+		if err = unmarshalAttributes(c.Body.Attributes, &rm); err != nil {
+			return err
+		}
+		rm.Id = c.ResourceId
+		resource, err = configuration.ExtractVessel(rm)
+	default:
+		return ErrUnknownResource
+	}
+	if err != nil {
+		return err
+	}
+	return f(resource)
+}
+
+// unmarshalAttributes decodes the attributes of a resource command, reporting malformed attributes as invalid
+func unmarshalAttributes(attributes json.RawMessage, v interface{}) error {
+	if len(attributes) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(attributes, v); err != nil {
+		return ErrInvalidAttributes.Wrap(err)
+	}
+	return nil
+}
+
+// tenantCommandFailed reports the tenant command handled in ctx as failed on the tenant status topic
+func tenantCommandFailed(l logrus.FieldLogger, ctx context.Context, tenantId uuid.UUID) func(err error) {
+	return func(err error) {
+		e := rest.TransformError(err)
+		l.WithError(err).WithField("commandId", message2.CommandId(ctx)).Error("Failed to apply tenant command.")
+		perr := producer.ProviderImpl(l)(ctx)(tenant.EventTopicTenantStatus)(tenant.CommandFailedEventProvider(ctx, tenantId, e.Code, e.Detail))
+		if perr != nil {
+			l.WithError(perr).Error("Unable to report tenant command failure.")
+		}
+	}
+}
+
+// resourceCommandFailed reports the configuration command handled in ctx as failed on the configuration status topic
+func resourceCommandFailed(l logrus.FieldLogger, ctx context.Context, tenantId uuid.UUID, resourceName string, resourceId string) func(err error) {
+	return func(err error) {
+		e := rest.TransformError(err)
+		l.WithError(err).WithField("commandId", message2.CommandId(ctx)).Error("Failed to apply configuration command.")
+		perr := producer.ProviderImpl(l)(ctx)(configuration.EventTopicConfigurationStatus)(configuration.CommandFailedEventProvider(ctx, tenantId, resourceName, resourceId, e.Code, e.Detail))
+		if perr != nil {
+			l.WithError(perr).Error("Unable to report configuration command failure.")
+		}
+	}
+}
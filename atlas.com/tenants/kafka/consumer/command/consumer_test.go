@@ -0,0 +1,356 @@
+package command
+
+import (
+	"atlas-tenants/auth"
+	"atlas-tenants/configuration"
+	"atlas-tenants/database"
+	"atlas-tenants/idempotency"
+	"atlas-tenants/kafka/kafkatest"
+	"atlas-tenants/kafka/message"
+	"atlas-tenants/kafka/producer"
+	"atlas-tenants/migrations"
+	"atlas-tenants/tenant"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+)
+
+func setup(t *testing.T) (*gorm.DB, *producer.Memory) {
+	t.Helper()
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DB_DRIVER", database.DriverSQLite)
+	t.Setenv("DB_NAME", filepath.Join(t.TempDir(), "tenants.db"))
+	events := kafkatest.Record(t)
+
+	l, _ := test.NewNullLogger()
+	db := database.Connect(l, database.SetMigrations(database.VersionedMigrator(l, migrations.For)))
+	db.Logger = logger.Discard
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db, events
+}
+
+func tenantEvents(t *testing.T, events *producer.Memory) []tenant.Event[json.RawMessage] {
+	t.Helper()
+	return kafkatest.Decode[tenant.Event[json.RawMessage]](t, events.Messages(tenant.EventTopicTenantStatus))
+}
+
+func resourceEvents(t *testing.T, events *producer.Memory) []configuration.StatusEvent[json.RawMessage] {
+	t.Helper()
+	return kafkatest.Decode[configuration.StatusEvent[json.RawMessage]](t, events.Messages(configuration.EventTopicConfigurationStatus))
+}
+
+// tenantEvent returns a match for the tenant events of the type caused by the command
+func tenantEvent(commandId uuid.UUID, eventType string) func(tenant.Event[json.RawMessage]) bool {
+	return func(e tenant.Event[json.RawMessage]) bool {
+		return e.CommandId != nil && *e.CommandId == commandId && e.Type == eventType
+	}
+}
+
+// resourceEvent returns a match for the configuration events of the type caused by the command
+func resourceEvent(commandId uuid.UUID, eventType string) func(configuration.StatusEvent[json.RawMessage]) bool {
+	return func(e configuration.StatusEvent[json.RawMessage]) bool {
+		return e.CommandId != nil && *e.CommandId == commandId && e.Type == eventType
+	}
+}
+
+func failure(t *testing.T, body json.RawMessage) tenant.StatusEventCommandFailedBody {
+	t.Helper()
+	var b tenant.StatusEventCommandFailedBody
+	if err := json.Unmarshal(body, &b); err != nil {
+		t.Fatalf("unable to decode failure body [%s]: %v", body, err)
+	}
+	return b
+}
+
+func attributes(name string, region string, majorVersion uint16) tenant.CommandAttributesBody {
+	return tenant.CommandAttributesBody{Name: name, Region: region, MajorVersion: majorVersion, MinorVersion: 1}
+}
+
+func TestTenantCommands(t *testing.T) {
+	t.Setenv(tenant.EnvEventFormat, tenant.EventFormatEnvelope)
+	db, events := setup(t)
+	l, _ := test.NewNullLogger()
+	ctx := context.Background()
+
+	createId := uuid.New()
+	handleCreateTenant(db)(l, ctx, tenant.Command[tenant.CommandAttributesBody]{CommandId: createId, Type: tenant.CommandTypeCreate, Body: attributes("Tenant", "GMS", 83)})
+	created := kafkatest.Single(t, tenantEvents(t, events), tenantEvent(createId, tenant.EventTypeCreated))
+	if created.TenantId == uuid.Nil {
+		t.Fatal("created event carries no tenant ID")
+	}
+	if want := (message.Actor{Subject: consumerName, Method: auth.MethodCommand}); created.Actor != want {
+		t.Errorf("created event actor = %+v, want %+v", created.Actor, want)
+	}
+
+	updateId := uuid.New()
+	handleUpdateTenant(db)(l, ctx, tenant.Command[tenant.CommandAttributesBody]{CommandId: updateId, TenantId: created.TenantId, Type: tenant.CommandTypeUpdate, Body: attributes("Tenant", "GMS", 87)})
+	kafkatest.Single(t, tenantEvents(t, events), tenantEvent(updateId, tenant.EventTypeUpdated))
+	m, err := tenant.NewProcessor(l, ctx, db).GetById(created.TenantId)
+	if err != nil {
+		t.Fatalf("unable to get updated tenant: %v", err)
+	}
+	if m.MajorVersion() != 87 {
+		t.Errorf("major version is %d, want 87", m.MajorVersion())
+	}
+
+	deleteId := uuid.New()
+	handleDeleteTenant(db)(l, ctx, tenant.Command[tenant.CommandDeleteBody]{CommandId: deleteId, TenantId: created.TenantId, Type: tenant.CommandTypeDelete})
+	kafkatest.Single(t, tenantEvents(t, events), tenantEvent(deleteId, tenant.EventTypeDeleted))
+}
+
+func TestTenantCommandFailed(t *testing.T) {
+	db, events := setup(t)
+	l, _ := test.NewNullLogger()
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		handle func(commandId uuid.UUID)
+		code   string
+	}{
+		{
+			name: "update of a missing tenant",
+			handle: func(commandId uuid.UUID) {
+				handleUpdateTenant(db)(l, ctx, tenant.Command[tenant.CommandAttributesBody]{CommandId: commandId, TenantId: uuid.Nil, Type: tenant.CommandTypeUpdate, Body: attributes("Tenant", "GMS", 83)})
+			},
+			code: "TENANT_NOT_FOUND",
+		},
+		{
+			name: "invalid parent",
+			handle: func(commandId uuid.UUID) {
+				body := attributes("Tenant", "GMS", 83)
+				body.ParentId = "not-a-uuid"
+				handleCreateTenant(db)(l, ctx, tenant.Command[tenant.CommandAttributesBody]{CommandId: commandId, Type: tenant.CommandTypeCreate, Body: body})
+			},
+			code: "INVALID_PARENT_ID",
+		},
+		{
+			name: "delete of a missing tenant",
+			handle: func(commandId uuid.UUID) {
+				handleDeleteTenant(db)(l, ctx, tenant.Command[tenant.CommandDeleteBody]{CommandId: commandId, TenantId: uuid.New(), Type: tenant.CommandTypeDelete})
+			},
+			code: "TENANT_NOT_FOUND",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandId := uuid.New()
+			tt.handle(commandId)
+			failed := kafkatest.Single(t, tenantEvents(t, events), tenantEvent(commandId, tenant.EventTypeCommandFailed))
+			if b := failure(t, failed.Body); b.Code != tt.code {
+				t.Errorf("failure code is %s, want %s", b.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestResourceCommands(t *testing.T) {
+	db, events := setup(t)
+	l, _ := test.NewNullLogger()
+	ctx := context.Background()
+
+	m, err := tenant.NewProcessor(l, ctx, db).CreateAndEmit("Tenant", "GMS", 83, 1, uuid.Nil)
+	if err != nil {
+		t.Fatalf("unable to create tenant: %v", err)
+	}
+
+	createId := uuid.New()
+	handleCreateResource(db)(l, ctx, configuration.Command[configuration.CommandResourceBody]{
+		CommandId:    createId,
+		TenantId:     m.Id(),
+		ResourceName: "routes",
+		Type:         configuration.CommandTypeCreateResource,
+		Body:         configuration.CommandResourceBody{Attributes: json.RawMessage(`{"name":"Ellinia to Orbis Ferry","travelDuration":15}`)},
+	})
+	created := kafkatest.Single(t, resourceEvents(t, events), resourceEvent(createId, configuration.EventTypeCreated))
+	if created.ResourceId == "" {
+		t.Fatal("created event carries no resource ID")
+	}
+
+	updateId := uuid.New()
+	handleUpdateResource(db)(l, ctx, configuration.Command[configuration.CommandResourceBody]{
+		CommandId:    updateId,
+		TenantId:     m.Id(),
+		ResourceName: "routes",
+		ResourceId:   created.ResourceId,
+		Type:         configuration.CommandTypeUpdateResource,
+		Body:         configuration.CommandResourceBody{Attributes: json.RawMessage(`{"name":"Ellinia to Orbis Ferry","travelDuration":10}`)},
+	})
+	kafkatest.Single(t, resourceEvents(t, events), resourceEvent(updateId, configuration.EventTypeUpdated))
+	route, err := configuration.NewProcessor(l, ctx, db).GetRouteById(m.Id(), created.ResourceId)
+	if err != nil {
+		t.Fatalf("unable to get updated route: %v", err)
+	}
+	if d := route["attributes"].(map[string]interface{})["travelDuration"]; d != float64(10) {
+		t.Errorf("travel duration is %v, want 10", d)
+	}
+
+	deleteId := uuid.New()
+	handleDeleteResource(db)(l, ctx, configuration.Command[configuration.CommandDeleteBody]{
+		CommandId:    deleteId,
+		TenantId:     m.Id(),
+		ResourceName: "routes",
+		ResourceId:   created.ResourceId,
+		Type:         configuration.CommandTypeDeleteResource,
+	})
+	kafkatest.Single(t, resourceEvents(t, events), resourceEvent(deleteId, configuration.EventTypeDeleted))
+}
+
+func TestResourceCommandFailed(t *testing.T) {
+	db, events := setup(t)
+	l, _ := test.NewNullLogger()
+	ctx := context.Background()
+
+	m, err := tenant.NewProcessor(l, ctx, db).CreateAndEmit("Tenant", "GMS", 83, 1, uuid.Nil)
+	if err != nil {
+		t.Fatalf("unable to create tenant: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		resourceName string
+		commandType  string
+		attributes   string
+		code         string
+	}{
+		{name: "unknown resource", resourceName: "maps", commandType: configuration.CommandTypeCreateResource, attributes: `{}`, code: "UNKNOWN_RESOURCE"},
+		{name: "invalid attributes", resourceName: "routes", commandType: configuration.CommandTypeCreateResource, attributes: `{"travelDuration":"soon"}`, code: "INVALID_ATTRIBUTES"},
+		{name: "update of a missing route", resourceName: "routes", commandType: configuration.CommandTypeUpdateResource, attributes: `{"name":"Missing"}`, code: "ROUTE_NOT_FOUND"},
+		{name: "delete of a missing vessel", resourceName: "vessels", commandType: configuration.CommandTypeDeleteResource, code: "VESSEL_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandId := uuid.New()
+			if tt.commandType == configuration.CommandTypeDeleteResource {
+				handleDeleteResource(db)(l, ctx, configuration.Command[configuration.CommandDeleteBody]{CommandId: commandId, TenantId: m.Id(), ResourceName: tt.resourceName, ResourceId: "missing", Type: tt.commandType})
+			} else {
+				c := configuration.Command[configuration.CommandResourceBody]{CommandId: commandId, TenantId: m.Id(), ResourceName: tt.resourceName, ResourceId: "missing", Type: tt.commandType, Body: configuration.CommandResourceBody{Attributes: json.RawMessage(tt.attributes)}}
+				handleCreateResource(db)(l, ctx, c)
+				handleUpdateResource(db)(l, ctx, c)
+			}
+			failed := kafkatest.Single(t, resourceEvents(t, events), resourceEvent(commandId, configuration.EventTypeCommandFailed))
+			if b := failure(t, failed.Body); b.Code != tt.code {
+				t.Errorf("failure code is %s, want %s", b.Code, tt.code)
+			}
+			if failed.ResourceName != tt.resourceName || failed.ResourceId != "missing" {
+				t.Errorf("failure reports resource %s [%s], want %s [missing]", failed.ResourceName, failed.ResourceId, tt.resourceName)
+			}
+		})
+	}
+}
+
+func TestIgnoresOtherCommandTypes(t *testing.T) {
+	db, events := setup(t)
+	l, _ := test.NewNullLogger()
+	ctx := context.Background()
+
+	c := tenant.Command[tenant.CommandAttributesBody]{CommandId: uuid.New(), Type: configuration.CommandTypeCreateResource, Body: attributes("Tenant", "GMS", 83)}
+	handleCreateTenant(db)(l, ctx, c)
+	handleUpdateTenant(db)(l, ctx, c)
+	handleCreateResource(db)(l, ctx, configuration.Command[configuration.CommandResourceBody]{CommandId: uuid.New(), ResourceName: "routes", Type: tenant.CommandTypeCreate})
+
+	if n := len(events.Messages(tenant.EventTopicTenantStatus)) + len(events.Messages(configuration.EventTopicConfigurationStatus)); n != 0 {
+		t.Errorf("%d events emitted for commands of other types, want none", n)
+	}
+}
+
+func TestDuplicateCommandEmitsRecordedEvents(t *testing.T) {
+	t.Setenv(tenant.EnvEventFormat, tenant.EventFormatEnvelope)
+	db, events := setup(t)
+	l, _ := test.NewNullLogger()
+	ctx := context.Background()
+
+	c := tenant.Command[tenant.CommandAttributesBody]{CommandId: uuid.New(), Type: tenant.CommandTypeCreate, Body: attributes("Tenant", "GMS", 83)}
+	handleCreateTenant(db)(l, ctx, c)
+	handleCreateTenant(db)(l, ctx, c)
+
+	ms, err := tenant.NewProcessor(l, ctx, db).GetAll()
+	if err != nil {
+		t.Fatalf("unable to get tenants: %v", err)
+	}
+	if len(ms) != 1 {
+		t.Errorf("%d tenants created by a redelivered command, want 1", len(ms))
+	}
+	var created []tenant.Event[json.RawMessage]
+	for _, e := range tenantEvents(t, events) {
+		if tenantEvent(c.CommandId, tenant.EventTypeCreated)(e) {
+			created = append(created, e)
+		}
+	}
+	if len(created) != 2 || created[0].EventId != created[1].EventId {
+		t.Errorf("created events = %+v, want the recorded event emitted again", created)
+	}
+}
+
+func TestCommandIdReusedByDifferentCommand(t *testing.T) {
+	db, events := setup(t)
+	l, _ := test.NewNullLogger()
+	ctx := context.Background()
+
+	c := tenant.Command[tenant.CommandAttributesBody]{CommandId: uuid.New(), Type: tenant.CommandTypeCreate, Body: attributes("Tenant", "GMS", 83)}
+	handleCreateTenant(db)(l, ctx, c)
+	other := c
+	other.Body = attributes("Other", "GMS", 83)
+	handleCreateTenant(db)(l, ctx, other)
+
+	ms, err := tenant.NewProcessor(l, ctx, db).GetAll()
+	if err != nil {
+		t.Fatalf("unable to get tenants: %v", err)
+	}
+	if len(ms) != 1 || ms[0].Name() != "Tenant" {
+		t.Errorf("tenants = %+v, want only the one created by the first command", ms)
+	}
+	if n := len(events.Messages(tenant.EventTopicTenantStatus)); n != 1 {
+		t.Errorf("%d events emitted, want only the first command's", n)
+	}
+
+	// The command is recorded under the command principal, which no authenticated request can act as
+	subject := idempotency.Subject(auth.NewBuilder(consumerName).SetMethod(auth.MethodCommand).Build())
+	e, err := idempotency.GetByKeyProvider(subject, c.CommandId.String())(db)()
+	if err != nil {
+		t.Fatalf("command record under %s: %v", subject, err)
+	}
+	if want, _ := hashCommand(c); e.RequestHash != want {
+		t.Errorf("recorded hash = %s, want the hash of the command %s", e.RequestHash, want)
+	}
+}
+
+func TestDuplicateFailedCommandEmitsRecordedFailure(t *testing.T) {
+	db, events := setup(t)
+	l, _ := test.NewNullLogger()
+	ctx := context.Background()
+
+	c := tenant.Command[tenant.CommandDeleteBody]{CommandId: uuid.New(), TenantId: uuid.New(), Type: tenant.CommandTypeDelete}
+	handleDeleteTenant(db)(l, ctx, c)
+	handleDeleteTenant(db)(l, ctx, c)
+
+	ms := events.Messages(tenant.EventTopicTenantStatus)
+	if len(ms) != 2 || string(ms[0].Value) != string(ms[1].Value) {
+		t.Errorf("failure events = %d, want the recorded failure emitted again", len(ms))
+	}
+}
+
+func TestCreateTenantFailureKeyedByCommand(t *testing.T) {
+	db, events := setup(t)
+	l, _ := test.NewNullLogger()
+	ctx := context.Background()
+
+	body := attributes("Tenant", "GMS", 83)
+	body.ParentId = "not-a-uuid"
+	c := tenant.Command[tenant.CommandAttributesBody]{CommandId: uuid.New(), Type: tenant.CommandTypeCreate, Body: body}
+	handleCreateTenant(db)(l, ctx, c)
+
+	ms := events.Messages(tenant.EventTopicTenantStatus)
+	if len(ms) != 1 || string(ms[0].Key) != c.CommandId.String() {
+		t.Errorf("failure event keys = %v, want the command ID %s", ms, c.CommandId)
+	}
+}
@@ -0,0 +1,91 @@
+package command
+
+import (
+	"atlas-tenants/auth"
+	"atlas-tenants/idempotency"
+	"atlas-tenants/kafka/producer"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"time"
+)
+
+// deduplicated applies a command once per command ID. The events produced while applying it, including the report of
+// a failure, are recorded in the idempotency keys of the command principal, so a redelivered command emits them again
+// instead of being applied twice. A command whose earlier delivery did not complete is applied again, and another
+// command reusing the ID is not applied.
+func deduplicated(l logrus.FieldLogger, ctx context.Context, db *gorm.DB, commandId uuid.UUID, command interface{}, apply func(ctx context.Context)) {
+	if commandId == uuid.Nil {
+		apply(ctx)
+		return
+	}
+	key := commandId.String()
+	cl := l.WithField("commandId", key)
+	tx := db.WithContext(ctx)
+
+	p, _ := auth.FromContext(ctx)
+	subject := idempotency.Subject(p)
+	commandHash, err := hashCommand(command)
+	if err != nil {
+		cl.WithError(err).Warn("Unable to hash command. It will be applied without deduplication.")
+		apply(ctx)
+		return
+	}
+
+	reserved, err := idempotency.ReserveKey(tx, subject, key, commandHash, time.Now().Add(idempotency.TTL()))
+	if err != nil {
+		cl.WithError(err).Warn("Unable to record command. It will be applied without deduplication.")
+		apply(ctx)
+		return
+	}
+	if !reserved {
+		e, err := idempotency.GetByKeyProvider(subject, key)(tx)()
+		if err == nil && e.RequestHash != commandHash {
+			cl.Error("Command ID was already used by a different command. It will not be applied.")
+			return
+		}
+		if err == nil && e.Completed {
+			replayResult(cl, ctx, e.ResponseBody)
+			return
+		}
+		cl.Warn("Earlier delivery of the command did not complete. Applying it again.")
+	}
+
+	cctx, c := producer.WithCapture(ctx)
+	apply(cctx)
+
+	body, err := json.Marshal(c.Messages())
+	if err == nil {
+		err = idempotency.CompleteKey(tx, subject, key, 0, "application/json", "", body)
+	}
+	if err != nil {
+		cl.WithError(err).Error("Unable to record the result of the command.")
+	}
+}
+
+// replayResult emits again the events recorded for a command which was already applied
+func replayResult(l logrus.FieldLogger, ctx context.Context, body []byte) {
+	var ms []producer.Captured
+	if err := json.Unmarshal(body, &ms); err != nil {
+		l.WithError(err).Error("Unable to decode the recorded result of the command.")
+		return
+	}
+	l.Info("Command was already applied. Emitting its recorded events again.")
+	if err := producer.Replay(l, ctx, ms); err != nil {
+		l.WithError(err).Error("Unable to emit the recorded events of the command.")
+	}
+}
+
+// hashCommand computes the digest identifying a command by its content
+func hashCommand(command interface{}) (string, error) {
+	b, err := json.Marshal(command)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
@@ -0,0 +1,54 @@
+// Package kafkatest records and decodes the events produced by processors, so tests run without a Kafka broker.
+package kafkatest
+
+import (
+	"atlas-tenants/kafka/producer"
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"testing"
+)
+
+// Record selects the in-memory producer for the test, and returns the recorder emptied of earlier messages
+func Record(t testing.TB) *producer.Memory {
+	t.Helper()
+	t.Setenv("KAFKA_PRODUCER", producer.ModeMemory)
+	m := producer.Recorded()
+	m.Reset()
+	t.Cleanup(m.Reset)
+	return m
+}
+
+// Decode decodes the message values into events of type E, failing the test on a value which is not an E
+func Decode[E any](t testing.TB, ms []kafka.Message) []E {
+	t.Helper()
+	results := make([]E, 0, len(ms))
+	for _, m := range ms {
+		var e E
+		if err := json.Unmarshal(m.Value, &e); err != nil {
+			t.Fatalf("unable to decode event [%s]: %v", m.Value, err)
+		}
+		results = append(results, e)
+	}
+	return results
+}
+
+// Filter returns the events matching the predicate
+func Filter[E any](es []E, match func(E) bool) []E {
+	var results []E
+	for _, e := range es {
+		if match(e) {
+			results = append(results, e)
+		}
+	}
+	return results
+}
+
+// Single returns the only event matching the predicate, failing the test unless exactly one matches
+func Single[E any](t testing.TB, es []E, match func(E) bool) E {
+	t.Helper()
+	ms := Filter(es, match)
+	if len(ms) != 1 {
+		t.Fatalf("found %d matching events in %+v, want exactly 1", len(ms), es)
+	}
+	return ms[0]
+}
@@ -0,0 +1,22 @@
+package message
+
+import (
+	"context"
+	"github.com/google/uuid"
+)
+
+type commandIdKey struct{}
+
+// WithCommandId returns a context whose events are correlated with the command which caused them
+func WithCommandId(ctx context.Context, commandId uuid.UUID) context.Context {
+	return context.WithValue(ctx, commandIdKey{}, commandId)
+}
+
+// CommandId returns the ID of the command being handled in ctx, or nil when ctx is not handling a command
+func CommandId(ctx context.Context) *uuid.UUID {
+	id, ok := ctx.Value(commandIdKey{}).(uuid.UUID)
+	if !ok || id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
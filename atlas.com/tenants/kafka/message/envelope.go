@@ -0,0 +1,41 @@
+package message
+
+import (
+	"atlas-tenants/auth"
+	"context"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SchemaVersion is the version of the event envelope. Events emitted in the legacy shape have no version, and are
+// version 1.
+const SchemaVersion = 2
+
+// Actor identifies who caused an event
+type Actor struct {
+	Subject string `json:"subject"`
+	Method  string `json:"method"`
+}
+
+// SystemActor is the actor of events the service causes by itself
+var SystemActor = Actor{Subject: "atlas-tenants", Method: "system"}
+
+// ActorFromContext returns the principal acting in ctx as an actor, or SystemActor when no principal is acting
+func ActorFromContext(ctx context.Context) Actor {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return SystemActor
+	}
+	return Actor{Subject: p.Subject(), Method: p.Method()}
+}
+
+// CorrelationId returns the ID correlating the events emitted in ctx with their cause. It is the ID of the command
+// handled in ctx, else the trace ID of ctx, else empty.
+func CorrelationId(ctx context.Context) string {
+	if id := CommandId(ctx); id != nil {
+		return id.String()
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
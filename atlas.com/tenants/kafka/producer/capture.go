@@ -0,0 +1,74 @@
+package producer
+
+import (
+	"context"
+	"github.com/Chronicle20/atlas-kafka/producer"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+type captureKey struct{}
+
+// Captured is a message produced while capturing, with the token of the topic it was produced to
+type Captured struct {
+	Token string `json:"token"`
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Capture collects the messages produced with a context carrying it
+type Capture struct {
+	mu       sync.Mutex
+	messages []Captured
+}
+
+// WithCapture returns a context whose producers also add the messages they produce to the returned capture
+func WithCapture(ctx context.Context) (context.Context, *Capture) {
+	c := &Capture{}
+	return context.WithValue(ctx, captureKey{}, c), c
+}
+
+// Messages returns the captured messages, in the order they were produced
+func (c *Capture) Messages() []Captured {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Captured(nil), c.messages...)
+}
+
+// Replay produces captured messages again, to the topics they were first produced to
+func Replay(l logrus.FieldLogger, ctx context.Context, ms []Captured) error {
+	p := ProviderImpl(l)(ctx)
+	for _, m := range ms {
+		if err := p(m.Token)(model.FixedProvider([]kafka.Message{{Key: m.Key, Value: m.Value}})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capture adds the messages produced to a topic to the capture carried by ctx, if any, once they are produced
+func capture(ctx context.Context, token string) func(mp producer.MessageProducer) producer.MessageProducer {
+	return func(mp producer.MessageProducer) producer.MessageProducer {
+		c, ok := ctx.Value(captureKey{}).(*Capture)
+		if !ok {
+			return mp
+		}
+		return func(provider model.Provider[[]kafka.Message]) error {
+			ms, err := provider()
+			if err != nil {
+				return err
+			}
+			if err = mp(model.FixedProvider(ms)); err != nil {
+				return err
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for _, m := range ms {
+				c.messages = append(c.messages, Captured{Token: token, Key: m.Key, Value: m.Value})
+			}
+			return nil
+		}
+	}
+}
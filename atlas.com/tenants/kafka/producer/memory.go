@@ -0,0 +1,74 @@
+package producer
+
+import (
+	"github.com/Chronicle20/atlas-kafka/producer"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/segmentio/kafka-go"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// ModeKafka produces messages to the Kafka brokers
+	ModeKafka = "kafka"
+	// ModeMemory records messages in memory instead of producing them, for local development and tests
+	ModeMemory = "memory"
+)
+
+// recorded holds the messages produced while KAFKA_PRODUCER selects the in-memory producer
+var recorded = NewMemory()
+
+// Mode returns the producer selected by KAFKA_PRODUCER, which defaults to kafka
+func Mode() string {
+	if strings.EqualFold(os.Getenv("KAFKA_PRODUCER"), ModeMemory) {
+		return ModeMemory
+	}
+	return ModeKafka
+}
+
+// Recorded returns the messages produced while KAFKA_PRODUCER selects the in-memory producer
+func Recorded() *Memory {
+	return recorded
+}
+
+// Memory records produced messages by topic
+type Memory struct {
+	mu       sync.Mutex
+	messages map[string][]kafka.Message
+}
+
+// NewMemory creates an empty in-memory producer
+func NewMemory() *Memory {
+	return &Memory{messages: make(map[string][]kafka.Message)}
+}
+
+// Provider returns a provider which records the messages produced to each topic
+func (m *Memory) Provider() Provider {
+	return func(token string) producer.MessageProducer {
+		return func(provider model.Provider[[]kafka.Message]) error {
+			ms, err := provider()
+			if err != nil {
+				return err
+			}
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.messages[token] = append(m.messages[token], ms...)
+			return nil
+		}
+	}
+}
+
+// Messages returns the messages produced to a topic, in the order they were produced
+func (m *Memory) Messages(token string) []kafka.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]kafka.Message(nil), m.messages[token]...)
+}
+
+// Reset discards the recorded messages
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = make(map[string][]kafka.Message)
+}
@@ -1,20 +1,75 @@
 package producer
 
 import (
+	"atlas-tenants/metrics"
+	"atlas-tenants/retry"
+	"atlas-tenants/tracing"
 	"context"
 	"github.com/Chronicle20/atlas-kafka/producer"
 	"github.com/Chronicle20/atlas-kafka/topic"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
 
 type Provider func(token string) producer.MessageProducer
 
+// ProviderImpl returns the producer selected by KAFKA_PRODUCER, which produces to Kafka unless it records messages in
+// memory. Messages produced with a context carrying a Capture are added to it.
 func ProviderImpl(l logrus.FieldLogger) func(ctx context.Context) func(token string) producer.MessageProducer {
 	return func(ctx context.Context) func(token string) producer.MessageProducer {
-		sd := producer.SpanHeaderDecorator(ctx)
+		if Mode() == ModeMemory {
+			rp := recorded.Provider()
+			return func(token string) producer.MessageProducer {
+				return capture(ctx, token)(rp(token))
+			}
+		}
 		td := producer.TenantHeaderDecorator(ctx)
 		return func(token string) producer.MessageProducer {
-			return producer.Produce(l)(producer.WriterProvider(topic.EnvProvider(l)(token)))(sd, td)
+			tp := topic.EnvProvider(l)(token)
+			name, _ := tp()
+			return capture(ctx, token)(func(provider model.Provider[[]kafka.Message]) error {
+				sctx, span := tracing.StartProduceSpan(ctx, name)
+				sd := producer.SpanHeaderDecorator(sctx)
+				err := instrument(name)(withRetry(l, sctx)(producer.Produce(l)(producer.WriterProvider(tp))(sd, td)))(provider)
+				tracing.EndSpan(span, err)
+				return err
+			})
+		}
+	}
+}
+
+// withRetry retries producing messages while the failure is temporary. Failures to build the messages are not retried.
+func withRetry(l logrus.FieldLogger, ctx context.Context) func(mp producer.MessageProducer) producer.MessageProducer {
+	return func(mp producer.MessageProducer) producer.MessageProducer {
+		return func(provider model.Provider[[]kafka.Message]) error {
+			ms, err := provider()
+			if err != nil {
+				return err
+			}
+			return retry.Do(ctx, func(ctx context.Context, attempt int) error {
+				return mp(model.FixedProvider(ms))
+			}, retry.MaxAttempts(3), retry.RetryIf(retry.Temporary), retry.Logger(l))
+		}
+	}
+}
+
+// instrument records the messages produced to a topic, and the attempts which failed
+func instrument(topic string) func(mp producer.MessageProducer) producer.MessageProducer {
+	return func(mp producer.MessageProducer) producer.MessageProducer {
+		return func(provider model.Provider[[]kafka.Message]) error {
+			count := 0
+			err := mp(func() ([]kafka.Message, error) {
+				ms, err := provider()
+				count = len(ms)
+				return ms, err
+			})
+			if err != nil {
+				metrics.ProduceFailed(topic)
+				return err
+			}
+			metrics.MessagesProduced(topic, count)
+			return nil
 		}
 	}
 }
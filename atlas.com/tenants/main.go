@@ -1,19 +1,36 @@
 package main
 
 import (
+	"atlas-tenants/auth"
 	"atlas-tenants/configuration"
+	"atlas-tenants/configuration/diff"
+	"atlas-tenants/configuration/promotion"
 	"atlas-tenants/database"
+	"atlas-tenants/health"
+	kafkaConsumer "atlas-tenants/kafka/consumer"
+	"atlas-tenants/kafka/consumer/command"
 	"atlas-tenants/logger"
+	"atlas-tenants/metrics"
+	"atlas-tenants/migrations"
+	"atlas-tenants/openapi"
+	"atlas-tenants/replay"
+	"atlas-tenants/rest"
 	"atlas-tenants/service"
 	"atlas-tenants/tenant"
 	"atlas-tenants/tracing"
+	"context"
+	"fmt"
 	"github.com/Chronicle20/atlas-kafka/consumer"
 	"github.com/Chronicle20/atlas-rest/server"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 	"os"
+	"sync"
 )
 
 const serviceName = "atlas-tenants"
 const consumerGroupId = "Tenant Service"
+const apiVersion = "1.0.0"
 
 type Server struct {
 	baseUrl string
@@ -37,6 +54,12 @@ func GetServer() Server {
 
 func main() {
 	l := logger.CreateLogger(serviceName)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(l, os.Args[2:])
+		return
+	}
+
 	l.Infoln("Starting main service.")
 
 	tdm := service.GetTeardownManager()
@@ -46,22 +69,116 @@ func main() {
 		l.WithError(err).Fatal("Unable to initialize tracer.")
 	}
 
-	db := database.Connect(l, database.SetMigrations(tenant.MigrateEntities, configuration.MigrateEntities))
+	sc, err := auth.FromEnvironment(l)
+	if err != nil {
+		l.WithError(err).Fatal("Unable to configure authentication.")
+	}
+	rest.ConfigureSecurity(sc)
+	rest.ConfigureContext(tdm.Context())
+
+	started := &health.Flag{}
+	hc := health.NewChecker().
+		AddCheck("startup", started.Check).
+		AddCheck("kafka", health.KafkaCheck(kafkaConsumer.LookupBrokers()))
+	tdm.DrainFunc(hc.Drain)
+	ops := health.Handler(hc)
+	ops.Handle("GET /metrics", metrics.Handler())
+	health.Serve(l, tdm.Context(), tdm.WaitGroup(), health.Port(), ops)
+
+	dcs := []database.Configurator{database.SetContext(tdm.Context()), database.SetApplicationName(serviceName)}
+	if database.AutoMigrate() {
+		dcs = append(dcs, database.SetMigrations(database.VersionedMigrator(l, migrations.For)))
+	}
+	db := database.Connect(l, dcs...)
+	ms, err := migrations.For(db.Dialector.Name())
+	if err != nil {
+		l.WithError(err).Fatal("Unable to load migrations.")
+	}
+	if err = tracing.InstrumentDatabase(db); err != nil {
+		l.WithError(err).Fatal("Unable to trace database.")
+	}
+	if err = metrics.InstrumentDatabase(db); err != nil {
+		l.WithError(err).Fatal("Unable to instrument database.")
+	}
+	if err = metrics.Register(tenant.NewCollector(l, tdm.Context(), tdm.WaitGroup(), db, tenant.MetricsRefreshInterval())); err != nil {
+		l.WithError(err).Fatal("Unable to register tenant metrics.")
+	}
+	hc.AddCheck("database", health.DatabaseCheck(db)).
+		AddCheck("migrations", migrationsCheck(db, ms))
+
+	cmf := consumer.GetManager().AddConsumer(l, tdm.Context(), tdm.WaitGroup())
+	command.InitConsumers(l)(cmf)(consumerGroupId)
+	command.InitHandlers(l)(db)(consumer.GetManager().RegisterHandler)
 
-	_ = consumer.GetManager().AddConsumer(l, tdm.Context(), tdm.WaitGroup())
+	if tenant.SnapshotOnStartup() {
+		reconcileSnapshots(l, tdm.Context(), tdm.WaitGroup(), db)
+	}
 
 	// CreateRoute and run server
-	server.New(l).
+	rs := server.New(l).
 		WithContext(tdm.Context()).
 		WithWaitGroup(tdm.WaitGroup()).
-		SetBasePath(GetServer().GetPrefix()).
-		AddRouteInitializer(tenant.RegisterRoutes(db)(GetServer())).
-		AddRouteInitializer(configuration.RegisterRoutes(db)(GetServer())).
-		SetPort(os.Getenv("REST_PORT")).
+		SetBasePath(GetServer().GetPrefix())
+	rn := replay.NewRunner(l, tdm.Context(), tdm.WaitGroup(), db)
+	for _, ri := range routeInitializers(db, rn) {
+		rs = rs.AddRouteInitializer(ri)
+	}
+	rs.SetPort(os.Getenv("REST_PORT")).
 		Run()
+	started.Set()
 
 	tdm.TeardownFunc(tracing.Teardown(l)(tc))
 
 	tdm.Wait()
 	l.Infoln("Service shutdown.")
 }
+
+// migrationsCheck reports the service not ready while schema migrations are pending
+func migrationsCheck(db *gorm.DB, ms []database.Migration) health.Check {
+	return func(ctx context.Context) error {
+		n, err := database.PendingMigrations(db.WithContext(ctx), ms)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%d migrations pending", n)
+		}
+		return nil
+	}
+}
+
+// reconcileSnapshots republishes every tenant to the snapshot topic in the background, so consumers can materialize
+// tenants from the topic even if messages were lost while the service was down
+func reconcileSnapshots(l logrus.FieldLogger, ctx context.Context, wg *sync.WaitGroup, db *gorm.DB) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := tenant.NewProcessor(l, ctx, db).ReconcileSnapshotsAndEmit(); err != nil {
+			l.WithError(err).Error("Unable to reconcile tenant snapshots.")
+		}
+	}()
+}
+
+// routeInitializers returns the initializers of every route served by the service
+func routeInitializers(db *gorm.DB, rn *replay.Runner) []server.RouteInitializer {
+	return []server.RouteInitializer{
+		tenant.RegisterRoutes(db)(GetServer()),
+		configuration.RegisterRoutes(db)(GetServer()),
+		diff.RegisterRoutes(db)(GetServer()),
+		promotion.RegisterRoutes(db)(GetServer()),
+		replay.RegisterRoutes(rn)(GetServer()),
+		openapi.RegisterRoutes(openapi.NewDocument(serviceName, apiVersion, GetServer().GetPrefix(), operations()...)),
+	}
+}
+
+// operations returns the descriptions of every route served by the service
+func operations() []openapi.Operation {
+	var ops []openapi.Operation
+	ops = append(ops, tenant.Operations()...)
+	ops = append(ops, configuration.Operations()...)
+	ops = append(ops, diff.Operations()...)
+	ops = append(ops, promotion.Operations()...)
+	ops = append(ops, replay.Operations()...)
+	ops = append(ops, openapi.Operations()...)
+	return ops
+}
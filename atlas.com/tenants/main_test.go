@@ -0,0 +1,64 @@
+package main
+
+import (
+	"atlas-tenants/openapi"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus/hooks/test"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocumentCoversRoutes(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	r := mux.NewRouter()
+	for _, ri := range routeInitializers(nil, nil) {
+		ri(r, l)
+	}
+
+	type route struct {
+		method string
+		path   string
+	}
+	var routes []route
+	err := r.Walk(func(rt *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := rt.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := rt.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, m := range methods {
+			routes = append(routes, route{method: m, path: path})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to walk routes: %v", err)
+	}
+	if len(routes) == 0 {
+		t.Fatal("no routes registered")
+	}
+
+	d := openapi.NewDocument(serviceName, apiVersion, GetServer().GetPrefix(), operations()...)
+	for _, rt := range routes {
+		t.Run(rt.method+" "+rt.path, func(t *testing.T) {
+			if !d.Has(rt.method, rt.path) {
+				t.Errorf("route %s %s is registered but missing from the OpenAPI document", rt.method, rt.path)
+			}
+		})
+	}
+
+	registered := make(map[route]bool)
+	for _, rt := range routes {
+		registered[route{method: strings.ToUpper(rt.method), path: rt.path}] = true
+	}
+	for path, items := range d.Paths {
+		for method := range items {
+			if !registered[route{method: strings.ToUpper(method), path: path}] {
+				t.Errorf("OpenAPI document describes %s %s which is not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
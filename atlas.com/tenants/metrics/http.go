@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: Namespace,
+	Subsystem: "http",
+	Name:      "requests_total",
+	Help:      "REST requests handled, by handler name, method and status code.",
+}, []string{"handler", "method", "code"})
+
+var httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: Namespace,
+	Subsystem: "http",
+	Name:      "request_duration_seconds",
+	Help:      "REST request latency, by handler name and method.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"handler", "method"})
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// InstrumentHandler records the count and latency of requests served by a named handler
+func InstrumentHandler(handlerName string) func(next http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sr := &statusRecorder{ResponseWriter: w}
+			next(sr, r)
+
+			status := sr.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			httpDuration.WithLabelValues(handlerName, r.Method).Observe(time.Since(start).Seconds())
+			httpRequests.WithLabelValues(handlerName, r.Method, strconv.Itoa(status)).Inc()
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var messagesProduced = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: Namespace,
+	Subsystem: "kafka",
+	Name:      "messages_produced_total",
+	Help:      "Kafka messages produced, by topic.",
+}, []string{"topic"})
+
+var produceFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: Namespace,
+	Subsystem: "kafka",
+	Name:      "produce_failures_total",
+	Help:      "Kafka produce attempts which failed, by topic.",
+}, []string{"topic"})
+
+// MessagesProduced records messages written to a topic
+func MessagesProduced(topic string, count int) {
+	messagesProduced.WithLabelValues(topic).Add(float64(count))
+}
+
+// ProduceFailed records a failed attempt to write to a topic
+func ProduceFailed(topic string) {
+	produceFailures.WithLabelValues(topic).Inc()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"atlas-tenants/database"
+	"atlas-tenants/migrations"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: migrate up [steps] | down [steps] | status"
+
+// runMigrate applies, reverts or reports the schema migrations as directed by args, then exits
+func runMigrate(l logrus.FieldLogger, args []string) {
+	if len(args) == 0 {
+		l.Fatal(migrateUsage)
+	}
+
+	steps := 0
+	if args[0] == "down" {
+		steps = 1
+	}
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			l.Fatalf("Invalid number of steps [%s]. %s", args[1], migrateUsage)
+		}
+		steps = n
+	}
+
+	db := database.Connect(l, database.SetApplicationName(serviceName+"-migrate"))
+	ms, err := migrations.For(db.Dialector.Name())
+	if err != nil {
+		l.WithError(err).Fatal("Unable to load migrations.")
+	}
+	switch args[0] {
+	case "up":
+		n, err := database.MigrateUp(l, db, ms, steps)
+		if err != nil {
+			l.WithError(err).Fatal("Unable to apply migrations.")
+		}
+		l.Infof("Applied [%d] migrations.", n)
+	case "down":
+		n, err := database.MigrateDown(l, db, ms, steps)
+		if err != nil {
+			l.WithError(err).Fatal("Unable to revert migrations.")
+		}
+		l.Infof("Reverted [%d] migrations.", n)
+	case "status":
+		ss, err := database.MigrationStatus(db, ms)
+		if err != nil {
+			l.WithError(err).Fatal("Unable to read migration status.")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range ss {
+			at := "pending"
+			if s.Applied() {
+				at = s.AppliedAt.Format(time.RFC3339)
+			}
+			_, _ = fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Migration.Version, s.Migration.Name, at)
+		}
+		_ = w.Flush()
+	default:
+		l.Fatalf("Unknown migrate command [%s]. %s", args[0], migrateUsage)
+	}
+}
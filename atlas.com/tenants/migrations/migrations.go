@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"atlas-tenants/database"
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Postgres returns the versioned schema migrations for PostgreSQL
+func Postgres() ([]database.Migration, error) {
+	return load(database.DriverPostgres)
+}
+
+// SQLite returns the versioned schema migrations for SQLite
+func SQLite() ([]database.Migration, error) {
+	return load(database.DriverSQLite)
+}
+
+// For returns the versioned schema migrations written in the dialect, as named by the gorm dialector
+func For(dialect string) ([]database.Migration, error) {
+	switch dialect {
+	case database.DriverPostgres:
+		return Postgres()
+	case database.DriverSQLite:
+		return SQLite()
+	}
+	return nil, fmt.Errorf("no migrations for database dialect [%s]", dialect)
+}
+
+func load(dir string) ([]database.Migration, error) {
+	sub, err := fs.Sub(files, dir)
+	if err != nil {
+		return nil, err
+	}
+	return database.LoadMigrations(sub)
+}
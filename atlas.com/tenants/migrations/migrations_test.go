@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"atlas-tenants/database"
+	"github.com/sirupsen/logrus/hooks/test"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteMigrationsRoundTrip(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	ms, err := SQLite()
+	if err != nil {
+		t.Fatalf("SQLite() error = %v", err)
+	}
+	db, err := gorm.Open(database.SQLiteSource(filepath.Join(t.TempDir(), "tenants.db")).Dialector(), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	if n, err := database.MigrateUp(l, db, ms, 0); err != nil || n != len(ms) {
+		t.Fatalf("MigrateUp() = %d, %v, want %d", n, err, len(ms))
+	}
+	if pending, err := database.PendingMigrations(db, ms); err != nil || pending != 0 {
+		t.Fatalf("PendingMigrations() after up = %d, %v, want 0", pending, err)
+	}
+
+	if n, err := database.MigrateDown(l, db, ms, len(ms)); err != nil || n != len(ms) {
+		t.Fatalf("MigrateDown() = %d, %v, want %d", n, err, len(ms))
+	}
+	if pending, err := database.PendingMigrations(db, ms); err != nil || pending != len(ms) {
+		t.Fatalf("PendingMigrations() after down = %d, %v, want %d", pending, err, len(ms))
+	}
+	for _, table := range []string{"tenants", "configurations", "idempotency_keys"} {
+		if db.Migrator().HasTable(table) {
+			t.Errorf("table %s remains after reverting every migration", table)
+		}
+	}
+
+	// The down migrations must leave a schema the up migrations can be applied to again
+	if n, err := database.MigrateUp(l, db, ms, 0); err != nil || n != len(ms) {
+		t.Fatalf("MigrateUp() after down = %d, %v, want %d", n, err, len(ms))
+	}
+}
+
+func TestSQLiteConfigurationsUniqueByTenantAndResource(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	ms, err := SQLite()
+	if err != nil {
+		t.Fatalf("SQLite() error = %v", err)
+	}
+	db, err := gorm.Open(database.SQLiteSource(filepath.Join(t.TempDir(), "tenants.db")).Dialector(), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	if _, err = database.MigrateUp(l, db, ms, 3); err != nil {
+		t.Fatalf("MigrateUp() to version 3 error = %v", err)
+	}
+	insert := "INSERT INTO configurations (id, tenant_id, resource_name, resource_data) VALUES (?, 'tenant', 'routes', '{}')"
+	for _, id := range []string{"b", "a", "c"} {
+		if err = db.Exec(insert, id).Error; err != nil {
+			t.Fatalf("unable to insert configuration %s: %v", id, err)
+		}
+	}
+
+	if _, err = database.MigrateUp(l, db, ms, 0); err != nil {
+		t.Fatalf("MigrateUp() with duplicate configurations error = %v", err)
+	}
+	var live []string
+	if err = db.Raw("SELECT id FROM configurations WHERE deleted_at IS NULL").Scan(&live).Error; err != nil {
+		t.Fatalf("unable to read configurations: %v", err)
+	}
+	if len(live) != 1 || live[0] != "a" {
+		t.Errorf("live configurations = %v, want only the one with the lowest ID", live)
+	}
+	if err = db.Exec(insert, "d").Error; err == nil {
+		t.Error("a second live configuration of the same resource was inserted")
+	}
+}
@@ -0,0 +1,19 @@
+-- Baseline of the schema previously created by GORM AutoMigrate. Statements are idempotent so databases created
+-- before versioned migrations adopt them without changes.
+CREATE TABLE IF NOT EXISTS tenants
+(
+    id            uuid    NOT NULL,
+    created_at    timestamp with time zone,
+    updated_at    timestamp with time zone,
+    deleted_at    timestamp with time zone,
+    name          text    NOT NULL,
+    region        text    NOT NULL,
+    major_version integer NOT NULL,
+    minor_version integer NOT NULL,
+    parent_id     uuid,
+    PRIMARY KEY (id)
+);
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS parent_id uuid;
+
+CREATE INDEX IF NOT EXISTS idx_tenants_deleted_at ON tenants (deleted_at);
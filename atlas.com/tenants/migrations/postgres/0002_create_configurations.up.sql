@@ -0,0 +1,17 @@
+-- Baseline of the schema previously created by GORM AutoMigrate.
+CREATE TABLE IF NOT EXISTS configurations
+(
+    id            uuid   NOT NULL,
+    created_at    timestamp with time zone,
+    updated_at    timestamp with time zone,
+    deleted_at    timestamp with time zone,
+    tenant_id     uuid   NOT NULL,
+    resource_name text   NOT NULL,
+    resource_data jsonb  NOT NULL,
+    revision      bigint NOT NULL DEFAULT 1,
+    PRIMARY KEY (id)
+);
+
+ALTER TABLE configurations ADD COLUMN IF NOT EXISTS revision bigint NOT NULL DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS idx_configurations_deleted_at ON configurations (deleted_at);
@@ -0,0 +1,17 @@
+-- Baseline of the schema previously created by GORM AutoMigrate.
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    subject       text                     NOT NULL,
+    key           text                     NOT NULL,
+    request_hash  text                     NOT NULL,
+    completed     boolean                  NOT NULL DEFAULT false,
+    status_code   bigint,
+    content_type  text,
+    location      text,
+    response_body bytea,
+    created_at    timestamp with time zone,
+    expires_at    timestamp with time zone NOT NULL,
+    PRIMARY KEY (subject, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_configurations_tenant_resource;
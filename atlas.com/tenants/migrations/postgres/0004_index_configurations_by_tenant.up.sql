@@ -0,0 +1,14 @@
+-- A tenant holds one live configuration per resource name. Duplicates left by concurrent creates are soft deleted,
+-- keeping the one reads returned, which is the one with the lowest ID.
+UPDATE configurations c
+SET deleted_at = now()
+WHERE c.deleted_at IS NULL
+  AND EXISTS (SELECT 1
+              FROM configurations o
+              WHERE o.deleted_at IS NULL
+                AND o.tenant_id = c.tenant_id
+                AND o.resource_name = c.resource_name
+                AND o.id < c.id);
+
+-- Configurations are always read by tenant, and by tenant and resource name.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_configurations_tenant_resource ON configurations (tenant_id, resource_name) WHERE deleted_at IS NULL;
@@ -0,0 +1,16 @@
+-- UUIDs are stored as their text form, and timestamps as datetime so the driver scans them into time values.
+CREATE TABLE IF NOT EXISTS tenants
+(
+    id            text    NOT NULL,
+    created_at    datetime,
+    updated_at    datetime,
+    deleted_at    datetime,
+    name          text    NOT NULL,
+    region        text    NOT NULL,
+    major_version integer NOT NULL,
+    minor_version integer NOT NULL,
+    parent_id     text,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tenants_deleted_at ON tenants (deleted_at);
@@ -0,0 +1,15 @@
+-- Resource data is stored as JSON text.
+CREATE TABLE IF NOT EXISTS configurations
+(
+    id            text    NOT NULL,
+    created_at    datetime,
+    updated_at    datetime,
+    deleted_at    datetime,
+    tenant_id     text    NOT NULL,
+    resource_name text    NOT NULL,
+    resource_data text    NOT NULL,
+    revision      integer NOT NULL DEFAULT 1,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_configurations_deleted_at ON configurations (deleted_at);
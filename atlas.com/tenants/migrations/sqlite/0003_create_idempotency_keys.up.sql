@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    subject       text     NOT NULL,
+    key           text     NOT NULL,
+    request_hash  text     NOT NULL,
+    completed     integer  NOT NULL DEFAULT 0,
+    status_code   integer,
+    content_type  text,
+    location      text,
+    response_body blob,
+    created_at    datetime,
+    expires_at    datetime NOT NULL,
+    PRIMARY KEY (subject, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
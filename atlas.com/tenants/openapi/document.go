@@ -0,0 +1,208 @@
+package openapi
+
+import (
+	"github.com/jtumidanski/api2go/jsonapi"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI specification version of generated documents
+const Version = "3.1.0"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                         `json:"openapi"`
+	Info       Info                           `json:"info"`
+	Servers    []Server                       `json:"servers"`
+	Paths      map[string]map[string]PathItem `json:"paths"`
+	Components Components                     `json:"components"`
+	Security   []map[string][]string          `json:"security"`
+}
+
+// Info describes the API
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Server is the base URL of the API
+type Server struct {
+	Url string `json:"url"`
+}
+
+// PathItem is an operation on a path
+type PathItem struct {
+	OperationId string                 `json:"operationId"`
+	Summary     string                 `json:"summary,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []ParameterObject      `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]Response    `json:"responses"`
+	Security    *[]map[string][]string `json:"security,omitempty"`
+}
+
+// ParameterObject is a parameter of an operation
+type ParameterObject struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Schema      Schema `json:"schema"`
+}
+
+// RequestBody is the body of an operation
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// Components holds the reusable schemas and security schemes of the document
+type Components struct {
+	Schemas         map[string]Schema         `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is an authentication method accepted by the API
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+}
+
+const mediaType = "application/vnd.api+json"
+
+var pathParameterPattern = regexp.MustCompile(`\{([^}]+)}`)
+
+// NewDocument creates an OpenAPI document describing the operations
+func NewDocument(title string, version string, basePath string, operations ...Operation) Document {
+	d := Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Servers: []Server{{Url: strings.TrimSuffix(basePath, "/")}},
+		Paths:   make(map[string]map[string]PathItem),
+		Components: Components{
+			Schemas: map[string]Schema{
+				"errors": SchemaOf(ErrorDocument{}),
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				"apiKey": {Type: "apiKey", Name: "X-API-Key", In: "header"},
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		Security: []map[string][]string{{"apiKey": {}}, {"bearer": {}}},
+	}
+
+	sorted := append([]Operation{}, operations...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	for _, o := range sorted {
+		if _, ok := d.Paths[o.Path]; !ok {
+			d.Paths[o.Path] = make(map[string]PathItem)
+		}
+		d.Paths[o.Path][strings.ToLower(o.Method)] = d.pathItem(o)
+	}
+	return d
+}
+
+// Has returns true if the document describes the method on the path
+func (d Document) Has(method string, path string) bool {
+	_, ok := d.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+func (d Document) pathItem(o Operation) PathItem {
+	item := PathItem{
+		OperationId: o.Name,
+		Summary:     o.Summary,
+		Responses:   make(map[string]Response),
+	}
+	if o.Tag != "" {
+		item.Tags = []string{o.Tag}
+	}
+	if o.Public {
+		item.Security = &[]map[string][]string{}
+	}
+
+	for _, m := range pathParameterPattern.FindAllStringSubmatch(o.Path, -1) {
+		schema := Schema{Type: "string"}
+		if strings.HasSuffix(m[1], "tenantId") {
+			schema.Format = "uuid"
+		}
+		item.Parameters = append(item.Parameters, ParameterObject{Name: m[1], In: "path", Required: true, Schema: schema})
+	}
+	for _, p := range o.Parameters {
+		item.Parameters = append(item.Parameters, ParameterObject{Name: p.Name, In: p.In, Description: p.Description, Required: p.Required, Schema: p.Schema})
+	}
+
+	if o.Request != nil {
+		item.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{mediaType: {Schema: d.documentSchema(o.Request, false)}},
+		}
+	}
+
+	status := o.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	if o.Response != nil {
+		success.Content = map[string]MediaType{mediaType: {Schema: d.documentSchema(o.Response, o.List)}}
+	}
+	item.Responses[strconv.Itoa(status)] = success
+	item.Responses["default"] = Response{
+		Description: "JSON:API error document",
+		Content:     map[string]MediaType{mediaType: {Schema: Ref("errors")}},
+	}
+	return item
+}
+
+// documentSchema registers the resource schema of a REST model, and returns the schema of a JSON:API document
+// carrying one or many of the resource
+func (d Document) documentSchema(v interface{}, list bool) Schema {
+	name := resourceName(v)
+	if _, ok := d.Components.Schemas[name]; !ok {
+		d.Components.Schemas[name] = ResourceSchemaOf(v)
+	}
+
+	data := Ref(name)
+	if list {
+		item := data
+		data = Schema{Type: "array", Items: &item}
+	}
+	return Schema{
+		Type: "object",
+		Properties: map[string]Schema{
+			"data":     data,
+			"included": {Type: "array", Items: &Schema{Type: "object"}},
+		},
+		Required: []string{"data"},
+	}
+}
+
+func resourceName(v interface{}) string {
+	if mi, ok := v.(jsonapi.EntityNamer); ok {
+		return mi.GetName()
+	}
+	return typeName(v)
+}
+
+// ErrorDocument is the JSON:API document returned for failed requests
+type ErrorDocument struct {
+	Errors []jsonapi.Error `json:"errors"`
+}
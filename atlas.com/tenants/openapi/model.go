@@ -0,0 +1,36 @@
+package openapi
+
+// Parameter describes a query or header parameter accepted by an operation
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      Schema
+}
+
+// QueryParameter creates a string query parameter
+func QueryParameter(name string, description string, required bool) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Required: required, Schema: Schema{Type: "string"}}
+}
+
+// HeaderParameter creates a string header parameter
+func HeaderParameter(name string, description string) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Schema: Schema{Type: "string"}}
+}
+
+// Operation describes a registered handler. Path is relative to the API base path, and uses the path template the
+// handler is registered with. Request and Response are zero values of the JSON:API REST models exchanged, or nil.
+type Operation struct {
+	Name       string
+	Method     string
+	Path       string
+	Summary    string
+	Tag        string
+	Parameters []Parameter
+	Request    interface{}
+	Response   interface{}
+	List       bool
+	Status     int
+	Public     bool
+}
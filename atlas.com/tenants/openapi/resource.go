@@ -0,0 +1,32 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/Chronicle20/atlas-rest/server"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// Operations returns the description of the OpenAPI document route
+func Operations() []Operation {
+	return []Operation{
+		{Name: "get_openapi_document", Method: http.MethodGet, Path: "/openapi.json", Summary: "Get the OpenAPI document of the API", Tag: "openapi", Public: true},
+	}
+}
+
+// RegisterRoutes registers the route serving the OpenAPI document
+func RegisterRoutes(d Document) server.RouteInitializer {
+	return func(r *mux.Router, l logrus.FieldLogger) {
+		body, err := json.Marshal(d)
+		if err != nil {
+			l.WithError(err).Error("Unable to marshal OpenAPI document.")
+			return
+		}
+
+		r.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		}).Methods(http.MethodGet)
+	}
+}
@@ -0,0 +1,124 @@
+package openapi
+
+import (
+	"github.com/jtumidanski/api2go/jsonapi"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema
+type Schema struct {
+	Ref                  string            `json:"$ref,omitempty"`
+	Type                 string            `json:"type,omitempty"`
+	Format               string            `json:"format,omitempty"`
+	Const                string            `json:"const,omitempty"`
+	Properties           map[string]Schema `json:"properties,omitempty"`
+	Required             []string          `json:"required,omitempty"`
+	Items                *Schema           `json:"items,omitempty"`
+	AdditionalProperties *Schema           `json:"additionalProperties,omitempty"`
+}
+
+// Ref creates a reference to a component schema
+func Ref(name string) Schema {
+	return Schema{Ref: "#/components/schemas/" + name}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf returns the schema of the JSON encoding of a value
+func SchemaOf(v interface{}) Schema {
+	return schemaOfType(reflect.TypeOf(v))
+}
+
+// ResourceSchemaOf returns the schema of the JSON:API resource object of a REST model. Exported fields form the
+// attributes, and the references of models implementing jsonapi.MarshalReferences form the relationships.
+func ResourceSchemaOf(v interface{}) Schema {
+	properties := map[string]Schema{
+		"type":       {Type: "string", Const: resourceName(v)},
+		"id":         {Type: "string"},
+		"attributes": schemaOfType(reflect.TypeOf(v)),
+	}
+
+	if mr, ok := v.(jsonapi.MarshalReferences); ok {
+		relationships := make(map[string]Schema)
+		for _, r := range mr.GetReferences() {
+			if r.Name == "" {
+				continue
+			}
+			relationships[r.Name] = Schema{
+				Type: "object",
+				Properties: map[string]Schema{
+					"data":  {},
+					"links": {Type: "object"},
+				},
+			}
+		}
+		if len(relationships) > 0 {
+			properties["relationships"] = Schema{Type: "object", Properties: relationships}
+		}
+	}
+
+	return Schema{
+		Type:       "object",
+		Properties: properties,
+		Required:   []string{"type"},
+	}
+}
+
+func schemaOfType(t reflect.Type) Schema {
+	if t == nil {
+		return Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return Schema{Type: "string"}
+	case reflect.Bool:
+		return Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		items := schemaOfType(t.Elem())
+		return Schema{Type: "array", Items: &items}
+	case reflect.Map:
+		values := schemaOfType(t.Elem())
+		return Schema{Type: "object", AdditionalProperties: &values}
+	case reflect.Struct:
+		properties := make(map[string]Schema)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = schemaOfType(f.Type)
+		}
+		return Schema{Type: "object", Properties: properties}
+	}
+	return Schema{}
+}
+
+func typeName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// readmePath is the README documenting the REST API, relative to the package
+const readmePath = "../../README.md"
+
+var (
+	endpointHeading = regexp.MustCompile("^#### (GET|POST|PATCH|DELETE) (\\S+)$")
+	responseLine    = regexp.MustCompile("^\\*\\*Response\\*\\*: (\\d{3})[^(]*(?:\\((.+)\\))?$")
+)
+
+// readmeExample is a documented response of an endpoint in the README, with the request body documented for the
+// endpoint
+type readmeExample struct {
+	line      int
+	method    string
+	path      string
+	request   string
+	status    int
+	condition string
+	response  string
+}
+
+func (e readmeExample) String() string {
+	s := fmt.Sprintf("%s %s %d", e.method, e.path, e.status)
+	if e.condition != "" {
+		s += " " + e.condition
+	}
+	return s
+}
+
+// parseReadme returns the examples documented under the #### METHOD path headings of the README
+func parseReadme(t *testing.T) []readmeExample {
+	t.Helper()
+	f, err := os.Open(readmePath)
+	if err != nil {
+		t.Fatalf("unable to open README: %v", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err = sc.Err(); err != nil {
+		t.Fatalf("unable to read README: %v", err)
+	}
+
+	// jsonBlock returns the fenced JSON block starting at line i, if there is one
+	jsonBlock := func(i int) string {
+		if i >= len(lines) || lines[i] != "```json" {
+			return ""
+		}
+		var b strings.Builder
+		for j := i + 1; j < len(lines) && lines[j] != "```"; j++ {
+			b.WriteString(lines[j])
+			b.WriteString("\n")
+		}
+		return b.String()
+	}
+
+	var results []readmeExample
+	var method, path, request string
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			method, path, request = "", "", ""
+			if m := endpointHeading.FindStringSubmatch(line); m != nil {
+				method, path = m[1], m[2]
+			}
+			continue
+		}
+		if method == "" {
+			continue
+		}
+		if line == "**Request Body**:" {
+			request = jsonBlock(i + 1)
+			continue
+		}
+		if m := responseLine.FindStringSubmatch(line); m != nil {
+			status, _ := strconv.Atoi(m[1])
+			results = append(results, readmeExample{
+				line:      i + 1,
+				method:    method,
+				path:      path,
+				request:   request,
+				status:    status,
+				condition: m[2],
+				response:  jsonBlock(i + 1),
+			})
+		}
+	}
+	return results
+}
+
+// readmeFixture holds the stored data an example is replayed against, and the values substituted for the
+// placeholders of its path
+type readmeFixture struct {
+	server    *testServer
+	tenantIds []string
+	routeId   string
+	vesselId  string
+	replayId  string
+	resources string
+	query     string
+}
+
+// newReadmeFixture stores a tenant holding a route and a vessel, and a second tenant holding a route of the same name
+// with a different travel duration, so lists, diffs and promotions have content
+func newReadmeFixture(s *testServer) *readmeFixture {
+	tenantId := s.createTenant("string")
+	otherId := s.createTenant("other")
+	routeId := s.createResource(tenantId, "routes", routeBody("Ellinia to Orbis Ferry", 15))
+	vesselId := s.createResource(tenantId, "vessels", vesselBody("Ellinia-Orbis Ferry", routeId, ""))
+	s.createResource(otherId, "routes", routeBody("Ellinia to Orbis Ferry", 10))
+	return &readmeFixture{
+		server:    s,
+		tenantIds: []string{tenantId, otherId},
+		routeId:   routeId,
+		vesselId:  vesselId,
+		resources: "routes",
+	}
+}
+
+// path returns the example path with its placeholders replaced. Successive {tenantId} placeholders name successive
+// fixture tenants. A {replayId} placeholder names a finished replay of the first tenant, which is run on first use.
+func (f *readmeFixture) path(template string) string {
+	if strings.Contains(template, "{replayId}") && f.replayId == "" {
+		started := resourceDocument(f.server.t, f.server.expect(http.MethodPost, "/api/admin/events/replay?tenantId="+f.tenantIds[0], "", http.StatusAccepted))
+		f.replayId = f.server.awaitReplay(started.Data.Id).Id
+	}
+	for _, id := range f.tenantIds {
+		template = strings.Replace(template, "{tenantId}", id, 1)
+	}
+	template = strings.NewReplacer(
+		"{routeId}", f.routeId,
+		"{vesselId}", f.vesselId,
+		"{replayId}", f.replayId,
+		"{types}", "tenant,routes,vessels",
+		"{resourceName}", f.resources,
+		"{resourceNames}", f.resources,
+		"{bool}", "true",
+	).Replace(template)
+	if f.query == "" {
+		return template
+	}
+	if strings.Contains(template, "?") {
+		return template + "&" + strings.TrimPrefix(f.query, "?")
+	}
+	return template + f.query
+}
+
+// readmeConditions arrange the fixture for the condition documented with a response. A condition without an entry
+// fails the test, so each documented response is replayed.
+var readmeConditions = map[string]func(s *testServer, f *readmeFixture){
+	"if tenant doesn't exist": func(_ *testServer, f *readmeFixture) {
+		f.tenantIds[0] = "00000000-0000-0000-0000-000000000001"
+	},
+	"if other tenants name the tenant as their parent": func(s *testServer, f *readmeFixture) {
+		child := s.createTenant("child")
+		s.expect(http.MethodPatch, "/api/tenants/"+child, strings.Replace(tenantBody("child", f.tenantIds[0]), `"type":"tenants"`, `"type":"tenants","id":"`+child+`"`, 1), http.StatusOK)
+	},
+	"if route doesn't exist": func(_ *testServer, f *readmeFixture) {
+		f.routeId = "00000000-0000-0000-0000-000000000002"
+	},
+	"if vessel doesn't exist": func(_ *testServer, f *readmeFixture) {
+		f.vesselId = "00000000-0000-0000-0000-000000000003"
+	},
+	"with `?include=routes,vessels`": func(_ *testServer, f *readmeFixture) {
+		f.query = "?include=routes,vessels"
+	},
+	"if `include` names an unknown resource type": func(_ *testServer, f *readmeFixture) {
+		f.query = "?include=widgets"
+	},
+	"if `left` or `right` is not a valid UUID, or `resource` names an unknown resource type": func(_ *testServer, f *readmeFixture) {
+		f.tenantIds[0] = "not-a-uuid"
+	},
+	"if `left` or `right` tenant doesn't exist": func(_ *testServer, f *readmeFixture) {
+		f.tenantIds[1] = "00000000-0000-0000-0000-000000000001"
+	},
+	"if `tenantId` is not a valid UUID, or `types` names an unknown event type": func(_ *testServer, f *readmeFixture) {
+		f.tenantIds[0] = "not-a-uuid"
+	},
+	"if replay doesn't exist": func(_ *testServer, f *readmeFixture) {
+		f.replayId = "00000000-0000-0000-0000-000000000004"
+	},
+	"if `from` is invalid or equal to the target, or `resources` names an unknown resource type": func(_ *testServer, f *readmeFixture) {
+		f.tenantIds[1] = f.tenantIds[0]
+	},
+	"if the target or `from` tenant doesn't exist": func(_ *testServer, f *readmeFixture) {
+		f.tenantIds[1] = "00000000-0000-0000-0000-000000000001"
+	},
+	"if a promoted vessel references a route with no counterpart in the target tenant": func(s *testServer, f *readmeFixture) {
+		routeId := s.createResource(f.tenantIds[1], "routes", routeBody("Leafre to Orbis Ferry", 10))
+		s.createResource(f.tenantIds[1], "vessels", vesselBody("Leafre-Orbis Ferry", routeId, ""))
+		f.resources = "vessels"
+	},
+}
+
+func TestReadmeExamples(t *testing.T) {
+	examples := parseReadme(t)
+	if len(examples) == 0 {
+		t.Fatal("no examples found in README")
+	}
+
+	for _, e := range examples {
+		t.Run(e.String(), func(t *testing.T) {
+			s := newTestServer(t)
+			f := newReadmeFixture(s)
+			if e.condition != "" {
+				arrange, ok := readmeConditions[e.condition]
+				if !ok {
+					t.Fatalf("README line %d: no scenario for the condition [%s]", e.line, e.condition)
+				}
+				arrange(s, f)
+			}
+
+			path := f.path(e.path)
+			w := s.do(e.method, path, e.request)
+			if w.Code != e.status {
+				t.Fatalf("README line %d: %s %s returned %d, want %d: %s", e.line, e.method, path, w.Code, e.status, w.Body)
+			}
+
+			switch {
+			case e.response != "":
+				var documented, actual interface{}
+				if err := json.Unmarshal([]byte(e.response), &documented); err != nil {
+					t.Fatalf("README line %d: example is not valid JSON: %v", e.line, err)
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+					t.Fatalf("README line %d: response is not valid JSON: %v: %s", e.line, err, w.Body)
+				}
+				for _, d := range matchDocumented("", documented, actual) {
+					t.Errorf("README line %d: %s", e.line, d)
+				}
+			case e.status == http.StatusNoContent:
+				if w.Body.Len() != 0 {
+					t.Errorf("README line %d: %s %s returned a body for %d: %s", e.line, e.method, path, e.status, w.Body)
+				}
+			case e.status >= http.StatusBadRequest:
+				errorCode(t, w)
+			}
+		})
+	}
+}
+
+// matchDocumented returns how an actual JSON value departs from the documented example. Members of the example must be
+// present with the same kind of value, the type of resources must match, resources must return exactly the documented
+// attribute names, and a documented non-empty list must not be empty. Other values, including attribute values, are
+// examples and are not compared.
+func matchDocumented(pointer string, documented interface{}, actual interface{}) []string {
+	switch d := documented.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s is %s, documented as an object", pointerOrRoot(pointer), kindOf(actual))}
+		}
+		var results []string
+		for k, dv := range d {
+			av, ok := a[k]
+			if !ok {
+				results = append(results, fmt.Sprintf("%s/%s is documented but absent", pointer, k))
+				continue
+			}
+			if k == "type" && dv != av {
+				results = append(results, fmt.Sprintf("%s/type is %v, documented as %v", pointer, av, dv))
+				continue
+			}
+			results = append(results, matchDocumented(pointer+"/"+k, dv, av)...)
+		}
+		if strings.HasSuffix(pointer, "/attributes") {
+			for k := range a {
+				if _, ok := d[k]; !ok {
+					results = append(results, fmt.Sprintf("%s/%s is returned but not documented", pointer, k))
+				}
+			}
+		}
+		sort.Strings(results)
+		return results
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s is %s, documented as an array", pointerOrRoot(pointer), kindOf(actual))}
+		}
+		if len(d) == 0 {
+			return nil
+		}
+		if len(a) == 0 {
+			return []string{fmt.Sprintf("%s is empty, documented with elements", pointerOrRoot(pointer))}
+		}
+		var results []string
+		for i, av := range a {
+			// Included resources mix types, so each element is matched against the documented element like it
+			results = append(results, matchDocumented(fmt.Sprintf("%s/%d", pointer, i), documentedElement(d, av), av)...)
+		}
+		return results
+	default:
+		if kindOf(documented) != kindOf(actual) {
+			return []string{fmt.Sprintf("%s is %s, documented as %s", pointerOrRoot(pointer), kindOf(actual), kindOf(documented))}
+		}
+		return nil
+	}
+}
+
+// documentedElement returns the documented array element with the type and ID of the actual element, else the first
+// with its type, else the first element
+func documentedElement(documented []interface{}, actual interface{}) interface{} {
+	a, ok := actual.(map[string]interface{})
+	if !ok || a["type"] == nil {
+		return documented[0]
+	}
+	var sameType interface{}
+	for _, d := range documented {
+		dm, ok := d.(map[string]interface{})
+		if !ok || dm["type"] != a["type"] {
+			continue
+		}
+		if dm["id"] == a["id"] {
+			return d
+		}
+		if sameType == nil {
+			sameType = d
+		}
+	}
+	if sameType != nil {
+		return sameType
+	}
+	return documented[0]
+}
+
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func pointerOrRoot(pointer string) string {
+	if pointer == "" {
+		return "the document"
+	}
+	return pointer
+}
@@ -0,0 +1,59 @@
+package replay
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRate bounds how many events per second replays emit when EVENT_REPLAY_RATE is not set
+const DefaultRate = 100
+
+// Rate returns the number of events per second replays may emit from EVENT_REPLAY_RATE
+func Rate() float64 {
+	if v, ok := os.LookupEnv("EVENT_REPLAY_RATE"); ok {
+		if r, err := strconv.ParseFloat(v, 64); err == nil && r > 0 {
+			return r
+		}
+	}
+	return DefaultRate
+}
+
+// Limiter paces events evenly at a rate shared by every replay, so concurrent replays together stay within it. It is
+// safe for concurrent use.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewLimiter creates a limiter allowing rate events per second
+func NewLimiter(rate float64) *Limiter {
+	return &Limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Wait blocks until the next event may be emitted, or returns the error of ctx when it is done first
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
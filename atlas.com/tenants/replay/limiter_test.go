@@ -0,0 +1,60 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterPacesEvents(t *testing.T) {
+	l := NewLimiter(100)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+	// The first event is not delayed, and each of the others waits 10ms
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("6 events took %s, want at least 50ms at 100 per second", elapsed)
+	}
+}
+
+func TestLimiterCancelled(t *testing.T) {
+	l := NewLimiter(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := l.Wait(ctx); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("cancelled Wait() returned after %s, want it to return on cancellation", elapsed)
+	}
+}
+
+func TestRate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{value: "", want: DefaultRate},
+		{value: "25", want: 25},
+		{value: "0.5", want: 0.5},
+		{value: "0", want: DefaultRate},
+		{value: "fast", want: DefaultRate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("EVENT_REPLAY_RATE", tt.value)
+			if got := Rate(); got != tt.want {
+				t.Errorf("Rate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package replay
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	TypeTenant  = "tenant"
+	TypeRoutes  = "routes"
+	TypeVessels = "vessels"
+
+	StatusRunning   = "RUNNING"
+	StatusCompleted = "COMPLETED"
+	StatusFailed    = "FAILED"
+	StatusCancelled = "CANCELLED"
+)
+
+// Types are the replayable event types, in the order they are replayed for each tenant
+var Types = []string{TypeTenant, TypeRoutes, TypeVessels}
+
+// Model reports the progress of a replay of the events describing the current state of tenants
+type Model struct {
+	id              uuid.UUID
+	tenantId        uuid.UUID
+	types           []string
+	status          string
+	tenantCount     int
+	tenantsReplayed int
+	tenantEvents    int
+	routeEvents     int
+	vesselEvents    int
+	errorCode       string
+	startedAt       time.Time
+	finishedAt      time.Time
+}
+
+// Id returns the replay ID
+func (m Model) Id() uuid.UUID {
+	return m.id
+}
+
+// TenantId returns the ID of the replayed tenant, or uuid.Nil when every tenant is replayed
+func (m Model) TenantId() uuid.UUID {
+	return m.tenantId
+}
+
+// Types returns the replayed event types
+func (m Model) Types() []string {
+	return m.types
+}
+
+// Replays returns true if events of the type are replayed
+func (m Model) Replays(eventType string) bool {
+	return contains(m.types, eventType)
+}
+
+// Status returns the status of the replay
+func (m Model) Status() string {
+	return m.status
+}
+
+// TenantCount returns the number of tenants to replay, known once the replay has read them
+func (m Model) TenantCount() int {
+	return m.tenantCount
+}
+
+// TenantsReplayed returns the number of tenants whose events have all been emitted
+func (m Model) TenantsReplayed() int {
+	return m.tenantsReplayed
+}
+
+// Emitted returns the number of events of the type emitted so far
+func (m Model) Emitted(eventType string) int {
+	switch eventType {
+	case TypeTenant:
+		return m.tenantEvents
+	case TypeRoutes:
+		return m.routeEvents
+	case TypeVessels:
+		return m.vesselEvents
+	}
+	return 0
+}
+
+// ErrorCode returns the code of the error which failed the replay
+func (m Model) ErrorCode() string {
+	return m.errorCode
+}
+
+// StartedAt returns when the replay started
+func (m Model) StartedAt() time.Time {
+	return m.startedAt
+}
+
+// FinishedAt returns when the replay finished, or the zero time while it runs
+func (m Model) FinishedAt() time.Time {
+	return m.finishedAt
+}
+
+// Finished returns true if the replay is no longer running
+func (m Model) Finished() bool {
+	return m.status != StatusRunning
+}
+
+// String returns a string representation of the replay
+func (m Model) String() string {
+	return fmt.Sprintf("Id [%s] Status [%s] Tenants [%d/%d] Events [%d tenant, %d routes, %d vessels]", m.Id().String(), m.Status(), m.TenantsReplayed(), m.TenantCount(), m.tenantEvents, m.routeEvents, m.vesselEvents)
+}
+
+// planned returns the replay with the number of tenants to replay
+func (m Model) planned(tenantCount int) Model {
+	m.tenantCount = tenantCount
+	return m
+}
+
+// emitted returns the replay with one more event of the type emitted
+func (m Model) emitted(eventType string) Model {
+	switch eventType {
+	case TypeTenant:
+		m.tenantEvents++
+	case TypeRoutes:
+		m.routeEvents++
+	case TypeVessels:
+		m.vesselEvents++
+	}
+	return m
+}
+
+// replayed returns the replay with one more tenant replayed
+func (m Model) replayed() Model {
+	m.tenantsReplayed++
+	return m
+}
+
+// finished returns the replay finished with the status, and the code of the error which failed it
+func (m Model) finished(status string, errorCode string) Model {
+	m.status = status
+	m.errorCode = errorCode
+	m.finishedAt = time.Now().UTC()
+	return m
+}
+
+// Builder is used to build a Model
+type Builder struct {
+	id       uuid.UUID
+	tenantId uuid.UUID
+	types    []string
+}
+
+// NewBuilder creates a new Builder for a replay of every tenant and event type
+func NewBuilder() *Builder {
+	return &Builder{
+		id:       uuid.New(),
+		tenantId: uuid.Nil,
+		types:    Types,
+	}
+}
+
+// SetTenantId limits the replay to a tenant
+func (b *Builder) SetTenantId(tenantId uuid.UUID) *Builder {
+	b.tenantId = tenantId
+	return b
+}
+
+// SetTypes sets the replayed event types
+func (b *Builder) SetTypes(types []string) *Builder {
+	b.types = types
+	return b
+}
+
+// Build creates a new running Model
+func (b *Builder) Build() Model {
+	return Model{
+		id:        b.id,
+		tenantId:  b.tenantId,
+		types:     b.types,
+		status:    StatusRunning,
+		startedAt: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,174 @@
+package replay
+
+import (
+	"atlas-tenants/configuration"
+	"atlas-tenants/domain"
+	"atlas-tenants/kafka/producer"
+	"atlas-tenants/tenant"
+	"context"
+	"errors"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"sort"
+)
+
+var (
+	// ErrNotFound is returned when a replay does not exist
+	ErrNotFound = domain.NotFound("REPLAY_NOT_FOUND", "replay not found")
+	// ErrUnknownType is returned when a replay names an event type other than tenant, routes or vessels
+	ErrUnknownType = domain.Validation("UNKNOWN_REPLAY_TYPE", "unknown replay event type").WithParameter("types")
+)
+
+// Processor defines the interface for replay operations
+type Processor interface {
+	// Replay emits a CREATED event for the current state of every tenant, and every route and vessel each tenant
+	// resolves, of the replayed types, reporting the progress of the replay after every event
+	Replay(m Model, report func(Model)) (Model, error)
+}
+
+// ProcessorImpl implements the Processor interface
+type ProcessorImpl struct {
+	l       logrus.FieldLogger
+	ctx     context.Context
+	db      *gorm.DB
+	limiter *Limiter
+	p       producer.Provider
+}
+
+// NewProcessor creates a new processor emitting events at the pace of the limiter. The replay stops when ctx is done.
+func NewProcessor(l logrus.FieldLogger, ctx context.Context, db *gorm.DB, limiter *Limiter) Processor {
+	return &ProcessorImpl{
+		l:       l,
+		ctx:     ctx,
+		db:      db,
+		limiter: limiter,
+		p:       producer.ProviderImpl(l)(ctx),
+	}
+}
+
+// Replay emits a CREATED event for the current state of every tenant, and every route and vessel each tenant
+// resolves, of the replayed types. Parents are replayed before their children, and routes before the vessels
+// referencing them.
+func (p *ProcessorImpl) Replay(m Model, report func(Model)) (Model, error) {
+	ts, err := p.tenants(m.TenantId())
+	if err != nil {
+		return m, err
+	}
+	m = m.planned(len(ts))
+	report(m)
+
+	cp := configuration.NewProcessor(p.l, p.ctx, p.db)
+	for _, t := range ts {
+		if m.Replays(TypeTenant) {
+			if err = p.emit(tenant.EventTopicTenantStatus, tenant.CreateStatusEventProvider(p.ctx, tenant.EventTypeCreated, t)); err != nil {
+				return m, err
+			}
+			m = m.emitted(TypeTenant)
+			report(m)
+		}
+
+		for _, resourceName := range []string{TypeRoutes, TypeVessels} {
+			if !m.Replays(resourceName) {
+				continue
+			}
+			resources, err := resolvedResources(cp, resourceName, t.Id())
+			if err != nil {
+				return m, err
+			}
+			for _, resource := range resources {
+				if err = p.emit(configuration.EventTopicConfigurationStatus, configuration.CreateStatusEventProvider(p.ctx, t.Id(), resourceName, configuration.EventTypeCreated, resource)); err != nil {
+					return m, err
+				}
+				m = m.emitted(resourceName)
+				report(m)
+			}
+		}
+
+		m = m.replayed()
+		report(m)
+		p.l.WithFields(logrus.Fields{"replayId": m.Id().String(), "tenantId": t.Id().String()}).Debug("Tenant replayed.")
+	}
+	return m, nil
+}
+
+// tenants returns the replayed tenants, parents before their children
+func (p *ProcessorImpl) tenants(tenantId uuid.UUID) ([]tenant.Model, error) {
+	tp := tenant.NewProcessor(p.l, p.ctx, p.db)
+	if tenantId != uuid.Nil {
+		t, err := tp.GetById(tenantId)
+		if err != nil {
+			return nil, err
+		}
+		return []tenant.Model{t}, nil
+	}
+	ts, err := tp.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return parentsFirst(ts), nil
+}
+
+// emit produces the event once the limiter allows it
+func (p *ProcessorImpl) emit(topic string, provider model.Provider[[]kafka.Message]) error {
+	if err := p.limiter.Wait(p.ctx); err != nil {
+		return err
+	}
+	return p.p(topic)(provider)
+}
+
+// resolvedResources returns the routes or vessels a tenant resolves, including those it inherits and leaving out those
+// it suppresses, as the tenant's live events describe them. A tenant without resources of the type has none.
+func resolvedResources(p configuration.Processor, resourceName string, tenantId uuid.UUID) ([]map[string]interface{}, error) {
+	provider := p.AllRoutesProvider(tenantId)
+	if resourceName == TypeVessels {
+		provider = p.AllVesselsProvider(tenantId)
+	}
+	resources, err := provider()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return []map[string]interface{}{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := make([]map[string]interface{}, 0, len(resources))
+	for _, r := range resources {
+		if !configuration.IsSuppressed(r) {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// parentsFirst orders tenants by name, moving each tenant after its parent
+func parentsFirst(ts []tenant.Model) []tenant.Model {
+	sort.SliceStable(ts, func(i, j int) bool { return ts[i].Name() < ts[j].Name() })
+
+	pending := make(map[uuid.UUID]bool, len(ts))
+	for _, t := range ts {
+		pending[t.Id()] = true
+	}
+	results := make([]tenant.Model, 0, len(ts))
+	for len(results) < len(ts) {
+		progressed := false
+		for _, t := range ts {
+			if pending[t.Id()] && !pending[t.ParentId()] {
+				results = append(results, t)
+				delete(pending, t.Id())
+				progressed = true
+			}
+		}
+		if !progressed {
+			// The remaining tenants form a cycle, which validation prevents; keep them in name order
+			for _, t := range ts {
+				if pending[t.Id()] {
+					results = append(results, t)
+				}
+			}
+			break
+		}
+	}
+	return results
+}
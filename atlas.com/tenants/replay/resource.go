@@ -0,0 +1,94 @@
+package replay
+
+import (
+	"atlas-tenants/openapi"
+	"atlas-tenants/rest"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-rest/server"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jtumidanski/api2go/jsonapi"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// StartReplayHandler handles POST /admin/events/replay?tenantId={tenantId}&types={types}
+func StartReplayHandler(rn *Runner) func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+		return parseOptionalTenantId(d.Logger(), func(tenantId uuid.UUID) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				rm, err := model.Map(Transform)(func() (Model, error) {
+					return rn.Start(d.Context(), tenantId, rest.ParseQueryList(r, "types"))
+				})()
+				if err != nil {
+					d.Logger().WithError(err).Error("Failed to start event replay")
+					rest.WriteError(d.Logger())(w)(err)
+					return
+				}
+
+				query := r.URL.Query()
+				queryParams := jsonapi.ParseQueryFields(&query)
+				w.WriteHeader(http.StatusAccepted)
+				server.MarshalResponse[RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(rm)
+			}
+		})
+	}
+}
+
+// GetReplayByIdHandler handles GET /admin/events/replay/{replayId}
+func GetReplayByIdHandler(rn *Runner) func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			replayId, err := uuid.Parse(mux.Vars(r)["replayId"])
+			if err != nil {
+				// An ID which is not a UUID names no replay
+				replayId = uuid.Nil
+			}
+
+			rm, err := model.Map(Transform)(rn.ByIdProvider(replayId))()
+			if err != nil {
+				d.Logger().WithError(err).Error("Failed to get event replay")
+				rest.WriteError(d.Logger())(w)(err)
+				return
+			}
+
+			query := r.URL.Query()
+			queryParams := jsonapi.ParseQueryFields(&query)
+			server.MarshalResponse[RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(rm)
+		}
+	}
+}
+
+// parseOptionalTenantId parses the tenantId query parameter, which is uuid.Nil when absent
+func parseOptionalTenantId(l logrus.FieldLogger, next rest.TenantIdHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("tenantId") == "" {
+			next(uuid.Nil)(w, r)
+			return
+		}
+		rest.ParseQueryTenantId(l, "tenantId", next)(w, r)
+	}
+}
+
+// RegisterRoutes registers the event replay routes
+func RegisterRoutes(rn *Runner) func(si jsonapi.ServerInformation) server.RouteInitializer {
+	return func(si jsonapi.ServerInformation) server.RouteInitializer {
+		return func(r *mux.Router, l logrus.FieldLogger) {
+			registerHandler := rest.RegisterHandler(l)(si)
+
+			r.HandleFunc("/admin/events/replay", registerHandler("replay_events", StartReplayHandler(rn))).Methods(http.MethodPost)
+			r.HandleFunc("/admin/events/replay/{replayId}", registerHandler("get_event_replay", GetReplayByIdHandler(rn))).Methods(http.MethodGet)
+		}
+	}
+}
+
+// Operations describes the event replay routes
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Name: "replay_events", Method: http.MethodPost, Path: "/admin/events/replay", Summary: "Replay the events describing the current state of tenants", Tag: "events", Parameters: []openapi.Parameter{
+			openapi.QueryParameter("tenantId", "Id of the tenant to replay, defaulting to every tenant", false),
+			openapi.QueryParameter("types", "Comma separated event types to replay (tenant, routes, vessels)", false),
+		}, Response: RestModel{}, Status: http.StatusAccepted},
+		{Name: "get_event_replay", Method: http.MethodGet, Path: "/admin/events/replay/{replayId}", Summary: "Get the progress of an event replay", Tag: "events", Response: RestModel{}},
+	}
+}
@@ -0,0 +1,73 @@
+package replay
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// RestModel is the JSON:API resource for event replays
+type RestModel struct {
+	Id              string           `json:"-"`
+	TenantId        *string          `json:"tenantId"`
+	Types           []string         `json:"types"`
+	Status          string           `json:"status"`
+	TenantCount     int              `json:"tenantCount"`
+	TenantsReplayed int              `json:"tenantsReplayed"`
+	Emitted         EmittedRestModel `json:"emitted"`
+	ErrorCode       *string          `json:"errorCode"`
+	StartedAt       time.Time        `json:"startedAt"`
+	FinishedAt      *time.Time       `json:"finishedAt"`
+}
+
+// EmittedRestModel counts the events a replay emitted by type
+type EmittedRestModel struct {
+	Tenant  int `json:"tenant"`
+	Routes  int `json:"routes"`
+	Vessels int `json:"vessels"`
+}
+
+// GetID returns the resource ID
+func (r RestModel) GetID() string {
+	return r.Id
+}
+
+// SetID sets the resource ID
+func (r *RestModel) SetID(id string) error {
+	r.Id = id
+	return nil
+}
+
+// GetName returns the resource name
+func (r RestModel) GetName() string {
+	return "eventReplays"
+}
+
+// Transform converts a Model to a RestModel. Members which do not apply yet are null.
+func Transform(m Model) (RestModel, error) {
+	rm := RestModel{
+		Id:              m.Id().String(),
+		Types:           m.Types(),
+		Status:          m.Status(),
+		TenantCount:     m.TenantCount(),
+		TenantsReplayed: m.TenantsReplayed(),
+		Emitted: EmittedRestModel{
+			Tenant:  m.Emitted(TypeTenant),
+			Routes:  m.Emitted(TypeRoutes),
+			Vessels: m.Emitted(TypeVessels),
+		},
+		StartedAt: m.StartedAt(),
+	}
+	if m.TenantId() != uuid.Nil {
+		tenantId := m.TenantId().String()
+		rm.TenantId = &tenantId
+	}
+	if m.ErrorCode() != "" {
+		errorCode := m.ErrorCode()
+		rm.ErrorCode = &errorCode
+	}
+	if m.Finished() {
+		finishedAt := m.FinishedAt()
+		rm.FinishedAt = &finishedAt
+	}
+	return rm, nil
+}
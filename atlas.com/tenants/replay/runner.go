@@ -0,0 +1,140 @@
+package replay
+
+import (
+	"atlas-tenants/auth"
+	"atlas-tenants/rest"
+	"atlas-tenants/tenant"
+	"context"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"sync"
+)
+
+// retained bounds how many finished replays are kept for their progress to be read
+const retained = 100
+
+// Runner runs replays in the background. Replays are bound to the service context rather than to the request starting
+// them, so they outlive the request and are cancelled when the service shuts down. It is safe for concurrent use.
+type Runner struct {
+	l       logrus.FieldLogger
+	ctx     context.Context
+	wg      *sync.WaitGroup
+	db      *gorm.DB
+	limiter *Limiter
+	mu      sync.RWMutex
+	replays map[uuid.UUID]Model
+	order   []uuid.UUID
+}
+
+// NewRunner creates a runner whose replays stop when ctx is done, and which the wait group waits for
+func NewRunner(l logrus.FieldLogger, ctx context.Context, wg *sync.WaitGroup, db *gorm.DB) *Runner {
+	return &Runner{
+		l:       l,
+		ctx:     ctx,
+		wg:      wg,
+		db:      db,
+		limiter: NewLimiter(Rate()),
+		replays: make(map[uuid.UUID]Model),
+	}
+}
+
+// Start validates a replay of the tenant, or of every tenant when tenantId is uuid.Nil, and starts it in the
+// background. Every event type is replayed when types is empty. The events are caused by the principal acting in ctx,
+// and correlated with its trace.
+func (r *Runner) Start(ctx context.Context, tenantId uuid.UUID, types []string) (Model, error) {
+	if len(types) == 0 {
+		types = Types
+	}
+	for _, t := range types {
+		if !contains(Types, t) {
+			return Model{}, ErrUnknownType.WithDetail("unknown replay event type: " + t)
+		}
+	}
+	if tenantId != uuid.Nil {
+		if _, err := tenant.NewProcessor(r.l, ctx, r.db).GetById(tenantId); err != nil {
+			return Model{}, err
+		}
+	}
+
+	m := NewBuilder().SetTenantId(tenantId).SetTypes(types).Build()
+	r.put(m)
+
+	rctx := trace.ContextWithSpanContext(r.ctx, trace.SpanContextFromContext(ctx))
+	if p, ok := auth.FromContext(ctx); ok {
+		rctx = auth.WithPrincipal(rctx, p)
+	}
+	r.wg.Add(1)
+	go r.run(rctx, m)
+
+	r.l.WithFields(logrus.Fields{
+		"replayId": m.Id().String(),
+		"tenantId": tenantId.String(),
+		"types":    types,
+	}).Info("Event replay started.")
+	return m, nil
+}
+
+// run replays the events, recording the progress of the replay until it finishes
+func (r *Runner) run(ctx context.Context, m Model) {
+	defer r.wg.Done()
+
+	m, err := NewProcessor(r.l, ctx, r.db, r.limiter).Replay(m, r.put)
+	l := r.l.WithField("replayId", m.Id().String())
+	switch {
+	case err == nil:
+		m = m.finished(StatusCompleted, "")
+		l.Infof("Event replay completed. %s", m)
+	case ctx.Err() != nil:
+		m = m.finished(StatusCancelled, "")
+		l.Warnf("Event replay cancelled. %s", m)
+	default:
+		m = m.finished(StatusFailed, rest.TransformError(err).Code)
+		l.WithError(err).Errorf("Event replay failed. %s", m)
+	}
+	r.put(m)
+}
+
+// put records the progress of a replay, forgetting the oldest finished replays beyond those retained
+func (r *Runner) put(m Model) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.replays[m.Id()]; !ok {
+		r.order = append(r.order, m.Id())
+	}
+	r.replays[m.Id()] = m
+
+	for i := 0; len(r.order) > retained && i < len(r.order); {
+		id := r.order[i]
+		if !r.replays[id].Finished() {
+			i++
+			continue
+		}
+		delete(r.replays, id)
+		r.order = append(r.order[:i], r.order[i+1:]...)
+	}
+}
+
+// ByIdProvider returns a provider for the progress of a replay by ID
+func (r *Runner) ByIdProvider(id uuid.UUID) model.Provider[Model] {
+	return func() (Model, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		m, ok := r.replays[id]
+		if !ok {
+			return Model{}, ErrNotFound
+		}
+		return m, nil
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"atlas-tenants/auth"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// tenantQueryParameters are the query parameters through which handlers name tenants
+var tenantQueryParameters = []string{"left", "right", "from", "tenantId"}
+
+// security rejects every request until ConfigureSecurity is called, so handlers are never served unauthenticated by
+// accident
+var security = auth.NewConfig(auth.Deny(), auth.DefaultPolicy())
+
+// ConfigureSecurity sets the authentication and authorization applied to handlers registered afterward
+func ConfigureSecurity(c auth.Config) {
+	security = c
+}
+
+type PrincipalHandler func(p auth.Principal) http.HandlerFunc
+
+// Authorize authenticates the request and verifies the principal may invoke the handler on the tenants the request
+// names, before passing the principal to next
+func Authorize(l logrus.FieldLogger, c auth.Config, handlerName string, next PrincipalHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := c.Authenticator().Authenticate(r)
+		if err != nil {
+			l.WithError(err).Warnf("Unable to authenticate request for [%s].", handlerName)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="atlas-tenants"`)
+			WriteError(l)(w)(err)
+			return
+		}
+
+		if !p.Role().Includes(c.Policy().RequiredRole(handlerName)) {
+			l.Warnf("Principal [%s] with role [%s] denied [%s].", p.Subject(), p.Role(), handlerName)
+			WriteError(l)(w)(auth.ErrForbidden)
+			return
+		}
+
+		if err = authorizeTenants(c, handlerName, p, r); err != nil {
+			l.WithError(err).Warnf("Principal [%s] denied [%s].", p.Subject(), handlerName)
+			WriteError(l)(w)(err)
+			return
+		}
+
+		next(p)(w, r)
+	}
+}
+
+// authorizeTenants verifies a principal limited to specific tenants only acts on those tenants
+func authorizeTenants(c auth.Config, handlerName string, p auth.Principal, r *http.Request) error {
+	if !p.Scoped() {
+		return nil
+	}
+
+	values := make([]string, 0)
+	if v, ok := mux.Vars(r)["tenantId"]; ok {
+		values = append(values, v)
+	}
+	for _, param := range tenantQueryParameters {
+		if v := r.URL.Query().Get(param); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	if len(values) == 0 {
+		if c.Policy().FiltersByTenant(handlerName) {
+			return nil
+		}
+		return auth.ErrTenantForbidden
+	}
+
+	for _, v := range values {
+		tenantId, err := uuid.Parse(v)
+		if err != nil {
+			// Malformed tenant IDs are rejected by the handler itself
+			continue
+		}
+		if !p.CanAccessTenant(tenantId) {
+			return auth.ErrTenantForbidden
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"atlas-tenants/auth"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus/hooks/test"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	allowed := uuid.New()
+	other := uuid.New()
+	c := auth.NewConfig(auth.NewAPIKeyAuthenticator(map[string]auth.Principal{
+		"viewer-key":   auth.NewBuilder("viewer").SetMethod(auth.MethodAPIKey).SetRole(auth.RoleViewer).Build(),
+		"operator-key": auth.NewBuilder("operator").SetMethod(auth.MethodAPIKey).SetRole(auth.RoleOperator).Build(),
+		"admin-key":    auth.NewBuilder("admin").SetMethod(auth.MethodAPIKey).SetRole(auth.RoleAdmin).Build(),
+		"scoped-key":   auth.NewBuilder("scoped").SetMethod(auth.MethodAPIKey).SetRole(auth.RoleAdmin).SetTenantIds([]uuid.UUID{allowed}).Build(),
+	}), auth.DefaultPolicy())
+
+	tests := []struct {
+		name        string
+		key         string
+		handlerName string
+		path        string
+		wantStatus  int
+		wantCode    string
+	}{
+		{name: "no credentials", handlerName: "get_all_tenants", path: "/tenants", wantStatus: http.StatusUnauthorized, wantCode: "UNAUTHENTICATED"},
+		{name: "invalid credentials", key: "guess", handlerName: "get_all_tenants", path: "/tenants", wantStatus: http.StatusUnauthorized, wantCode: "INVALID_CREDENTIALS"},
+		{name: "viewer reads", key: "viewer-key", handlerName: "get_tenant_by_id", path: "/tenants/" + other.String(), wantStatus: http.StatusOK},
+		{name: "viewer changes a route", key: "viewer-key", handlerName: "create_route", path: "/tenants/" + other.String(), wantStatus: http.StatusForbidden, wantCode: "FORBIDDEN"},
+		{name: "operator changes a route", key: "operator-key", handlerName: "create_route", path: "/tenants/" + other.String(), wantStatus: http.StatusOK},
+		{name: "operator changes a tenant", key: "operator-key", handlerName: "update_tenant", path: "/tenants/" + other.String(), wantStatus: http.StatusForbidden, wantCode: "FORBIDDEN"},
+		{name: "operator invokes an unlisted handler", key: "operator-key", handlerName: "unlisted", path: "/tenants", wantStatus: http.StatusForbidden, wantCode: "FORBIDDEN"},
+		{name: "admin changes a tenant", key: "admin-key", handlerName: "update_tenant", path: "/tenants/" + other.String(), wantStatus: http.StatusOK},
+		{name: "scoped path tenant", key: "scoped-key", handlerName: "get_tenant_by_id", path: "/tenants/" + allowed.String(), wantStatus: http.StatusOK},
+		{name: "scoped other path tenant", key: "scoped-key", handlerName: "get_tenant_by_id", path: "/tenants/" + other.String(), wantStatus: http.StatusForbidden, wantCode: "TENANT_FORBIDDEN"},
+		{name: "scoped query tenants", key: "scoped-key", handlerName: "get_configuration_diff", path: "/diff?left=" + allowed.String() + "&right=" + allowed.String(), wantStatus: http.StatusOK},
+		{name: "scoped other query tenant", key: "scoped-key", handlerName: "get_configuration_diff", path: "/diff?left=" + allowed.String() + "&right=" + other.String(), wantStatus: http.StatusForbidden, wantCode: "TENANT_FORBIDDEN"},
+		{name: "scoped promotion source", key: "scoped-key", handlerName: "promote_configuration", path: "/tenants/" + allowed.String() + "?from=" + other.String(), wantStatus: http.StatusForbidden, wantCode: "TENANT_FORBIDDEN"},
+		{name: "scoped filtered handler", key: "scoped-key", handlerName: "get_all_tenants", path: "/tenants", wantStatus: http.StatusOK},
+		{name: "scoped handler naming no tenant", key: "scoped-key", handlerName: "create_tenant", path: "/tenants", wantStatus: http.StatusForbidden, wantCode: "TENANT_FORBIDDEN"},
+		{name: "scoped malformed tenant", key: "scoped-key", handlerName: "get_tenant_by_id", path: "/tenants/not-a-uuid", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := test.NewNullLogger()
+			var principal auth.Principal
+			router := mux.NewRouter()
+			handler := Authorize(l, c, tt.handlerName, func(p auth.Principal) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					principal = p
+					w.WriteHeader(http.StatusOK)
+				}
+			})
+			router.HandleFunc("/tenants/{tenantId}", handler)
+			router.HandleFunc("/tenants", handler)
+			router.HandleFunc("/diff", handler)
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				r.Header.Set(auth.APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if principal.Subject() == "" {
+					t.Error("handler was not passed the principal")
+				}
+				return
+			}
+
+			var d ErrorDocument
+			if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil || len(d.Errors) != 1 {
+				t.Fatalf("body = %s, want a JSON:API error document with one error", w.Body)
+			}
+			if d.Errors[0].Code != tt.wantCode || d.Errors[0].Status != strconv.Itoa(tt.wantStatus) {
+				t.Errorf("error = %+v, want %d %s", d.Errors[0], tt.wantStatus, tt.wantCode)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/vnd.api+json" {
+				t.Errorf("Content-Type = %s, want application/vnd.api+json", ct)
+			}
+			if challenge := w.Header().Get("WWW-Authenticate"); (challenge != "") != (tt.wantStatus == http.StatusUnauthorized) {
+				t.Errorf("WWW-Authenticate = %q, want a challenge only for 401", challenge)
+			}
+		})
+	}
+}
+
+func TestDefaultSecurityDeniesRequests(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	called := false
+	handler := Authorize(l, security, "get_all_tenants", func(auth.Principal) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/tenants", nil))
+	if w.Code != http.StatusUnauthorized || called {
+		t.Fatalf("status = %d, called = %t, want 401 without calling the handler", w.Code, called)
+	}
+}
@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultRequestTimeout bounds how long a request may run when REST_REQUEST_TIMEOUT is not set
+const DefaultRequestTimeout = 30 * time.Second
+
+// RequestTimeout returns the request timeout from REST_REQUEST_TIMEOUT
+func RequestTimeout() time.Duration {
+	if v, ok := os.LookupEnv("REST_REQUEST_TIMEOUT"); ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultRequestTimeout
+}
+
+var serviceContext = context.Background()
+
+// ConfigureContext sets the service context from which the requests of handlers registered afterward are derived, so
+// they are cancelled when the service shuts down
+func ConfigureContext(ctx context.Context) {
+	serviceContext = ctx
+}
+
+type ContextHandler func(ctx context.Context) http.HandlerFunc
+
+// WithRequestContext derives the handler context from the span context. It is cancelled when the client disconnects or
+// the service shuts down, and times out after the request timeout, so database work for the request stops with it.
+func WithRequestContext(parent context.Context, next ContextHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(parent, RequestTimeout())
+		defer cancel()
+		stop := context.AfterFunc(r.Context(), cancel)
+		defer stop()
+		stopService := context.AfterFunc(serviceContext, cancel)
+		defer stopService()
+
+		next(ctx)(w, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"context"
+	"github.com/sirupsen/logrus/hooks/test"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitForCancel is a handler which reports the error ending its request context
+func waitForCancel(ctx context.Context) http.HandlerFunc {
+	l, _ := test.NewNullLogger()
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-ctx.Done():
+			WriteError(l)(w)(ctx.Err())
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+}
+
+func TestWithRequestContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		timeout    string
+		arrange    func(t *testing.T, r *http.Request) *http.Request
+		wantStatus int
+	}{
+		{
+			name:    "request timeout",
+			timeout: "10ms",
+			arrange: func(t *testing.T, r *http.Request) *http.Request {
+				return r
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:    "client disconnect",
+			timeout: "5s",
+			arrange: func(t *testing.T, r *http.Request) *http.Request {
+				ctx, cancel := context.WithCancel(r.Context())
+				time.AfterFunc(10*time.Millisecond, cancel)
+				return r.WithContext(ctx)
+			},
+			wantStatus: StatusClientClosedRequest,
+		},
+		{
+			name:    "service shutdown",
+			timeout: "5s",
+			arrange: func(t *testing.T, r *http.Request) *http.Request {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(10*time.Millisecond, cancel)
+				ConfigureContext(ctx)
+				t.Cleanup(func() { ConfigureContext(context.Background()) })
+				return r
+			},
+			wantStatus: StatusClientClosedRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REST_REQUEST_TIMEOUT", tt.timeout)
+			r := tt.arrange(t, httptest.NewRequest(http.MethodGet, "/", nil))
+			w := httptest.NewRecorder()
+
+			WithRequestContext(context.Background(), waitForCancel)(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWithRequestContextKeepsParentValues(t *testing.T) {
+	type key struct{}
+	parent := context.WithValue(context.Background(), key{}, "span")
+	var got interface{}
+
+	WithRequestContext(parent, func(ctx context.Context) http.HandlerFunc {
+		got = ctx.Value(key{})
+		return func(http.ResponseWriter, *http.Request) {}
+	})(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got != "span" {
+		t.Errorf("request context value = %v, want the parent's", got)
+	}
+}
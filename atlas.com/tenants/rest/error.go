@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"atlas-tenants/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jtumidanski/api2go/jsonapi"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+)
+
+// StatusClientClosedRequest is the non-standard status reported when the client abandons a request
+const StatusClientClosedRequest = 499
+
+var (
+	// ErrRequestCancelled is returned when the request context is cancelled, typically because the client disconnected
+	ErrRequestCancelled = domain.Cancelled("REQUEST_CANCELLED", "request was cancelled")
+	// ErrRequestTimeout is returned when the request does not complete within the request timeout
+	ErrRequestTimeout = domain.Unavailable("REQUEST_TIMEOUT", "request timed out")
+)
+
+// ErrorDocument is a JSON:API document carrying error objects
+type ErrorDocument struct {
+	Errors []jsonapi.Error `json:"errors"`
+}
+
+// StatusFor returns the HTTP status for a domain error kind
+func StatusFor(kind domain.Kind) int {
+	switch kind {
+	case domain.KindNotFound:
+		return http.StatusNotFound
+	case domain.KindConflict:
+		return http.StatusConflict
+	case domain.KindValidation:
+		return http.StatusBadRequest
+	case domain.KindPrecondition:
+		return http.StatusPreconditionFailed
+	case domain.KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case domain.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case domain.KindForbidden:
+		return http.StatusForbidden
+	case domain.KindCancelled:
+		return StatusClientClosedRequest
+	case domain.KindUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// statusText returns the reason phrase of a status, including the non-standard ones the service reports
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+// TransformError converts an error to a JSON:API error object. Errors which are not domain errors are reported as
+// internal errors without exposing their detail.
+func TransformError(err error) jsonapi.Error {
+	var de *domain.Error
+	if !errors.As(err, &de) {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			de = domain.NotFound("NOT_FOUND", "resource not found")
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			de, err = ErrRequestTimeout, ErrRequestTimeout.Wrap(err)
+		} else if errors.Is(err, context.Canceled) {
+			de, err = ErrRequestCancelled, ErrRequestCancelled.Wrap(err)
+		} else {
+			status := http.StatusInternalServerError
+			return jsonapi.Error{
+				Status: strconv.Itoa(status),
+				Code:   "INTERNAL_ERROR",
+				Title:  http.StatusText(status),
+			}
+		}
+	}
+
+	status := StatusFor(de.Kind())
+	result := jsonapi.Error{
+		Status: strconv.Itoa(status),
+		Code:   de.Code(),
+		Title:  statusText(status),
+		Detail: err.Error(),
+	}
+	if de.Pointer() != "" || de.Parameter() != "" {
+		result.Source = &jsonapi.ErrorSource{
+			Pointer:   de.Pointer(),
+			Parameter: de.Parameter(),
+		}
+	}
+	return result
+}
+
+// WriteError writes the error as a JSON:API errors document with the status of its domain error kind
+func WriteError(l logrus.FieldLogger) func(w http.ResponseWriter) func(err error) {
+	return func(w http.ResponseWriter) func(err error) {
+		return func(err error) {
+			e := TransformError(err)
+			status, _ := strconv.Atoi(e.Status)
+
+			res, merr := json.Marshal(ErrorDocument{Errors: []jsonapi.Error{e}})
+			if merr != nil {
+				l.WithError(merr).Error("Unable to marshal error document.")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			w.WriteHeader(status)
+			_, _ = w.Write(res)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strconv"
+	"testing"
+)
+
+func TestTransformError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "deadline exceeded", err: context.DeadlineExceeded, wantStatus: 503, wantCode: "REQUEST_TIMEOUT"},
+		{name: "wrapped deadline exceeded", err: fmt.Errorf("query: %w", context.DeadlineExceeded), wantStatus: 503, wantCode: "REQUEST_TIMEOUT"},
+		{name: "cancelled", err: context.Canceled, wantStatus: StatusClientClosedRequest, wantCode: "REQUEST_CANCELLED"},
+		{name: "wrapped cancelled", err: fmt.Errorf("query: %w", context.Canceled), wantStatus: StatusClientClosedRequest, wantCode: "REQUEST_CANCELLED"},
+		{name: "record not found", err: gorm.ErrRecordNotFound, wantStatus: 404, wantCode: "NOT_FOUND"},
+		{name: "domain error", err: ErrInvalidTenantId, wantStatus: 400, wantCode: "INVALID_TENANT_ID"},
+		{name: "other error", err: errors.New("boom"), wantStatus: 500, wantCode: "INTERNAL_ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TransformError(tt.err)
+			if got.Status != strconv.Itoa(tt.wantStatus) || got.Code != tt.wantCode {
+				t.Errorf("TransformError() = %s %s, want %d %s", got.Status, got.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
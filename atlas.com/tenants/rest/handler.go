@@ -1,6 +1,10 @@
 package rest
 
 import (
+	"atlas-tenants/auth"
+	"atlas-tenants/domain"
+	"atlas-tenants/metrics"
+	"bytes"
 	"context"
 	"github.com/Chronicle20/atlas-rest/server"
 	"github.com/google/uuid"
@@ -9,11 +13,22 @@ import (
 	"github.com/sirupsen/logrus"
 	"io"
 	"net/http"
+	"strings"
+)
+
+var (
+	// ErrInvalidRequestBody is returned when a request body cannot be read or is not a valid JSON:API document
+	ErrInvalidRequestBody = domain.Validation("INVALID_REQUEST_BODY", "request body is not a valid JSON:API document").WithPointer("/data")
+	// ErrInvalidTenantId is returned when a tenant ID is not a valid UUID
+	ErrInvalidTenantId = domain.Validation("INVALID_TENANT_ID", "tenant ID is not a valid UUID")
+	// ErrMissingPathParameter is returned when a required path parameter is absent
+	ErrMissingPathParameter = domain.Validation("MISSING_PATH_PARAMETER", "required path parameter not provided")
 )
 
 type HandlerDependency struct {
-	l   logrus.FieldLogger
-	ctx context.Context
+	l         logrus.FieldLogger
+	ctx       context.Context
+	principal auth.Principal
 }
 
 func (h HandlerDependency) Logger() logrus.FieldLogger {
@@ -24,6 +39,11 @@ func (h HandlerDependency) Context() context.Context {
 	return h.ctx
 }
 
+// Principal returns the authenticated caller of the request
+func (h HandlerDependency) Principal() auth.Principal {
+	return h.principal
+}
+
 type HandlerContext struct {
 	si jsonapi.ServerInformation
 }
@@ -42,15 +62,17 @@ func ParseInput[M any](d *HandlerDependency, c *HandlerContext, next InputHandle
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			WriteError(d.l)(w)(ErrInvalidRequestBody.Wrap(err))
 			return
 		}
-		defer r.Body.Close()
+		_ = r.Body.Close()
+		// Restore the body for handlers which also need the raw request
+		r.Body = io.NopCloser(bytes.NewReader(body))
 
 		err = jsonapi.Unmarshal(body, &model)
 		if err != nil {
 			d.l.WithError(err).Errorln("Deserializing input", err)
-			w.WriteHeader(http.StatusBadRequest)
+			WriteError(d.l)(w)(ErrInvalidRequestBody.WithDetail(err.Error()).Wrap(err))
 			return
 		}
 		next(d, c, model)(w, r)
@@ -60,10 +82,16 @@ func ParseInput[M any](d *HandlerDependency, c *HandlerContext, next InputHandle
 func RegisterHandler(l logrus.FieldLogger) func(si jsonapi.ServerInformation) func(handlerName string, handler GetHandler) http.HandlerFunc {
 	return func(si jsonapi.ServerInformation) func(handlerName string, handler GetHandler) http.HandlerFunc {
 		return func(handlerName string, handler GetHandler) http.HandlerFunc {
-			return server.RetrieveSpan(l, handlerName, context.Background(), func(sl logrus.FieldLogger, sctx context.Context) http.HandlerFunc {
+			sc := security
+			return metrics.InstrumentHandler(handlerName)(server.RetrieveSpan(l, handlerName, serviceContext, func(sl logrus.FieldLogger, sctx context.Context) http.HandlerFunc {
 				fl := sl.WithFields(logrus.Fields{"originator": handlerName, "type": "rest_handler"})
-				return handler(&HandlerDependency{l: fl, ctx: sctx}, &HandlerContext{si: si})
-			})
+				return Authorize(fl, sc, handlerName, func(p auth.Principal) http.HandlerFunc {
+					pl := fl.WithField("principal", p.Subject())
+					return WithRequestContext(auth.WithPrincipal(sctx, p), func(ctx context.Context) http.HandlerFunc {
+						return handler(&HandlerDependency{l: pl, ctx: ctx, principal: p}, &HandlerContext{si: si})
+					})
+				})
+			}))
 		}
 	}
 }
@@ -71,10 +99,16 @@ func RegisterHandler(l logrus.FieldLogger) func(si jsonapi.ServerInformation) fu
 func RegisterInputHandler[M any](l logrus.FieldLogger) func(si jsonapi.ServerInformation) func(handlerName string, handler InputHandler[M]) http.HandlerFunc {
 	return func(si jsonapi.ServerInformation) func(handlerName string, handler InputHandler[M]) http.HandlerFunc {
 		return func(handlerName string, handler InputHandler[M]) http.HandlerFunc {
-			return server.RetrieveSpan(l, handlerName, context.Background(), func(sl logrus.FieldLogger, sctx context.Context) http.HandlerFunc {
+			sc := security
+			return metrics.InstrumentHandler(handlerName)(server.RetrieveSpan(l, handlerName, serviceContext, func(sl logrus.FieldLogger, sctx context.Context) http.HandlerFunc {
 				fl := sl.WithFields(logrus.Fields{"originator": handlerName, "type": "rest_handler"})
-				return ParseInput[M](&HandlerDependency{l: fl, ctx: sctx}, &HandlerContext{si: si}, handler)
-			})
+				return Authorize(fl, sc, handlerName, func(p auth.Principal) http.HandlerFunc {
+					pl := fl.WithField("principal", p.Subject())
+					return WithRequestContext(auth.WithPrincipal(sctx, p), func(ctx context.Context) http.HandlerFunc {
+						return ParseInput[M](&HandlerDependency{l: pl, ctx: ctx, principal: p}, &HandlerContext{si: si}, handler)
+					})
+				})
+			}))
 		}
 	}
 }
@@ -86,13 +120,39 @@ func ParseTenantId(l logrus.FieldLogger, next TenantIdHandler) http.HandlerFunc
 		tenantId, err := uuid.Parse(mux.Vars(r)["tenantId"])
 		if err != nil {
 			l.WithError(err).Errorf("Unable to properly parse tenantId from path.")
-			w.WriteHeader(http.StatusBadRequest)
+			WriteError(l)(w)(ErrInvalidTenantId.Wrap(err))
+			return
+		}
+		next(tenantId)(w, r)
+	}
+}
+
+// ParseQueryTenantId parses the tenant ID in the named query parameter, rejecting a missing or malformed ID as
+// ErrInvalidTenantId
+func ParseQueryTenantId(l logrus.FieldLogger, param string, next TenantIdHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenantId, err := uuid.Parse(r.URL.Query().Get(param))
+		if err != nil {
+			l.WithError(err).Errorf("Unable to properly parse [%s] tenantId from query.", param)
+			WriteError(l)(w)(ErrInvalidTenantId.WithParameter(param).Wrap(err))
 			return
 		}
 		next(tenantId)(w, r)
 	}
 }
 
+// ParseQueryList splits a comma separated query parameter into its non-blank values
+func ParseQueryList(r *http.Request, param string) []string {
+	results := make([]string, 0)
+	for _, value := range strings.Split(r.URL.Query().Get(param), ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			results = append(results, value)
+		}
+	}
+	return results
+}
+
 type RouteIdHandler func(routeId string) http.HandlerFunc
 
 func ParseRouteId(l logrus.FieldLogger, next RouteIdHandler) http.HandlerFunc {
@@ -100,7 +160,7 @@ func ParseRouteId(l logrus.FieldLogger, next RouteIdHandler) http.HandlerFunc {
 		routeId, ok := mux.Vars(r)["routeId"]
 		if !ok {
 			l.Errorf("Route ID not provided in path.")
-			w.WriteHeader(http.StatusBadRequest)
+			WriteError(l)(w)(ErrMissingPathParameter.WithDetail("route ID not provided in path"))
 			return
 		}
 		next(routeId)(w, r)
@@ -114,7 +174,7 @@ func ParseVesselId(l logrus.FieldLogger, next VesselIdHandler) http.HandlerFunc
 		vesselId, ok := mux.Vars(r)["vesselId"]
 		if !ok {
 			l.Errorf("Vessel ID not provided in path.")
-			w.WriteHeader(http.StatusBadRequest)
+			WriteError(l)(w)(ErrMissingPathParameter.WithDetail("vessel ID not provided in path"))
 			return
 		}
 		next(vesselId)(w, r)
@@ -1,19 +1,27 @@
 package rest
 
 import (
+	"atlas-tenants/retry"
 	"context"
 	"github.com/Chronicle20/atlas-rest/requests"
 	"github.com/sirupsen/logrus"
 )
 
+// requestRetries bounds the attempts made for idempotent outbound requests
+const requestRetries = 3
+
+// MakeGetRequest creates a GET request, retried while it fails temporarily
 func MakeGetRequest[A any](url string) requests.Request[A] {
 	return func(l logrus.FieldLogger, ctx context.Context) (A, error) {
 		sd := requests.AddHeaderDecorator(requests.SpanHeaderDecorator(ctx))
 		td := requests.AddHeaderDecorator(requests.TenantHeaderDecorator(ctx))
-		return requests.MakeGetRequest[A](url, sd, td)(l, ctx)
+		return retry.Try(ctx, func(ctx context.Context, attempt int) (A, error) {
+			return requests.MakeGetRequest[A](url, sd, td)(l, ctx)
+		}, retry.MaxAttempts(requestRetries), retry.RetryIf(retry.Temporary), retry.Logger(l))
 	}
 }
 
+// MakePostRequest creates a POST request. It is not retried, as it may not be idempotent.
 func MakePostRequest[A any](url string, i interface{}) requests.Request[A] {
 	return func(l logrus.FieldLogger, ctx context.Context) (A, error) {
 		sd := requests.AddHeaderDecorator(requests.SpanHeaderDecorator(ctx))
@@ -22,6 +30,7 @@ func MakePostRequest[A any](url string, i interface{}) requests.Request[A] {
 	}
 }
 
+// MakePatchRequest creates a PATCH request. It is not retried, as it may not be idempotent.
 func MakePatchRequest[A any](url string, i interface{}) requests.Request[A] {
 	return func(l logrus.FieldLogger, ctx context.Context) (A, error) {
 		sd := requests.AddHeaderDecorator(requests.SpanHeaderDecorator(ctx))
@@ -30,10 +39,13 @@ func MakePatchRequest[A any](url string, i interface{}) requests.Request[A] {
 	}
 }
 
+// MakeDeleteRequest creates a DELETE request, retried while it fails temporarily
 func MakeDeleteRequest(url string) requests.EmptyBodyRequest {
 	return func(l logrus.FieldLogger, ctx context.Context) error {
 		sd := requests.AddHeaderDecorator(requests.SpanHeaderDecorator(ctx))
 		td := requests.AddHeaderDecorator(requests.TenantHeaderDecorator(ctx))
-		return requests.MakeDeleteRequest(url, sd, td)(l, ctx)
+		return retry.Do(ctx, func(ctx context.Context, attempt int) error {
+			return requests.MakeDeleteRequest(url, sd, td)(l, ctx)
+		}, retry.MaxAttempts(requestRetries), retry.RetryIf(retry.Temporary), retry.Logger(l))
 	}
 }
@@ -1,26 +1,233 @@
 package retry
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"math"
+	"math/rand/v2"
+	"syscall"
 	"time"
 )
 
-type RepeatableFunc func(attempt int) (retry bool, err error)
+// Func is an attempt producing a result. attempt starts at 1.
+type Func[T any] func(ctx context.Context, attempt int) (T, error)
 
-type RepeatableFuncWithResponse func(attempt int) (bool, interface{}, error)
+// Config controls how many attempts are made and how long to wait between them
+type Config struct {
+	maxAttempts     int
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	maxElapsedTime  time.Duration
+	multiplier      float64
+	jitter          float64
+	retryable       func(err error) bool
+	l               logrus.FieldLogger
+}
+
+// Configurator changes the default Config
+type Configurator func(c *Config)
+
+// DefaultConfig makes 5 attempts, waiting 100ms after the first and doubling up to 10s, with 20% jitter
+func DefaultConfig() Config {
+	return Config{
+		maxAttempts:     5,
+		initialInterval: 100 * time.Millisecond,
+		maxInterval:     10 * time.Second,
+		maxElapsedTime:  0,
+		multiplier:      2,
+		jitter:          0.2,
+		retryable:       func(err error) bool { return true },
+	}
+}
+
+// MaxAttempts sets the number of attempts made. 0 makes attempts until the context ends or the max elapsed time passes.
+func MaxAttempts(n int) Configurator {
+	return func(c *Config) {
+		c.maxAttempts = n
+	}
+}
+
+// InitialInterval sets the wait after the first failed attempt
+func InitialInterval(d time.Duration) Configurator {
+	return func(c *Config) {
+		c.initialInterval = d
+	}
+}
+
+// MaxInterval caps the wait between attempts
+func MaxInterval(d time.Duration) Configurator {
+	return func(c *Config) {
+		c.maxInterval = d
+	}
+}
+
+// MaxElapsedTime stops retrying once another wait would end later than d after the first attempt. 0 disables the limit.
+func MaxElapsedTime(d time.Duration) Configurator {
+	return func(c *Config) {
+		c.maxElapsedTime = d
+	}
+}
+
+// Multiplier sets the growth of the wait after each failed attempt
+func Multiplier(m float64) Configurator {
+	return func(c *Config) {
+		c.multiplier = m
+	}
+}
+
+// Jitter randomizes each wait by up to the given fraction of it, in either direction
+func Jitter(fraction float64) Configurator {
+	return func(c *Config) {
+		c.jitter = fraction
+	}
+}
+
+// RetryIf retries only the errors the predicate accepts. Permanent errors are never retried.
+func RetryIf(retryable func(err error) bool) Configurator {
+	return func(c *Config) {
+		c.retryable = retryable
+	}
+}
+
+// Logger logs each failed attempt that will be retried
+func Logger(l logrus.FieldLogger) Configurator {
+	return func(c *Config) {
+		c.l = l
+	}
+}
+
+// ExhaustedError is returned when the attempts or elapsed time allowed ran out before an attempt succeeded
+type ExhaustedError struct {
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+func (e *ExhaustedError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts in %s: %v", e.Attempts, e.Elapsed.Round(time.Millisecond), e.Err)
+}
+
+func (e *ExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error as not retryable. Try returns the error unwrapped.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent returns true if the error was marked with Permanent
+func IsPermanent(err error) bool {
+	var pe permanentError
+	return errors.As(err, &pe)
+}
+
+// Try calls fn until it succeeds, returns a permanent or non-retryable error, runs out of attempts or elapsed time, or
+// the context ends
+func Try[T any](ctx context.Context, fn Func[T], configurators ...Configurator) (T, error) {
+	c := DefaultConfig()
+	for _, configurator := range configurators {
+		configurator(&c)
+	}
+
+	var zero T
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		result, err := fn(ctx, attempt)
+		if err == nil {
+			return result, nil
+		}
+
+		var pe permanentError
+		if errors.As(err, &pe) {
+			return zero, pe.err
+		}
+		if ctx.Err() != nil {
+			return zero, errors.Join(ctx.Err(), err)
+		}
+		if !c.retryable(err) {
+			return zero, err
+		}
 
-func Try(fn RepeatableFunc, retries int) error {
-	attempt := 1
-	for {
-		cont, err := fn(attempt)
-		if !cont || err == nil {
-			return nil
+		wait := c.backoff(attempt)
+		elapsed := time.Since(start)
+		if (c.maxAttempts > 0 && attempt >= c.maxAttempts) || (c.maxElapsedTime > 0 && elapsed+wait > c.maxElapsedTime) {
+			return zero, &ExhaustedError{Attempts: attempt, Elapsed: elapsed, Err: err}
 		}
-		attempt++
-		if attempt > retries {
-			return errors.New("max retry reached")
+		if c.l != nil {
+			c.l.WithError(err).Warnf("Attempt [%d] failed. Retrying in [%s].", attempt, wait.Round(time.Millisecond))
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return zero, errors.Join(ctx.Err(), err)
+		case <-t.C:
 		}
-		time.Sleep(1 * time.Second)
 	}
 }
 
+// Do calls fn until it succeeds, as Try does for functions without a result
+func Do(ctx context.Context, fn func(ctx context.Context, attempt int) error, configurators ...Configurator) error {
+	_, err := Try(ctx, func(ctx context.Context, attempt int) (struct{}, error) {
+		return struct{}{}, fn(ctx, attempt)
+	}, configurators...)
+	return err
+}
+
+// backoff returns the wait after the given failed attempt
+func (c Config) backoff(attempt int) time.Duration {
+	wait := float64(c.initialInterval) * math.Pow(c.multiplier, float64(attempt-1))
+	if c.maxInterval > 0 {
+		wait = math.Min(wait, float64(c.maxInterval))
+	}
+	if c.jitter > 0 {
+		wait += wait * c.jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// transientErrors are failures of the connection rather than of the request, which another attempt may not meet
+var transientErrors = []error{
+	io.EOF,
+	io.ErrUnexpectedEOF,
+	syscall.ECONNABORTED,
+	syscall.ECONNREFUSED,
+	syscall.ECONNRESET,
+	syscall.EPIPE,
+}
+
+// Temporary retries broken or refused connections, and errors reporting themselves timed out or temporary. Any other
+// error is not retried.
+func Temporary(err error) bool {
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	var to interface{ Timeout() bool }
+	if errors.As(err, &to) && to.Timeout() {
+		return true
+	}
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
@@ -0,0 +1,226 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var errAttempt = errors.New("attempt failed")
+
+// temporaryError reports itself temporary, or not, as net errors do
+type temporaryError bool
+
+func (e temporaryError) Error() string {
+	return "temporary error"
+}
+
+func (e temporaryError) Temporary() bool {
+	return bool(e)
+}
+
+// failing fails the first n attempts with err, and counts the attempts made
+func failing(n int, err error, calls *int) Func[int] {
+	return func(ctx context.Context, attempt int) (int, error) {
+		*calls++
+		if attempt <= n {
+			return 0, err
+		}
+		return attempt, nil
+	}
+}
+
+func TestTryAttempts(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		err          error
+		retryable    func(err error) bool
+		wantCalls    int
+		wantResult   int
+		wantErr      error
+		wantExhausts bool
+	}{
+		{name: "first attempt succeeds", failures: 0, err: errAttempt, wantCalls: 1, wantResult: 1},
+		{name: "succeeds after retries", failures: 2, err: errAttempt, wantCalls: 3, wantResult: 3},
+		{name: "attempts exhausted", failures: 5, err: errAttempt, wantCalls: 3, wantErr: errAttempt, wantExhausts: true},
+		{name: "not retryable", failures: 5, err: errAttempt, retryable: Temporary, wantCalls: 1, wantErr: errAttempt},
+		{name: "retryable", failures: 2, err: io.ErrUnexpectedEOF, retryable: Temporary, wantCalls: 3, wantResult: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configurators := []Configurator{MaxAttempts(3), InitialInterval(time.Millisecond), Jitter(0)}
+			if tt.retryable != nil {
+				configurators = append(configurators, RetryIf(tt.retryable))
+			}
+
+			calls := 0
+			result, err := Try(context.Background(), failing(tt.failures, tt.err, &calls), configurators...)
+			if calls != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil || result != tt.wantResult {
+					t.Fatalf("Try() = %d, %v, want %d, nil", result, err, tt.wantResult)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Try() error = %v, want %v", err, tt.wantErr)
+			}
+			var ee *ExhaustedError
+			if errors.As(err, &ee) != tt.wantExhausts {
+				t.Fatalf("Try() error = %v, exhausted want %t", err, tt.wantExhausts)
+			}
+			if tt.wantExhausts && ee.Attempts != tt.wantCalls {
+				t.Fatalf("ExhaustedError.Attempts = %d, want %d", ee.Attempts, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := DefaultConfig()
+	MaxInterval(time.Second)(&c)
+	Jitter(0)(&c)
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 100 * time.Millisecond},
+		{attempt: 2, want: 200 * time.Millisecond},
+		{attempt: 4, want: 800 * time.Millisecond},
+		{attempt: 5, want: time.Second},
+		{attempt: 30, want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d", tt.attempt), func(t *testing.T) {
+			if got := c.backoff(tt.attempt); got != tt.want {
+				t.Fatalf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	c := DefaultConfig()
+	MaxInterval(time.Second)(&c)
+	Jitter(0.2)(&c)
+
+	for i := 0; i < 100; i++ {
+		got := c.backoff(30)
+		if got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("backoff(30) = %s, want within 20%% of 1s", got)
+		}
+	}
+}
+
+func TestMaxElapsedTime(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	_, err := Try(context.Background(), failing(100, errAttempt, &calls),
+		MaxAttempts(0), InitialInterval(20*time.Millisecond), Jitter(0), MaxElapsedTime(50*time.Millisecond))
+
+	var ee *ExhaustedError
+	if !errors.As(err, &ee) || !errors.Is(err, errAttempt) {
+		t.Fatalf("Try() error = %v, want ExhaustedError wrapping %v", err, errAttempt)
+	}
+	// the 40ms wait after the second attempt would end past the 50ms allowed
+	if calls != 2 || ee.Attempts != 2 {
+		t.Fatalf("calls = %d, Attempts = %d, want 2", calls, ee.Attempts)
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Fatalf("Try() took %s, want less than 50ms", elapsed)
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), func(ctx context.Context, attempt int) error {
+		calls++
+		return fmt.Errorf("wrapped: %w", Permanent(errAttempt))
+	}, MaxAttempts(5), InitialInterval(time.Millisecond))
+
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+	if err != errAttempt {
+		t.Fatalf("Do() error = %v, want the unwrapped %v", err, errAttempt)
+	}
+	if IsPermanent(err) {
+		t.Fatalf("IsPermanent() = true for the returned error, want false")
+	}
+	if Permanent(nil) != nil {
+		t.Fatalf("Permanent(nil) != nil")
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	t.Run("during wait", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		calls := 0
+		start := time.Now()
+		_, err := Try(ctx, failing(100, errAttempt, &calls), MaxAttempts(0), InitialInterval(time.Hour))
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errAttempt) {
+			t.Fatalf("Try() error = %v, want %v joined with %v", err, context.DeadlineExceeded, errAttempt)
+		}
+		if calls != 1 {
+			t.Fatalf("calls = %d, want 1", calls)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("Try() took %s after the context ended", elapsed)
+		}
+	})
+	t.Run("during attempt", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		calls := 0
+		err := Do(ctx, func(ctx context.Context, attempt int) error {
+			calls++
+			cancel()
+			return errAttempt
+		}, MaxAttempts(5), InitialInterval(time.Millisecond))
+		if !errors.Is(err, context.Canceled) || !errors.Is(err, errAttempt) {
+			t.Fatalf("Do() error = %v, want %v joined with %v", err, context.Canceled, errAttempt)
+		}
+		if calls != 1 {
+			t.Fatalf("calls = %d, want 1", calls)
+		}
+	})
+}
+
+func TestTemporary(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "unclassified", err: errAttempt, want: false},
+		{name: "unexpected eof", err: fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "eof", err: io.EOF, want: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: true},
+		{name: "connection reset", err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, want: true},
+		{name: "broken pipe", err: fmt.Errorf("write: %w", syscall.EPIPE), want: true},
+		{name: "timeout", err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}, want: true},
+		{name: "not found", err: &net.DNSError{Err: "no such host", IsNotFound: true}, want: false},
+		{name: "reports temporary", err: temporaryError(true), want: true},
+		{name: "reports not temporary", err: temporaryError(false), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Temporary(tt.err); got != tt.want {
+				t.Fatalf("Temporary(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -6,14 +6,20 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 )
 
+// DefaultDrainPeriod is how long the service stays up, reporting not ready, after a termination signal
+const DefaultDrainPeriod = 5 * time.Second
+
 type Manager struct {
-	termChan  chan os.Signal
-	doneChan  chan struct{}
-	waitGroup *sync.WaitGroup
-	context   context.Context
-	cancel    context.CancelFunc
+	termChan    chan os.Signal
+	drainChan   chan struct{}
+	doneChan    chan struct{}
+	drainPeriod time.Duration
+	waitGroup   *sync.WaitGroup
+	context     context.Context
+	cancel      context.CancelFunc
 }
 
 var manager *Manager
@@ -24,11 +30,13 @@ func GetTeardownManager() *Manager {
 		ctx, cancel := context.WithCancel(context.Background())
 
 		manager = &Manager{
-			termChan:  make(chan os.Signal),
-			doneChan:  make(chan struct{}),
-			waitGroup: &sync.WaitGroup{},
-			context:   ctx,
-			cancel:    cancel,
+			termChan:    make(chan os.Signal, 1),
+			drainChan:   make(chan struct{}),
+			doneChan:    make(chan struct{}),
+			drainPeriod: drainPeriod(),
+			waitGroup:   &sync.WaitGroup{},
+			context:     ctx,
+			cancel:      cancel,
 		}
 
 		signal.Notify(manager.termChan, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGHUP)
@@ -36,9 +44,27 @@ func GetTeardownManager() *Manager {
 	return manager
 }
 
+// drainPeriod returns the drain period from SHUTDOWN_DRAIN_PERIOD
+func drainPeriod() time.Duration {
+	if v, ok := os.LookupEnv("SHUTDOWN_DRAIN_PERIOD"); ok {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return DefaultDrainPeriod
+}
+
+// DrainFunc runs f as soon as teardown starts, before the drain period elapses
+func (m *Manager) DrainFunc(f func()) {
+	go func() {
+		<-m.drainChan
+		f()
+	}()
+}
+
 func (m *Manager) TeardownFunc(f func()) {
+	m.waitGroup.Add(1)
 	go func() {
-		m.waitGroup.Add(1)
 		defer m.waitGroup.Done()
 		<-m.doneChan
 		f()
@@ -47,6 +73,8 @@ func (m *Manager) TeardownFunc(f func()) {
 
 func (m *Manager) Wait() {
 	<-m.termChan
+	close(m.drainChan)
+	time.Sleep(m.drainPeriod)
 	close(m.doneChan)
 	m.cancel()
 	m.waitGroup.Wait()
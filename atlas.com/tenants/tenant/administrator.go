@@ -27,7 +27,7 @@ func DeleteTenant(db *gorm.DB, id uuid.UUID) error {
 	err := db.Where("id = ?", id).First(&e).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("tenant not found")
+			return ErrNotFound.Wrap(err)
 		}
 		return err
 	}
@@ -35,4 +35,4 @@ func DeleteTenant(db *gorm.DB, id uuid.UUID) error {
 	return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
 		return tx.Delete(&e).Error
 	})
-}
\ No newline at end of file
+}
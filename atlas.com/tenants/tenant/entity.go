@@ -8,19 +8,15 @@ import (
 // Entity represents a tenant in the database
 type Entity struct {
 	gorm.Model
-	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
-	Name         string    `gorm:"not null"`
-	Region       string    `gorm:"not null"`
-	MajorVersion uint16    `gorm:"not null"`
-	MinorVersion uint16    `gorm:"not null"`
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	Name         string     `gorm:"not null"`
+	Region       string     `gorm:"not null"`
+	MajorVersion uint16     `gorm:"not null"`
+	MinorVersion uint16     `gorm:"not null"`
+	ParentID     *uuid.UUID `gorm:"type:uuid"`
 }
 
 // TableName overrides the table name
 func (Entity) TableName() string {
 	return "tenants"
 }
-
-// MigrateEntities creates the tenant table in the database
-func MigrateEntities(db *gorm.DB) error {
-	return db.AutoMigrate(&Entity{})
-}
\ No newline at end of file
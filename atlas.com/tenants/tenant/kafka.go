@@ -1,10 +1,15 @@
 package tenant
 
 import (
+	"atlas-tenants/kafka/message"
+	"context"
 	"github.com/Chronicle20/atlas-kafka/producer"
 	"github.com/Chronicle20/atlas-model/model"
 	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
+	"os"
+	"strconv"
+	"time"
 )
 
 const (
@@ -12,71 +17,234 @@ const (
 	EventTypeCreated       = "CREATED"
 	EventTypeUpdated       = "UPDATED"
 	EventTypeDeleted       = "DELETED"
+	EventTypeCommandFailed = "COMMAND_FAILED"
+
+	EventTopicTenantSnapshot = "tenant.snapshot"
+	EnvSnapshotOnStartup     = "TENANT_SNAPSHOT_ON_STARTUP"
+	EnvTombstoneWindow       = "TENANT_SNAPSHOT_TOMBSTONE_WINDOW"
+	DefaultTombstoneWindow   = 7 * 24 * time.Hour
+
+	EnvEventFormat      = "TENANT_EVENT_FORMAT"
+	EventFormatEnvelope = "envelope"
+	EventFormatLegacy   = "legacy"
+
+	EnvCommandTopic   = "COMMAND_TOPIC_TENANT"
+	CommandTypeCreate = "CREATE"
+	CommandTypeUpdate = "UPDATE"
+	CommandTypeDelete = "DELETE"
 )
 
-// StatusEvent is a generic event for tenant status changes
+// Event is the versioned envelope of a tenant status event. It keeps the tenantId, commandId, type and body of the
+// legacy StatusEvent, so consumers of the legacy shape can read it.
+type Event[T any] struct {
+	EventId       uuid.UUID     `json:"eventId"`
+	SchemaVersion int           `json:"schemaVersion"`
+	Type          string        `json:"type"`
+	OccurredAt    time.Time     `json:"occurredAt"`
+	Actor         message.Actor `json:"actor"`
+	CorrelationId string        `json:"correlationId,omitempty"`
+	CommandId     *uuid.UUID    `json:"commandId,omitempty"`
+	TenantId      uuid.UUID     `json:"tenantId"`
+	Body          T             `json:"body"`
+}
+
+// StatusEvent is the legacy shape of a tenant status event, emitted unless the envelope event format is configured.
+// Events caused by a command carry its ID.
 type StatusEvent[T any] struct {
-	TenantId uuid.UUID `json:"tenantId"`
-	Type     string    `json:"type"`
-	Body     T         `json:"body"`
+	TenantId  uuid.UUID  `json:"tenantId"`
+	CommandId *uuid.UUID `json:"commandId,omitempty"`
+	Type      string     `json:"type"`
+	Body      T          `json:"body"`
 }
 
-// StatusEventCreatedBody is the body for a tenant created event
-type StatusEventCreatedBody struct {
-	Name         string `json:"name"`
-	Region       string `json:"region"`
-	MajorVersion uint16 `json:"majorVersion"`
-	MinorVersion uint16 `json:"minorVersion"`
+// StatusEventBody is the body for a tenant created or deleted event, carrying the tenant attributes
+type StatusEventBody struct {
+	Name         string     `json:"name"`
+	Region       string     `json:"region"`
+	MajorVersion uint16     `json:"majorVersion"`
+	MinorVersion uint16     `json:"minorVersion"`
+	ParentId     *uuid.UUID `json:"parentId,omitempty"`
 }
 
-// StatusEventUpdatedBody is the body for a tenant updated event
+// StatusEventUpdatedBody is the body for a tenant updated event, carrying the tenant attributes after the update, the
+// attributes before it, and the names of the attributes which changed
 type StatusEventUpdatedBody struct {
-	Name         string `json:"name"`
-	Region       string `json:"region"`
-	MajorVersion uint16 `json:"majorVersion"`
-	MinorVersion uint16 `json:"minorVersion"`
+	StatusEventBody
+	Previous      StatusEventBody `json:"previous"`
+	ChangedFields []string        `json:"changedFields"`
 }
 
-// StatusEventDeletedBody is the body for a tenant deleted event
-type StatusEventDeletedBody struct {
+// StatusEventCommandFailedBody is the body for the event reporting a command which could not be applied
+type StatusEventCommandFailedBody struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Snapshot is the full current state of a tenant, published to the log-compacted snapshot topic keyed by tenant ID
+type Snapshot struct {
+	TenantId     uuid.UUID  `json:"tenantId"`
+	Name         string     `json:"name"`
+	Region       string     `json:"region"`
+	MajorVersion uint16     `json:"majorVersion"`
+	MinorVersion uint16     `json:"minorVersion"`
+	ParentId     *uuid.UUID `json:"parentId,omitempty"`
+}
+
+// Command is a generic command requesting a tenant change
+type Command[T any] struct {
+	CommandId uuid.UUID `json:"commandId"`
+	TenantId  uuid.UUID `json:"tenantId"`
+	Type      string    `json:"type"`
+	Body      T         `json:"body"`
+}
+
+// CommandAttributesBody is the body for a tenant create or update command, carrying the tenant attributes
+type CommandAttributesBody struct {
 	Name         string `json:"name"`
 	Region       string `json:"region"`
 	MajorVersion uint16 `json:"majorVersion"`
 	MinorVersion uint16 `json:"minorVersion"`
+	ParentId     string `json:"parentId,omitempty"`
 }
 
-// CreateStatusEventProvider creates a provider for tenant status events
-func CreateStatusEventProvider(tenantId uuid.UUID, eventType string, name string, region string, majorVersion uint16, minorVersion uint16) model.Provider[[]kafka.Message] {
-	var body interface{}
-	switch eventType {
-	case "CREATED":
-		body = StatusEventCreatedBody{
-			Name:         name,
-			Region:       region,
-			MajorVersion: majorVersion,
-			MinorVersion: minorVersion,
-		}
-	case "UPDATED":
-		body = StatusEventUpdatedBody{
-			Name:         name,
-			Region:       region,
-			MajorVersion: majorVersion,
-			MinorVersion: minorVersion,
+// CommandDeleteBody is the body for a tenant delete command
+type CommandDeleteBody struct {
+}
+
+// EventFormat returns the shape of tenant status events from TENANT_EVENT_FORMAT. Events keep the legacy shape until
+// consumers opt in to the envelope.
+func EventFormat() string {
+	if os.Getenv(EnvEventFormat) == EventFormatEnvelope {
+		return EventFormatEnvelope
+	}
+	return EventFormatLegacy
+}
+
+// SnapshotOnStartup returns true unless TENANT_SNAPSHOT_ON_STARTUP disables republishing every tenant to the snapshot
+// topic at startup
+func SnapshotOnStartup() bool {
+	if v, ok := os.LookupEnv(EnvSnapshotOnStartup); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
 		}
-	case "DELETED":
-		body = StatusEventDeletedBody{
-			Name:         name,
-			Region:       region,
-			MajorVersion: majorVersion,
-			MinorVersion: minorVersion,
+	}
+	return true
+}
+
+// TombstoneWindow returns how far back a reconciliation republishes tombstones for deleted tenants, configured by
+// TENANT_SNAPSHOT_TOMBSTONE_WINDOW
+func TombstoneWindow() time.Duration {
+	if v, ok := os.LookupEnv(EnvTombstoneWindow); ok {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
 		}
 	}
+	return DefaultTombstoneWindow
+}
+
+// SnapshotProvider creates a provider for the snapshot of the current state of a tenant
+func SnapshotProvider(m Model) model.Provider[[]kafka.Message] {
+	return producer.SingleMessageProvider([]byte(m.Id().String()), Snapshot{
+		TenantId:     m.Id(),
+		Name:         m.Name(),
+		Region:       m.Region(),
+		MajorVersion: m.MajorVersion(),
+		MinorVersion: m.MinorVersion(),
+		ParentId:     parentIdReference(m.ParentId()),
+	})
+}
+
+// TombstoneProvider creates a provider for the tombstone removing a deleted tenant from the snapshot topic
+func TombstoneProvider(tenantId uuid.UUID) model.Provider[[]kafka.Message] {
+	return model.FixedProvider([]kafka.Message{{Key: []byte(tenantId.String())}})
+}
+
+// CreateStatusEventProvider creates a provider for tenant created and deleted events, correlated with the command
+// handled in ctx
+func CreateStatusEventProvider(ctx context.Context, eventType string, m Model) model.Provider[[]kafka.Message] {
+	return statusEventProvider(ctx, m.Id(), eventType, statusEventBody(m))
+}
+
+// UpdatedStatusEventProvider creates a provider for the tenant updated event changing before to after, correlated with
+// the command handled in ctx
+func UpdatedStatusEventProvider(ctx context.Context, before Model, after Model) model.Provider[[]kafka.Message] {
+	return statusEventProvider(ctx, after.Id(), EventTypeUpdated, StatusEventUpdatedBody{
+		StatusEventBody: statusEventBody(after),
+		Previous:        statusEventBody(before),
+		ChangedFields:   ChangedFields(before, after),
+	})
+}
+
+// ChangedFields returns the event body names of the attributes which differ between two states of a tenant
+func ChangedFields(before Model, after Model) []string {
+	results := make([]string, 0)
+	if before.Name() != after.Name() {
+		results = append(results, "name")
+	}
+	if before.Region() != after.Region() {
+		results = append(results, "region")
+	}
+	if before.MajorVersion() != after.MajorVersion() {
+		results = append(results, "majorVersion")
+	}
+	if before.MinorVersion() != after.MinorVersion() {
+		results = append(results, "minorVersion")
+	}
+	if before.ParentId() != after.ParentId() {
+		results = append(results, "parentId")
+	}
+	return results
+}
 
+// statusEventBody returns the attributes of a tenant as they are carried by status events
+func statusEventBody(m Model) StatusEventBody {
+	return StatusEventBody{
+		Name:         m.Name(),
+		Region:       m.Region(),
+		MajorVersion: m.MajorVersion(),
+		MinorVersion: m.MinorVersion(),
+		ParentId:     parentIdReference(m.ParentId()),
+	}
+}
+
+// CommandFailedEventProvider creates a provider for the event reporting the command handled in ctx as failed. The
+// failure of a command naming no tenant, such as CREATE, is keyed by the command ID, so such failures do not all share
+// the key of the nil tenant.
+func CommandFailedEventProvider(ctx context.Context, tenantId uuid.UUID, code string, detail string) model.Provider[[]kafka.Message] {
 	key := []byte(tenantId.String())
-	value := StatusEvent[interface{}]{
-		TenantId: tenantId,
-		Type:     eventType,
-		Body:     body,
+	if commandId := message.CommandId(ctx); tenantId == uuid.Nil && commandId != nil {
+		key = []byte(commandId.String())
+	}
+	return keyedStatusEventProvider(ctx, key, tenantId, EventTypeCommandFailed, StatusEventCommandFailedBody{
+		Code:   code,
+		Detail: detail,
+	})
+}
+
+// statusEventProvider creates a provider for a tenant status event in the configured event format, keyed by tenant ID
+func statusEventProvider[T any](ctx context.Context, tenantId uuid.UUID, eventType string, body T) model.Provider[[]kafka.Message] {
+	return keyedStatusEventProvider(ctx, []byte(tenantId.String()), tenantId, eventType, body)
+}
+
+// keyedStatusEventProvider creates a provider for a tenant status event in the configured event format
+func keyedStatusEventProvider[T any](ctx context.Context, key []byte, tenantId uuid.UUID, eventType string, body T) model.Provider[[]kafka.Message] {
+	if EventFormat() == EventFormatLegacy {
+		return producer.SingleMessageProvider(key, StatusEvent[T]{
+			TenantId:  tenantId,
+			CommandId: message.CommandId(ctx),
+			Type:      eventType,
+			Body:      body,
+		})
 	}
-	return producer.SingleMessageProvider(key, value)
+	return producer.SingleMessageProvider(key, Event[T]{
+		EventId:       uuid.New(),
+		SchemaVersion: message.SchemaVersion,
+		Type:          eventType,
+		OccurredAt:    time.Now().UTC(),
+		Actor:         message.ActorFromContext(ctx),
+		CorrelationId: message.CorrelationId(ctx),
+		CommandId:     message.CommandId(ctx),
+		TenantId:      tenantId,
+		Body:          body,
+	})
 }
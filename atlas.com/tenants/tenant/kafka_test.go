@@ -0,0 +1,166 @@
+package tenant
+
+import (
+	"atlas-tenants/auth"
+	"atlas-tenants/kafka/message"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/segmentio/kafka-go"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// envelopeSchemaId is the $id the event schemas reference the shared envelope schema by
+const envelopeSchemaId = "urn:atlas-tenants:tenant.status:envelope"
+
+// commandCtx returns a context handling a command sent by the principal
+func commandCtx(commandId uuid.UUID) context.Context {
+	p := auth.NewBuilder("tenant_command").SetMethod(auth.MethodCommand).Build()
+	return message.WithCommandId(auth.WithPrincipal(context.Background(), p), commandId)
+}
+
+// produce returns the single message the provider produces
+func produce(t *testing.T, p model.Provider[[]kafka.Message]) []byte {
+	t.Helper()
+	ms, err := p()
+	if err != nil {
+		t.Fatalf("unable to produce event: %v", err)
+	}
+	if len(ms) != 1 {
+		t.Fatalf("produced %d messages, want 1", len(ms))
+	}
+	return ms[0].Value
+}
+
+// compileSchema compiles the documented JSON Schema of the event type, with the envelope schema it references
+func compileSchema(t *testing.T, eventType string) *jsonschema.Schema {
+	t.Helper()
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+	f, err := os.Open(filepath.Join("schema", "envelope.schema.json"))
+	if err != nil {
+		t.Fatalf("unable to open envelope schema: %v", err)
+	}
+	defer f.Close()
+	envelope, err := jsonschema.UnmarshalJSON(f)
+	if err != nil {
+		t.Fatalf("unable to read envelope schema: %v", err)
+	}
+	if err = c.AddResource(envelopeSchemaId, envelope); err != nil {
+		t.Fatalf("unable to add envelope schema: %v", err)
+	}
+	s, err := c.Compile(filepath.Join("schema", strings.ToLower(eventType)+".schema.json"))
+	if err != nil {
+		t.Fatalf("unable to compile schema of %s: %v", eventType, err)
+	}
+	return s
+}
+
+// testModel returns a tenant with a parent
+func testModel(majorVersion uint16) Model {
+	return NewBuilder().SetName("tenant").SetRegion("GMS").SetMajorVersion(majorVersion).SetMinorVersion(1).SetParentId(uuid.New()).Build()
+}
+
+func TestEventSchemas(t *testing.T) {
+	t.Setenv(EnvEventFormat, EventFormatEnvelope)
+	before := testModel(83)
+	after := NewBuilder().SetId(before.Id()).SetName("tenant").SetRegion("GMS").SetMajorVersion(84).SetMinorVersion(1).Build()
+	tests := []struct {
+		name      string
+		eventType string
+		provider  func(ctx context.Context) model.Provider[[]kafka.Message]
+	}{
+		{name: "created", eventType: EventTypeCreated, provider: func(ctx context.Context) model.Provider[[]kafka.Message] {
+			return CreateStatusEventProvider(ctx, EventTypeCreated, before)
+		}},
+		{name: "updated", eventType: EventTypeUpdated, provider: func(ctx context.Context) model.Provider[[]kafka.Message] {
+			return UpdatedStatusEventProvider(ctx, before, after)
+		}},
+		{name: "deleted", eventType: EventTypeDeleted, provider: func(ctx context.Context) model.Provider[[]kafka.Message] {
+			return CreateStatusEventProvider(ctx, EventTypeDeleted, after)
+		}},
+		{name: "command failed", eventType: EventTypeCommandFailed, provider: func(ctx context.Context) model.Provider[[]kafka.Message] {
+			return CommandFailedEventProvider(ctx, uuid.Nil, "TENANT_NOT_FOUND", "tenant not found")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := compileSchema(t, tt.eventType)
+			ctxs := map[string]context.Context{"command": commandCtx(uuid.New())}
+			if tt.eventType != EventTypeCommandFailed {
+				ctxs["system"] = context.Background()
+			}
+			for name, ctx := range ctxs {
+				v, err := jsonschema.UnmarshalJSON(bytes.NewReader(produce(t, tt.provider(ctx))))
+				if err != nil {
+					t.Fatalf("event is not valid JSON: %v", err)
+				}
+				if err = s.Validate(v); err != nil {
+					t.Errorf("%s event does not match its schema: %v", name, err)
+				}
+			}
+			// An event of another type must not match, so the schema constrains the type
+			other := CreateStatusEventProvider(context.Background(), EventTypeCreated, before)
+			if tt.eventType != EventTypeCreated {
+				v, _ := jsonschema.UnmarshalJSON(bytes.NewReader(produce(t, other)))
+				if s.Validate(v) == nil {
+					t.Errorf("CREATED event matches the schema of %s", tt.eventType)
+				}
+			}
+		})
+	}
+}
+
+func TestEventEnvelope(t *testing.T) {
+	t.Setenv(EnvEventFormat, EventFormatEnvelope)
+	commandId := uuid.New()
+	var es []Event[StatusEventBody]
+	for i := 0; i < 2; i++ {
+		var e Event[StatusEventBody]
+		if err := json.Unmarshal(produce(t, CreateStatusEventProvider(commandCtx(commandId), EventTypeCreated, testModel(83))), &e); err != nil {
+			t.Fatalf("unable to decode event: %v", err)
+		}
+		es = append(es, e)
+	}
+
+	if es[0].EventId == uuid.Nil || es[0].EventId == es[1].EventId {
+		t.Errorf("event IDs = %s, %s, want distinct IDs", es[0].EventId, es[1].EventId)
+	}
+	if es[0].SchemaVersion != message.SchemaVersion || es[0].OccurredAt.IsZero() {
+		t.Errorf("event = %+v, want schema version %d and an occurrence time", es[0], message.SchemaVersion)
+	}
+	if want := (message.Actor{Subject: "tenant_command", Method: auth.MethodCommand}); es[0].Actor != want {
+		t.Errorf("actor = %+v, want %+v", es[0].Actor, want)
+	}
+	if es[0].CorrelationId != commandId.String() {
+		t.Errorf("correlation ID = %s, want command ID %s", es[0].CorrelationId, commandId)
+	}
+	if es[0].CommandId == nil || *es[0].CommandId != commandId {
+		t.Errorf("command ID = %v, want %s", es[0].CommandId, commandId)
+	}
+}
+
+func TestLegacyEventFormatByDefault(t *testing.T) {
+	t.Setenv(EnvEventFormat, "")
+	commandId := uuid.New()
+
+	var e map[string]json.RawMessage
+	if err := json.Unmarshal(produce(t, CreateStatusEventProvider(commandCtx(commandId), EventTypeCreated, testModel(83))), &e); err != nil {
+		t.Fatalf("unable to decode event: %v", err)
+	}
+	var keys []string
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if got, want := strings.Join(keys, ","), "body,commandId,tenantId,type"; got != want {
+		t.Errorf("legacy event members = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package tenant
+
+import (
+	"atlas-tenants/database"
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sync"
+)
+
+// snapshotLocks serialize the changes to a tenant with the reconciliation of its snapshot within the process, so a
+// reconciliation cannot publish a snapshot older than the one published by a concurrent change. Each tenant has its own
+// lock, which is removed when no one holds or awaits it.
+var snapshotLocks = newKeyedLock()
+
+// keyedLock is a set of mutexes keyed by tenant ID
+type keyedLock struct {
+	mu    sync.Mutex
+	locks map[uuid.UUID]*keyedMutex
+}
+
+// keyedMutex is the mutex of one key, with the number of callers holding or awaiting it
+type keyedMutex struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyedLock() *keyedLock {
+	return &keyedLock{locks: make(map[uuid.UUID]*keyedMutex)}
+}
+
+// lock locks the mutex of the ID, returning the function which unlocks it
+func (k *keyedLock) lock(id uuid.UUID) func() {
+	k.mu.Lock()
+	m, ok := k.locks[id]
+	if !ok {
+		m = &keyedMutex{}
+		k.locks[id] = m
+	}
+	m.refs++
+	k.mu.Unlock()
+
+	m.Lock()
+	return func() {
+		m.Unlock()
+		k.mu.Lock()
+		m.refs--
+		if m.refs == 0 {
+			delete(k.locks, id)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// LockSnapshot locks the snapshot of a tenant across every replica sharing the database, returning the function which
+// unlocks it. On PostgreSQL it holds a session advisory lock keyed by the tenant ID on a dedicated connection, and
+// elsewhere, where a single process owns the database, a lock within the process.
+func LockSnapshot(db *gorm.DB, id uuid.UUID) (func(), error) {
+	if db.Dialector.Name() != database.DriverPostgres {
+		return snapshotLocks.lock(id), nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	ctx := db.Statement.Context
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := snapshotLockKey(id)
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return func() {
+		// The lock outlives a cancelled request, so it is released without the request's context. Closing the
+		// connection after a failed unlock discards the session, and the lock with it.
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}, nil
+}
+
+// snapshotLockKey folds a tenant ID into the key of its advisory lock
+func snapshotLockKey(id uuid.UUID) int64 {
+	return int64(binary.BigEndian.Uint64(id[:8]) ^ binary.BigEndian.Uint64(id[8:]))
+}
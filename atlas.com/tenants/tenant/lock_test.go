@@ -0,0 +1,45 @@
+package tenant
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestKeyedLock(t *testing.T) {
+	k := newKeyedLock()
+	id := uuid.New()
+	unlock := k.lock(id)
+
+	other := make(chan struct{})
+	go func() {
+		k.lock(uuid.New())()
+		close(other)
+	}()
+	select {
+	case <-other:
+	case <-time.After(time.Second):
+		t.Fatal("locking another tenant waited for the held lock")
+	}
+
+	same := make(chan struct{})
+	go func() {
+		k.lock(id)()
+		close(same)
+	}()
+	select {
+	case <-same:
+		t.Fatal("locking the same tenant did not wait for the held lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-same:
+	case <-time.After(time.Second):
+		t.Fatal("locking the same tenant did not resume after unlock")
+	}
+	if len(k.locks) != 0 {
+		t.Errorf("locks = %d, want the released locks removed", len(k.locks))
+	}
+}
@@ -0,0 +1,134 @@
+package tenant
+
+import (
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InMemoryRepository stores tenants in memory. It is safe for concurrent use, and is intended for tests.
+type InMemoryRepository struct {
+	mu       sync.RWMutex
+	entities map[uuid.UUID]Entity
+	deleted  map[uuid.UUID]time.Time
+}
+
+// NewInMemoryRepository creates an empty in-memory repository
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{entities: make(map[uuid.UUID]Entity), deleted: make(map[uuid.UUID]time.Time)}
+}
+
+// ByIdProvider returns a provider for a tenant by ID
+func (r *InMemoryRepository) ByIdProvider(id uuid.UUID) model.Provider[Entity] {
+	return func() (Entity, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		e, ok := r.entities[id]
+		if !ok {
+			return Entity{}, gorm.ErrRecordNotFound
+		}
+		return e, nil
+	}
+}
+
+// AllProvider returns a provider for all tenants, in the order they were created
+func (r *InMemoryRepository) AllProvider() model.Provider[[]Entity] {
+	return func() ([]Entity, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		results := make([]Entity, 0, len(r.entities))
+		for _, e := range r.entities {
+			results = append(results, e)
+		}
+		sort.SliceStable(results, func(i, j int) bool { return results[i].CreatedAt.Before(results[j].CreatedAt) })
+		return results, nil
+	}
+}
+
+// DeletedIdsProvider returns a provider for the IDs of tenants deleted since the given time
+func (r *InMemoryRepository) DeletedIdsProvider(since time.Time) model.Provider[[]uuid.UUID] {
+	return func() ([]uuid.UUID, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		results := make([]uuid.UUID, 0, len(r.deleted))
+		for id, deletedAt := range r.deleted {
+			if !deletedAt.Before(since) {
+				results = append(results, id)
+			}
+		}
+		return results, nil
+	}
+}
+
+// ChildIdsProvider returns a provider for the IDs of the tenants naming a tenant as their parent
+func (r *InMemoryRepository) ChildIdsProvider(id uuid.UUID) model.Provider[[]uuid.UUID] {
+	return func() ([]uuid.UUID, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		results := make([]uuid.UUID, 0)
+		for _, e := range r.entities {
+			if e.ParentID != nil && *e.ParentID == id {
+				results = append(results, e.ID)
+			}
+		}
+		return results, nil
+	}
+}
+
+// ParentIdProvider returns a provider for the parent of a tenant. uuid.Nil is provided when the tenant has no parent
+// or is unknown.
+func (r *InMemoryRepository) ParentIdProvider(id uuid.UUID) model.Provider[uuid.UUID] {
+	return func() (uuid.UUID, error) {
+		e, err := r.ByIdProvider(id)()
+		if err != nil || e.ParentID == nil {
+			return uuid.Nil, nil
+		}
+		return *e.ParentID, nil
+	}
+}
+
+// Create stores a new tenant
+func (r *InMemoryRepository) Create(e Entity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entities[e.ID]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	now := time.Now()
+	e.CreatedAt = now
+	e.UpdatedAt = now
+	r.entities[e.ID] = e
+	return nil
+}
+
+// Update stores the changes to an existing tenant
+func (r *InMemoryRepository) Update(e Entity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.entities[e.ID]; ok {
+		e.CreatedAt = existing.CreatedAt
+	}
+	e.UpdatedAt = time.Now()
+	r.entities[e.ID] = e
+	return nil
+}
+
+// Delete removes a tenant
+func (r *InMemoryRepository) Delete(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entities[id]; !ok {
+		return ErrNotFound.Wrap(gorm.ErrRecordNotFound)
+	}
+	delete(r.entities, id)
+	r.deleted[id] = time.Now()
+	return nil
+}
+
+// LockSnapshot locks the snapshot of a tenant within the process
+func (r *InMemoryRepository) LockSnapshot(id uuid.UUID) (func(), error) {
+	return snapshotLocks.lock(id), nil
+}
@@ -0,0 +1,124 @@
+package tenant
+
+import (
+	"atlas-tenants/configuration"
+	"atlas-tenants/metrics"
+	"context"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	EnvMetricsRefreshInterval     = "TENANT_METRICS_REFRESH_INTERVAL"
+	DefaultMetricsRefreshInterval = time.Minute
+)
+
+var tenantsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(metrics.Namespace, "", "tenants"),
+	"Number of tenants.",
+	nil, nil)
+
+var resourcesDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(metrics.Namespace, "configuration", "resources"),
+	"Number of configuration resources a tenant resolves, by tenant and resource name.",
+	[]string{"tenant_id", "resource"}, nil)
+
+// MetricsRefreshInterval returns how often the tenant gauges are read from the database, configured by
+// TENANT_METRICS_REFRESH_INTERVAL
+func MetricsRefreshInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(EnvMetricsRefreshInterval)); err == nil && d > 0 {
+		return d
+	}
+	return DefaultMetricsRefreshInterval
+}
+
+// Collector reports the number of tenants, and the routes and vessels each tenant resolves. The counts are read from
+// the database in the background on every refresh interval, so a scrape never queries the database.
+type Collector struct {
+	l  logrus.FieldLogger
+	db *gorm.DB
+
+	mu        sync.RWMutex
+	refreshed bool
+	tenants   int
+	resources map[uuid.UUID]map[string]int
+}
+
+// NewCollector creates a collector reading from the database, which refreshes its counts every interval until the
+// context ends
+func NewCollector(l logrus.FieldLogger, ctx context.Context, wg *sync.WaitGroup, db *gorm.DB, interval time.Duration) *Collector {
+	c := &Collector{l: l, db: db}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			c.refresh(ctx, interval)
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return c
+}
+
+// refresh reads the counts from the database, taking at most the timeout. The previous counts are kept when they cannot
+// be read.
+func (c *Collector) refresh(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ts, err := NewProcessor(c.l, ctx, c.db).GetAll()
+	if err != nil {
+		c.l.WithError(err).Warn("Unable to count tenants for metrics.")
+		return
+	}
+	cp := configuration.NewProcessor(c.l, ctx, c.db)
+	resources := make(map[uuid.UUID]map[string]int, len(ts))
+	for _, t := range ts {
+		rs, err := emptyIfNotFound(cp.AllRoutesProvider(t.Id()))()
+		if err != nil {
+			c.l.WithError(err).Warnf("Unable to count [routes] of tenant [%s] for metrics.", t.Id())
+			return
+		}
+		vs, err := emptyIfNotFound(cp.AllVesselsProvider(t.Id()))()
+		if err != nil {
+			c.l.WithError(err).Warnf("Unable to count [vessels] of tenant [%s] for metrics.", t.Id())
+			return
+		}
+		resources[t.Id()] = map[string]int{"routes": len(rs), "vessels": len(vs)}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshed = true
+	c.tenants = len(ts)
+	c.resources = resources
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tenantsDesc
+	ch <- resourcesDesc
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.refreshed {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(tenantsDesc, prometheus.GaugeValue, float64(c.tenants))
+	for id, counts := range c.resources {
+		for resource, n := range counts {
+			ch <- prometheus.MustNewConstMetric(resourcesDesc, prometheus.GaugeValue, float64(n), id.String(), resource)
+		}
+	}
+}
@@ -12,6 +12,7 @@ type Model struct {
 	region       string
 	majorVersion uint16
 	minorVersion uint16
+	parentId     uuid.UUID
 }
 
 // Id returns the tenant ID
@@ -39,6 +40,16 @@ func (m Model) MinorVersion() uint16 {
 	return m.minorVersion
 }
 
+// ParentId returns the ID of the tenant this tenant inherits configuration from
+func (m Model) ParentId() uuid.UUID {
+	return m.parentId
+}
+
+// HasParent returns true if the tenant inherits configuration from a parent tenant
+func (m Model) HasParent() bool {
+	return m.parentId != uuid.Nil
+}
+
 // String returns a string representation of the tenant
 func (m Model) String() string {
 	return fmt.Sprintf("Id [%s] Name [%s] Region [%s] Version [%d.%d]", m.Id().String(), m.Name(), m.Region(), m.MajorVersion(), m.MinorVersion())
@@ -51,6 +62,7 @@ type Builder struct {
 	region       string
 	majorVersion uint16
 	minorVersion uint16
+	parentId     uuid.UUID
 }
 
 // NewBuilder creates a new Builder
@@ -61,6 +73,7 @@ func NewBuilder() *Builder {
 		region:       "",
 		majorVersion: 0,
 		minorVersion: 0,
+		parentId:     uuid.Nil,
 	}
 }
 
@@ -94,6 +107,12 @@ func (b *Builder) SetMinorVersion(minorVersion uint16) *Builder {
 	return b
 }
 
+// SetParentId sets the ID of the tenant this tenant inherits configuration from
+func (b *Builder) SetParentId(parentId uuid.UUID) *Builder {
+	b.parentId = parentId
+	return b
+}
+
 // Build creates a new Model
 func (b *Builder) Build() Model {
 	return Model{
@@ -102,16 +121,44 @@ func (b *Builder) Build() Model {
 		region:       b.region,
 		majorVersion: b.majorVersion,
 		minorVersion: b.minorVersion,
+		parentId:     b.parentId,
 	}
 }
 
 // Make converts an Entity to a Model
 func Make(e Entity) (Model, error) {
+	parentId := uuid.Nil
+	if e.ParentID != nil {
+		parentId = *e.ParentID
+	}
 	return NewBuilder().
 		SetId(e.ID).
 		SetName(e.Name).
 		SetRegion(e.Region).
 		SetMajorVersion(e.MajorVersion).
 		SetMinorVersion(e.MinorVersion).
+		SetParentId(parentId).
 		Build(), nil
 }
+
+// Reconciliation reports a republication of every tenant to the snapshot topic
+type Reconciliation struct {
+	id         uuid.UUID
+	published  int
+	tombstones int
+}
+
+// Id returns the ID of the reconciliation
+func (r Reconciliation) Id() uuid.UUID {
+	return r.id
+}
+
+// Published returns the number of tenant snapshots published
+func (r Reconciliation) Published() int {
+	return r.published
+}
+
+// Tombstones returns the number of tombstones published for recently deleted tenants
+func (r Reconciliation) Tombstones() int {
+	return r.tombstones
+}
@@ -1,6 +1,8 @@
 package tenant
 
 import (
+	"atlas-tenants/auth"
+	"atlas-tenants/domain"
 	"atlas-tenants/kafka/message"
 	"atlas-tenants/kafka/producer"
 	"context"
@@ -9,21 +11,37 @@ import (
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a tenant does not exist
+	ErrNotFound = domain.NotFound("TENANT_NOT_FOUND", "tenant not found")
+	// ErrParentNotFound is returned when a tenant references a parent tenant that does not exist
+	ErrParentNotFound = domain.Validation("PARENT_NOT_FOUND", "parent tenant not found").WithPointer("/data/attributes/parentId")
+	// ErrParentCycle is returned when a parent assignment would make a tenant its own ancestor
+	ErrParentCycle = domain.Validation("PARENT_CYCLE", "parent tenant would create a cycle").WithPointer("/data/attributes/parentId")
+	// ErrHasChildren is returned when deleting a tenant which other tenants inherit configuration from
+	ErrHasChildren = domain.Conflict("TENANT_HAS_CHILDREN", "tenant is the parent of other tenants")
+	// ErrParentForbidden is returned when the principal is not permitted to act on the parent tenant it names
+	ErrParentForbidden = auth.ErrTenantForbidden.WithPointer("/data/attributes/parentId")
+	// ErrInvalidParentId is returned when a parent tenant ID is not a valid UUID
+	ErrInvalidParentId = domain.Validation("INVALID_PARENT_ID", "parent tenant ID is not a valid UUID").WithPointer("/data/attributes/parentId")
 )
 
 // Processor defines the interface for tenant operations
 type Processor interface {
 	// Create creates a new tenant
-	Create(mb *message.Buffer) func(name string, region string, majorVersion uint16, minorVersion uint16) (Model, error)
+	Create(mb *message.Buffer) func(name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error)
 
 	// CreateAndEmit creates a new tenant and emits a Kafka message
-	CreateAndEmit(name string, region string, majorVersion uint16, minorVersion uint16) (Model, error)
+	CreateAndEmit(name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error)
 
 	// Update updates an existing tenant
-	Update(mb *message.Buffer) func(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16) (Model, error)
+	Update(mb *message.Buffer) func(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error)
 
 	// UpdateAndEmit updates an existing tenant and emits a Kafka message
-	UpdateAndEmit(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16) (Model, error)
+	UpdateAndEmit(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error)
 
 	// Delete deletes a tenant
 	Delete(mb *message.Buffer) func(id uuid.UUID) error
@@ -31,6 +49,14 @@ type Processor interface {
 	// DeleteAndEmit deletes a tenant and emits a Kafka message
 	DeleteAndEmit(id uuid.UUID) error
 
+	// ReconcileSnapshot republishes the current state of a tenant to the snapshot topic, or a tombstone when it is
+	// deleted. It returns true when a snapshot was published.
+	ReconcileSnapshot(mb *message.Buffer) func(id uuid.UUID) (bool, error)
+
+	// ReconcileSnapshotsAndEmit republishes every tenant, and a tombstone for every recently deleted tenant, to the
+	// snapshot topic
+	ReconcileSnapshotsAndEmit() (Reconciliation, error)
+
 	// GetById gets a tenant by ID
 	GetById(id uuid.UUID) (Model, error)
 
@@ -48,52 +74,66 @@ type Processor interface {
 type ProcessorImpl struct {
 	l   logrus.FieldLogger
 	ctx context.Context
-	db  *gorm.DB
+	r   Repository
 	p   producer.Provider
 }
 
-// NewProcessor creates a new processor
+// NewProcessor creates a new processor backed by the database. Database access is bound to ctx, so it is cancelled
+// with the request. Events are produced after the database commit, so producing them is not.
 func NewProcessor(l logrus.FieldLogger, ctx context.Context, db *gorm.DB) Processor {
+	return NewRepositoryProcessor(l, ctx, NewGormRepository(db.WithContext(ctx)))
+}
+
+// NewRepositoryProcessor creates a new processor storing tenants in the given repository
+func NewRepositoryProcessor(l logrus.FieldLogger, ctx context.Context, r Repository) Processor {
 	return &ProcessorImpl{
 		l:   l,
 		ctx: ctx,
-		db:  db,
-		p:   producer.ProviderImpl(l)(ctx),
+		r:   r,
+		p:   producer.ProviderImpl(l)(context.WithoutCancel(ctx)),
 	}
 }
 
 // Create creates a new tenant
-func (p *ProcessorImpl) Create(mb *message.Buffer) func(name string, region string, majorVersion uint16, minorVersion uint16) (Model, error) {
-	return func(name string, region string, majorVersion uint16, minorVersion uint16) (Model, error) {
+func (p *ProcessorImpl) Create(mb *message.Buffer) func(name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error) {
+	return func(name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error) {
 		m := NewBuilder().
 			SetName(name).
 			SetRegion(region).
 			SetMajorVersion(majorVersion).
 			SetMinorVersion(minorVersion).
+			SetParentId(parentId).
 			Build()
 
+		err := p.authorizeParent(uuid.Nil, m.ParentId())
+		if err != nil {
+			return Model{}, err
+		}
+		err = p.validateParent(m.Id(), m.ParentId())
+		if err != nil {
+			return Model{}, err
+		}
+
 		e := Entity{
 			ID:           m.Id(),
 			Name:         m.Name(),
 			Region:       m.Region(),
 			MajorVersion: m.MajorVersion(),
 			MinorVersion: m.MinorVersion(),
+			ParentID:     parentIdReference(m.ParentId()),
 		}
 
-		err := CreateTenant(p.db, e)
+		err = p.r.Create(e)
 		if err != nil {
 			return Model{}, err
 		}
 
 		// CreateRoute and add the Kafka message to the buffer
-		err = mb.Put(EventTopicTenantStatus, CreateStatusEventProvider(
-			m.Id(),
-			EventTypeCreated,
-			m.Name(),
-			m.Region(),
-			m.MajorVersion(),
-			m.MinorVersion(),
-		))
+		err = mb.Put(EventTopicTenantStatus, CreateStatusEventProvider(p.ctx, EventTypeCreated, m))
+		if err != nil {
+			return Model{}, err
+		}
+		err = mb.Put(EventTopicTenantSnapshot, SnapshotProvider(m))
 		if err != nil {
 			return Model{}, err
 		}
@@ -110,24 +150,34 @@ func (p *ProcessorImpl) Create(mb *message.Buffer) func(name string, region stri
 }
 
 // CreateAndEmit creates a new tenant and emits a Kafka message
-func (p *ProcessorImpl) CreateAndEmit(name string, region string, majorVersion uint16, minorVersion uint16) (Model, error) {
+func (p *ProcessorImpl) CreateAndEmit(name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error) {
 	return message.EmitWithResult[Model, string](p.p)(func(mb *message.Buffer) func(string) (Model, error) {
 		return func(name string) (Model, error) {
-			return p.Create(mb)(name, region, majorVersion, minorVersion)
+			return p.Create(mb)(name, region, majorVersion, minorVersion, parentId)
 		}
 	})(name)
 }
 
 // Update updates an existing tenant
-func (p *ProcessorImpl) Update(mb *message.Buffer) func(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16) (Model, error) {
-	return func(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16) (Model, error) {
+func (p *ProcessorImpl) Update(mb *message.Buffer) func(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error) {
+	return func(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error) {
 		// First get the tenant to ensure it exists
-		provider := GetByIdProvider(id)(p.db)
-		e, err := provider()
+		e, err := p.entityByIdProvider(id)()
+		if err != nil {
+			return Model{}, err
+		}
+
+		before, err := Make(e)
+		if err != nil {
+			return Model{}, err
+		}
+
+		err = p.authorizeParent(before.ParentId(), parentId)
+		if err != nil {
+			return Model{}, err
+		}
+		err = p.validateParent(id, parentId)
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return Model{}, errors.New("tenant not found")
-			}
 			return Model{}, err
 		}
 
@@ -135,26 +185,30 @@ func (p *ProcessorImpl) Update(mb *message.Buffer) func(id uuid.UUID, name strin
 		e.Region = region
 		e.MajorVersion = majorVersion
 		e.MinorVersion = minorVersion
+		e.ParentID = parentIdReference(parentId)
 
-		err = UpdateTenant(p.db, e)
+		m, err := Make(e)
 		if err != nil {
 			return Model{}, err
 		}
 
-		m, err := Make(e)
+		// An update which changes nothing is neither stored nor emitted
+		changed := ChangedFields(before, m)
+		if len(changed) == 0 {
+			p.l.WithField("tenantId", m.Id().String()).Debug("Tenant update changed nothing.")
+			return m, nil
+		}
+
+		err = p.r.Update(e)
 		if err != nil {
 			return Model{}, err
 		}
 
-		// CreateRoute and add the Kafka message to the buffer
-		err = mb.Put(EventTopicTenantStatus, CreateStatusEventProvider(
-			m.Id(),
-			EventTypeUpdated,
-			m.Name(),
-			m.Region(),
-			m.MajorVersion(),
-			m.MinorVersion(),
-		))
+		err = mb.Put(EventTopicTenantStatus, UpdatedStatusEventProvider(p.ctx, before, m))
+		if err != nil {
+			return Model{}, err
+		}
+		err = mb.Put(EventTopicTenantSnapshot, SnapshotProvider(m))
 		if err != nil {
 			return Model{}, err
 		}
@@ -164,6 +218,7 @@ func (p *ProcessorImpl) Update(mb *message.Buffer) func(id uuid.UUID, name strin
 			"event":    EventTypeUpdated,
 			"name":     m.Name(),
 			"region":   m.Region(),
+			"changed":  changed,
 		}).Info("Tenant updated")
 
 		return m, nil
@@ -171,10 +226,15 @@ func (p *ProcessorImpl) Update(mb *message.Buffer) func(id uuid.UUID, name strin
 }
 
 // UpdateAndEmit updates an existing tenant and emits a Kafka message
-func (p *ProcessorImpl) UpdateAndEmit(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16) (Model, error) {
+func (p *ProcessorImpl) UpdateAndEmit(id uuid.UUID, name string, region string, majorVersion uint16, minorVersion uint16, parentId uuid.UUID) (Model, error) {
+	unlock, err := p.r.LockSnapshot(id)
+	if err != nil {
+		return Model{}, err
+	}
+	defer unlock()
 	return message.EmitWithResult[Model, uuid.UUID](p.p)(func(mb *message.Buffer) func(uuid.UUID) (Model, error) {
 		return func(id uuid.UUID) (Model, error) {
-			return p.Update(mb)(id, name, region, majorVersion, minorVersion)
+			return p.Update(mb)(id, name, region, majorVersion, minorVersion, parentId)
 		}
 	})(id)
 }
@@ -183,12 +243,8 @@ func (p *ProcessorImpl) UpdateAndEmit(id uuid.UUID, name string, region string,
 func (p *ProcessorImpl) Delete(mb *message.Buffer) func(id uuid.UUID) error {
 	return func(id uuid.UUID) error {
 		// First get the tenant to ensure it exists and to log its details
-		provider := GetByIdProvider(id)(p.db)
-		e, err := provider()
+		e, err := p.entityByIdProvider(id)()
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("tenant not found")
-			}
 			return err
 		}
 
@@ -197,20 +253,26 @@ func (p *ProcessorImpl) Delete(mb *message.Buffer) func(id uuid.UUID) error {
 			return err
 		}
 
-		err = DeleteTenant(p.db, id)
+		// Deleting a parent would silently remove the configuration its children inherit
+		children, err := p.r.ChildIdsProvider(id)()
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			return ErrHasChildren
+		}
+
+		err = p.r.Delete(id)
 		if err != nil {
 			return err
 		}
 
 		// CreateRoute and add the Kafka message to the buffer
-		err = mb.Put(EventTopicTenantStatus, CreateStatusEventProvider(
-			m.Id(),
-			EventTypeDeleted,
-			m.Name(),
-			m.Region(),
-			m.MajorVersion(),
-			m.MinorVersion(),
-		))
+		err = mb.Put(EventTopicTenantStatus, CreateStatusEventProvider(p.ctx, EventTypeDeleted, m))
+		if err != nil {
+			return err
+		}
+		err = mb.Put(EventTopicTenantSnapshot, TombstoneProvider(id))
 		if err != nil {
 			return err
 		}
@@ -228,27 +290,159 @@ func (p *ProcessorImpl) Delete(mb *message.Buffer) func(id uuid.UUID) error {
 
 // DeleteAndEmit deletes a tenant and emits a Kafka message
 func (p *ProcessorImpl) DeleteAndEmit(id uuid.UUID) error {
+	unlock, err := p.r.LockSnapshot(id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	return message.Emit(p.p)(func(mb *message.Buffer) error {
 		return p.Delete(mb)(id)
 	})
 }
 
+// ReconcileSnapshot republishes the current state of a tenant to the snapshot topic, or a tombstone when it is deleted,
+// so the topic holds the state of the database even if earlier messages were lost. It returns true when a snapshot was
+// published.
+func (p *ProcessorImpl) ReconcileSnapshot(mb *message.Buffer) func(id uuid.UUID) (bool, error) {
+	return func(id uuid.UUID) (bool, error) {
+		m, err := p.GetById(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, mb.Put(EventTopicTenantSnapshot, TombstoneProvider(id))
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, mb.Put(EventTopicTenantSnapshot, SnapshotProvider(m))
+	}
+}
+
+// ReconcileSnapshotsAndEmit republishes every tenant, and a tombstone for every tenant deleted within the tombstone
+// window, to the snapshot topic. Each tenant is read and emitted while holding its snapshot lock, so a concurrent
+// change of the tenant is published either before or after its reconciled state, never replaced by it.
+func (p *ProcessorImpl) ReconcileSnapshotsAndEmit() (Reconciliation, error) {
+	ms, err := p.GetAll()
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	deleted, err := p.r.DeletedIdsProvider(time.Now().Add(-TombstoneWindow()))()
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	ids := make([]uuid.UUID, 0, len(ms)+len(deleted))
+	for _, m := range ms {
+		ids = append(ids, m.Id())
+	}
+	ids = append(ids, deleted...)
+
+	r := Reconciliation{id: uuid.New()}
+	for _, id := range ids {
+		published, err := p.reconcileSnapshotAndEmit(id)
+		if err != nil {
+			return Reconciliation{}, err
+		}
+		if published {
+			r.published++
+		} else {
+			r.tombstones++
+		}
+	}
+
+	p.l.WithFields(logrus.Fields{
+		"published":  r.published,
+		"tombstones": r.tombstones,
+	}).Info("Tenant snapshots reconciled")
+	return r, nil
+}
+
+// reconcileSnapshotAndEmit republishes the current state of a tenant to the snapshot topic while holding its snapshot
+// lock
+func (p *ProcessorImpl) reconcileSnapshotAndEmit(id uuid.UUID) (bool, error) {
+	unlock, err := p.r.LockSnapshot(id)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	return message.EmitWithResult[bool, uuid.UUID](p.p)(p.ReconcileSnapshot)(id)
+}
+
+// validateParent ensures the parent tenant and each of its ancestors exist, and that walking its ancestry never leads
+// back to the tenant
+// authorizeParent verifies the principal acting in the processor's context may access the parent a tenant is given, so a
+// principal limited to some tenants cannot inherit the configuration of others. A parent kept by an update was
+// authorized when it was assigned.
+func (p *ProcessorImpl) authorizeParent(current uuid.UUID, parentId uuid.UUID) error {
+	if parentId == uuid.Nil || parentId == current {
+		return nil
+	}
+	if pr, ok := auth.FromContext(p.ctx); ok && !pr.CanAccessTenant(parentId) {
+		return ErrParentForbidden
+	}
+	return nil
+}
+
+func (p *ProcessorImpl) validateParent(id uuid.UUID, parentId uuid.UUID) error {
+	if parentId == uuid.Nil {
+		return nil
+	}
+
+	visited := map[uuid.UUID]bool{id: true}
+	current := parentId
+	for current != uuid.Nil {
+		if visited[current] {
+			return ErrParentCycle
+		}
+		visited[current] = true
+
+		e, err := p.r.ByIdProvider(current)()
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrParentNotFound
+			}
+			return err
+		}
+		if e.ParentID == nil {
+			return nil
+		}
+		current = *e.ParentID
+	}
+	return nil
+}
+
+// parentIdReference converts a parent ID to its nullable column representation
+func parentIdReference(parentId uuid.UUID) *uuid.UUID {
+	if parentId == uuid.Nil {
+		return nil
+	}
+	return &parentId
+}
+
+// entityByIdProvider returns a provider for a tenant entity, reporting a missing tenant as ErrNotFound
+func (p *ProcessorImpl) entityByIdProvider(id uuid.UUID) model.Provider[Entity] {
+	return func() (Entity, error) {
+		e, err := p.r.ByIdProvider(id)()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Entity{}, ErrNotFound.Wrap(err)
+		}
+		return e, err
+	}
+}
+
 // GetById gets a tenant by ID
 func (p *ProcessorImpl) GetById(id uuid.UUID) (Model, error) {
-	return model.Map(Make)(GetByIdProvider(id)(p.db))()
+	return p.ByIdProvider(id)()
 }
 
 // GetAll gets all tenants
 func (p *ProcessorImpl) GetAll() ([]Model, error) {
-	return model.SliceMap(Make)(GetAllProvider()(p.db))(model.ParallelMap())()
+	return model.SliceMap(Make)(p.r.AllProvider())(model.ParallelMap())()
 }
 
 // ByIdProvider returns a provider for a tenant by ID
 func (p *ProcessorImpl) ByIdProvider(id uuid.UUID) model.Provider[Model] {
-	return model.Map(Make)(GetByIdProvider(id)(p.db))
+	return model.Map(Make)(p.entityByIdProvider(id))
 }
 
 // AllProvider returns a provider for all tenants
 func (p *ProcessorImpl) AllProvider() model.Provider[[]Model] {
-	return model.SliceMap(Make)(GetAllProvider()(p.db))(model.ParallelMap())
+	return model.SliceMap(Make)(p.r.AllProvider())(model.ParallelMap())
 }
@@ -0,0 +1,473 @@
+package tenant
+
+import (
+	"atlas-tenants/auth"
+	"atlas-tenants/kafka/kafkatest"
+	"atlas-tenants/kafka/message"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestProcessor(t *testing.T) (Processor, *InMemoryRepository) {
+	t.Helper()
+	l, _ := test.NewNullLogger()
+	r := NewInMemoryRepository()
+	return NewRepositoryProcessor(l, context.Background(), r), r
+}
+
+func mustCreate(t *testing.T, p Processor, name string, parentId uuid.UUID) Model {
+	t.Helper()
+	m, err := p.Create(message.NewBuffer())(name, "GMS", 83, 1, parentId)
+	if err != nil {
+		t.Fatalf("unable to create tenant [%s]: %v", name, err)
+	}
+	return m
+}
+
+// eventTypes returns the types of the tenant status events in the buffer
+func eventTypes(t *testing.T, mb *message.Buffer) []string {
+	t.Helper()
+	var results []string
+	for _, e := range kafkatest.Decode[Event[json.RawMessage]](t, mb.GetAll()[EventTopicTenantStatus]) {
+		results = append(results, e.Type)
+	}
+	return results
+}
+
+// ofType matches tenant status events of the event type
+func ofType[B any](eventType string) func(Event[B]) bool {
+	return func(e Event[B]) bool {
+		return e.Type == eventType
+	}
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		parentId  func(p Processor) uuid.UUID
+		wantErr   error
+		wantEvent bool
+	}{
+		{name: "without parent", parentId: func(Processor) uuid.UUID { return uuid.Nil }, wantEvent: true},
+		{name: "with parent", parentId: func(p Processor) uuid.UUID { return mustCreate(t, p, "parent", uuid.Nil).Id() }, wantEvent: true},
+		{name: "unknown parent", parentId: func(Processor) uuid.UUID { return uuid.New() }, wantErr: ErrParentNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, r := newTestProcessor(t)
+			parentId := tt.parentId(p)
+			mb := message.NewBuffer()
+
+			m, err := p.Create(mb)("tenant", "GMS", 83, 1, parentId)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(eventTypes(t, mb)) != 0 {
+					t.Errorf("Create() buffered events for a failed create")
+				}
+				return
+			}
+			e, err := r.ByIdProvider(m.Id())()
+			if err != nil {
+				t.Fatalf("tenant was not stored: %v", err)
+			}
+			if e.Name != "tenant" || m.ParentId() != parentId {
+				t.Errorf("stored tenant = %+v, parent %s, want name tenant, parent %s", e, m.ParentId(), parentId)
+			}
+			if got := eventTypes(t, mb); len(got) != 1 || got[0] != EventTypeCreated {
+				t.Errorf("Create() events = %v, want [%s]", got, EventTypeCreated)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  func(p Processor) uuid.UUID
+		parent  func(p Processor, r Repository, target uuid.UUID) uuid.UUID
+		wantErr error
+	}{
+		{
+			name:   "existing tenant",
+			target: func(p Processor) uuid.UUID { return mustCreate(t, p, "tenant", uuid.Nil).Id() },
+			parent: func(Processor, Repository, uuid.UUID) uuid.UUID { return uuid.Nil },
+		},
+		{
+			name:    "unknown tenant",
+			target:  func(Processor) uuid.UUID { return uuid.New() },
+			parent:  func(Processor, Repository, uuid.UUID) uuid.UUID { return uuid.Nil },
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "own parent",
+			target:  func(p Processor) uuid.UUID { return mustCreate(t, p, "tenant", uuid.Nil).Id() },
+			parent:  func(_ Processor, _ Repository, target uuid.UUID) uuid.UUID { return target },
+			wantErr: ErrParentCycle,
+		},
+		{
+			name:   "descendant as parent",
+			target: func(p Processor) uuid.UUID { return mustCreate(t, p, "tenant", uuid.Nil).Id() },
+			parent: func(p Processor, _ Repository, target uuid.UUID) uuid.UUID {
+				child := mustCreate(t, p, "child", target)
+				return mustCreate(t, p, "grandchild", child.Id()).Id()
+			},
+			wantErr: ErrParentCycle,
+		},
+		{
+			name:    "unknown parent",
+			target:  func(p Processor) uuid.UUID { return mustCreate(t, p, "tenant", uuid.Nil).Id() },
+			parent:  func(Processor, Repository, uuid.UUID) uuid.UUID { return uuid.New() },
+			wantErr: ErrParentNotFound,
+		},
+		{
+			name:   "parent with unknown ancestor",
+			target: func(p Processor) uuid.UUID { return mustCreate(t, p, "tenant", uuid.Nil).Id() },
+			parent: func(p Processor, r Repository, _ uuid.UUID) uuid.UUID {
+				grandparent := mustCreate(t, p, "grandparent", uuid.Nil)
+				parent := mustCreate(t, p, "parent", grandparent.Id())
+				// The processor refuses to delete a parent, so the grandparent is removed from the repository
+				if err := r.Delete(grandparent.Id()); err != nil {
+					t.Fatalf("unable to delete grandparent: %v", err)
+				}
+				return parent.Id()
+			},
+			wantErr: ErrParentNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, r := newTestProcessor(t)
+			id := tt.target(p)
+			parentId := tt.parent(p, r, id)
+			mb := message.NewBuffer()
+
+			m, err := p.Update(mb)(id, "renamed", "KMS", 84, 2, parentId)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			got, err := p.GetById(id)
+			if err != nil {
+				t.Fatalf("GetById() error = %v", err)
+			}
+			if got.Name() != "renamed" || got.Region() != "KMS" || got.MajorVersion() != 84 || got.MinorVersion() != 2 {
+				t.Errorf("GetById() = %+v, want the updated tenant", got)
+			}
+			if m.Name() != got.Name() {
+				t.Errorf("Update() = %+v, want %+v", m, got)
+			}
+			if types := eventTypes(t, mb); len(types) != 1 || types[0] != EventTypeUpdated {
+				t.Errorf("Update() events = %v, want [%s]", types, EventTypeUpdated)
+			}
+		})
+	}
+}
+
+func TestUpdateEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		region      string
+		major       uint16
+		parent      bool
+		wantChanged []string
+	}{
+		{name: "no-op", region: "GMS", major: 83},
+		{name: "version bump", region: "GMS", major: 84, wantChanged: []string{"majorVersion"}},
+		{name: "region and version", region: "KMS", major: 84, wantChanged: []string{"region", "majorVersion"}},
+		{name: "parent only", region: "GMS", major: 83, parent: true, wantChanged: []string{"parentId"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, r := newTestProcessor(t)
+			before := mustCreate(t, p, "tenant", uuid.Nil)
+			parentId := uuid.Nil
+			if tt.parent {
+				parentId = mustCreate(t, p, "parent", uuid.Nil).Id()
+			}
+			stored, _ := r.ByIdProvider(before.Id())()
+			mb := message.NewBuffer()
+
+			if _, err := p.Update(mb)(before.Id(), "tenant", tt.region, tt.major, 1, parentId); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			es := kafkatest.Decode[Event[StatusEventUpdatedBody]](t, mb.GetAll()[EventTopicTenantStatus])
+			if tt.wantChanged == nil {
+				if len(es) != 0 {
+					t.Errorf("Update() emitted %d events for a no-op, want none", len(es))
+				}
+				if after, _ := r.ByIdProvider(before.Id())(); !after.UpdatedAt.Equal(stored.UpdatedAt) {
+					t.Errorf("Update() stored a no-op")
+				}
+				return
+			}
+			e := kafkatest.Single(t, es, ofType[StatusEventUpdatedBody](EventTypeUpdated))
+			if strings.Join(e.Body.ChangedFields, ",") != strings.Join(tt.wantChanged, ",") {
+				t.Errorf("changed fields = %v, want %v", e.Body.ChangedFields, tt.wantChanged)
+			}
+			if want := statusEventBody(before); !reflect.DeepEqual(e.Body.Previous, want) {
+				t.Errorf("previous = %+v, want %+v", e.Body.Previous, want)
+			}
+			if e.Body.Region != tt.region || e.Body.MajorVersion != tt.major {
+				t.Errorf("body = %+v, want region %s and major version %d", e.Body.StatusEventBody, tt.region, tt.major)
+			}
+			if tt.parent && (e.Body.ParentId == nil || *e.Body.ParentId != parentId || e.Body.Previous.ParentId != nil) {
+				t.Errorf("body parent = %v, previous parent = %v, want %s and none", e.Body.ParentId, e.Body.Previous.ParentId, parentId)
+			}
+		})
+	}
+}
+
+func TestParentOutsidePrincipalScope(t *testing.T) {
+	p, r := newTestProcessor(t)
+	l, _ := test.NewNullLogger()
+	other := mustCreate(t, p, "other", uuid.Nil)
+	scoped := mustCreate(t, p, "scoped", other.Id())
+	own := mustCreate(t, p, "own", uuid.Nil)
+	principal := auth.NewBuilder("scoped").SetMethod(auth.MethodAPIKey).SetRole(auth.RoleAdmin).SetTenantIds([]uuid.UUID{scoped.Id(), own.Id()}).Build()
+	sp := NewRepositoryProcessor(l, auth.WithPrincipal(context.Background(), principal), r)
+
+	if _, err := sp.Create(message.NewBuffer())("tenant", "GMS", 83, 1, other.Id()); !errors.Is(err, ErrParentForbidden) {
+		t.Errorf("Create() with a parent outside the scope error = %v, want %v", err, ErrParentForbidden)
+	}
+	if _, err := sp.Update(message.NewBuffer())(own.Id(), "own", "GMS", 83, 1, other.Id()); !errors.Is(err, ErrParentForbidden) {
+		t.Errorf("Update() to a parent outside the scope error = %v, want %v", err, ErrParentForbidden)
+	}
+	// A parent assigned by an unlimited principal is kept by updates of the child
+	if _, err := sp.Update(message.NewBuffer())(scoped.Id(), "renamed", "GMS", 83, 1, other.Id()); err != nil {
+		t.Errorf("Update() keeping the parent error = %v", err)
+	}
+	if _, err := sp.Update(message.NewBuffer())(own.Id(), "own", "GMS", 83, 1, scoped.Id()); err != nil {
+		t.Errorf("Update() to a parent within the scope error = %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  func(p Processor) uuid.UUID
+		wantErr error
+	}{
+		{name: "existing tenant", target: func(p Processor) uuid.UUID { return mustCreate(t, p, "tenant", uuid.Nil).Id() }},
+		{name: "unknown tenant", target: func(Processor) uuid.UUID { return uuid.New() }, wantErr: ErrNotFound},
+		{
+			name: "parent",
+			target: func(p Processor) uuid.UUID {
+				parent := mustCreate(t, p, "parent", uuid.Nil)
+				mustCreate(t, p, "child", parent.Id())
+				return parent.Id()
+			},
+			wantErr: ErrHasChildren,
+		},
+		{
+			name: "parent of deleted child",
+			target: func(p Processor) uuid.UUID {
+				parent := mustCreate(t, p, "parent", uuid.Nil)
+				child := mustCreate(t, p, "child", parent.Id())
+				if err := p.Delete(message.NewBuffer())(child.Id()); err != nil {
+					t.Fatalf("unable to delete child: %v", err)
+				}
+				return parent.Id()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newTestProcessor(t)
+			id := tt.target(p)
+			mb := message.NewBuffer()
+
+			err := p.Delete(mb)(id)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if _, err = p.GetById(id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetById() after delete error = %v, want %v", err, ErrNotFound)
+			}
+			if types := eventTypes(t, mb); len(types) != 1 || types[0] != EventTypeDeleted {
+				t.Errorf("Delete() events = %v, want [%s]", types, EventTypeDeleted)
+			}
+		})
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	p, _ := newTestProcessor(t)
+	if ms, err := p.GetAll(); err != nil || len(ms) != 0 {
+		t.Fatalf("GetAll() = %v, %v, want no tenants", ms, err)
+	}
+
+	parent := mustCreate(t, p, "parent", uuid.Nil)
+	mustCreate(t, p, "child", parent.Id())
+
+	ms, err := p.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	names := make(map[string]uuid.UUID)
+	for _, m := range ms {
+		names[m.Name()] = m.ParentId()
+	}
+	if len(names) != 2 || names["parent"] != uuid.Nil || names["child"] != parent.Id() {
+		t.Errorf("GetAll() = %v, want parent and child", names)
+	}
+}
+
+func TestEmit(t *testing.T) {
+	rec := kafkatest.Record(t)
+	p, _ := newTestProcessor(t)
+
+	m, err := p.CreateAndEmit("tenant", "GMS", 83, 1, uuid.Nil)
+	if err != nil {
+		t.Fatalf("CreateAndEmit() error = %v", err)
+	}
+	if _, err = p.UpdateAndEmit(m.Id(), "renamed", "KMS", 84, 2, uuid.Nil); err != nil {
+		t.Fatalf("UpdateAndEmit() error = %v", err)
+	}
+	if err = p.DeleteAndEmit(m.Id()); err != nil {
+		t.Fatalf("DeleteAndEmit() error = %v", err)
+	}
+
+	ms := rec.Messages(EventTopicTenantStatus)
+	if len(ms) != 3 {
+		t.Fatalf("produced %d events, want 3", len(ms))
+	}
+	for _, msg := range ms {
+		if string(msg.Key) != m.Id().String() {
+			t.Errorf("event key = %s, want %s", msg.Key, m.Id())
+		}
+	}
+
+	es := kafkatest.Decode[Event[StatusEventBody]](t, ms)
+	created := kafkatest.Single(t, es, ofType[StatusEventBody](EventTypeCreated))
+	if want := (StatusEventBody{Name: "tenant", Region: "GMS", MajorVersion: 83, MinorVersion: 1}); created.TenantId != m.Id() || created.Body != want {
+		t.Errorf("CREATED event = %+v, want tenant %s with %+v", created, m.Id(), want)
+	}
+	deleted := kafkatest.Single(t, es, ofType[StatusEventBody](EventTypeDeleted))
+	if want := (StatusEventBody{Name: "renamed", Region: "KMS", MajorVersion: 84, MinorVersion: 2}); deleted.TenantId != m.Id() || deleted.Body != want {
+		t.Errorf("DELETED event = %+v, want tenant %s with %+v", deleted, m.Id(), want)
+	}
+}
+
+func TestEmitFailedDelete(t *testing.T) {
+	rec := kafkatest.Record(t)
+	p, _ := newTestProcessor(t)
+
+	if err := p.DeleteAndEmit(uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DeleteAndEmit() error = %v, want %v", err, ErrNotFound)
+	}
+	if ms := rec.Messages(EventTopicTenantStatus); len(ms) != 0 {
+		t.Errorf("produced %d events for a failed delete, want none", len(ms))
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	rec := kafkatest.Record(t)
+	p, _ := newTestProcessor(t)
+
+	parent, err := p.CreateAndEmit("parent", "GMS", 83, 1, uuid.Nil)
+	if err != nil {
+		t.Fatalf("CreateAndEmit() error = %v", err)
+	}
+	child, err := p.CreateAndEmit("child", "GMS", 83, 1, parent.Id())
+	if err != nil {
+		t.Fatalf("CreateAndEmit() error = %v", err)
+	}
+	// An update which changes nothing publishes no snapshot
+	for _, name := range []string{"child", "renamed"} {
+		if _, err = p.UpdateAndEmit(child.Id(), name, "GMS", 83, 1, parent.Id()); err != nil {
+			t.Fatalf("UpdateAndEmit() error = %v", err)
+		}
+	}
+	if err = p.DeleteAndEmit(child.Id()); err != nil {
+		t.Fatalf("DeleteAndEmit() error = %v", err)
+	}
+
+	ms := rec.Messages(EventTopicTenantSnapshot)
+	if len(ms) != 4 {
+		t.Fatalf("produced %d snapshot messages, want 4", len(ms))
+	}
+	wantKeys := []uuid.UUID{parent.Id(), child.Id(), child.Id(), child.Id()}
+	for i, msg := range ms {
+		if string(msg.Key) != wantKeys[i].String() {
+			t.Errorf("snapshot message %d key = %s, want %s", i, msg.Key, wantKeys[i])
+		}
+	}
+	if ms[3].Value != nil {
+		t.Errorf("delete published %s, want a tombstone", ms[3].Value)
+	}
+
+	ss := kafkatest.Decode[Snapshot](t, ms[:3])
+	parentId := parent.Id()
+	if want := (Snapshot{TenantId: child.Id(), Name: "renamed", Region: "GMS", MajorVersion: 83, MinorVersion: 1, ParentId: &parentId}); !reflect.DeepEqual(ss[2], want) {
+		t.Errorf("snapshot after update = %+v, want %+v", ss[2], want)
+	}
+	if ss[0].Name != "parent" || ss[0].ParentId != nil {
+		t.Errorf("snapshot of parent = %+v, want a tenant without parent", ss[0])
+	}
+}
+
+func TestReconcileSnapshots(t *testing.T) {
+	rec := kafkatest.Record(t)
+	p, _ := newTestProcessor(t)
+	kept := mustCreate(t, p, "kept", uuid.Nil)
+	deleted := mustCreate(t, p, "deleted", uuid.Nil)
+	if err := p.Delete(message.NewBuffer())(deleted.Id()); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	r, err := p.ReconcileSnapshotsAndEmit()
+
+	if err != nil {
+		t.Fatalf("ReconcileSnapshotsAndEmit() error = %v", err)
+	}
+	if r.Published() != 1 || r.Tombstones() != 1 {
+		t.Errorf("ReconcileSnapshotsAndEmit() = %d published, %d tombstones, want 1 and 1", r.Published(), r.Tombstones())
+	}
+	values := make(map[string][]byte)
+	for _, msg := range rec.Messages(EventTopicTenantSnapshot) {
+		values[string(msg.Key)] = msg.Value
+	}
+	if len(values) != 2 || values[kept.Id().String()] == nil {
+		t.Errorf("reconciled snapshot messages = %v, want a snapshot of %s", values, kept.Id())
+	}
+	if v, ok := values[deleted.Id().String()]; !ok || v != nil {
+		t.Errorf("reconciled snapshot messages = %v, want a tombstone for %s", values, deleted.Id())
+	}
+}
+
+func TestReconcileSnapshotsSkipsTombstonesOutsideWindow(t *testing.T) {
+	t.Setenv(EnvTombstoneWindow, "0s")
+	kafkatest.Record(t)
+	p, _ := newTestProcessor(t)
+	mustCreate(t, p, "kept", uuid.Nil)
+	deleted := mustCreate(t, p, "deleted", uuid.Nil)
+	if err := p.Delete(message.NewBuffer())(deleted.Id()); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	r, err := p.ReconcileSnapshotsAndEmit()
+
+	if err != nil {
+		t.Fatalf("ReconcileSnapshotsAndEmit() error = %v", err)
+	}
+	if r.Published() != 1 || r.Tombstones() != 0 {
+		t.Errorf("ReconcileSnapshotsAndEmit() = %d published, %d tombstones, want 1 and 0", r.Published(), r.Tombstones())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/Chronicle20/atlas-model/model"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"time"
 )
 
 // GetByIdProvider returns a provider for a tenant by ID
@@ -20,3 +21,25 @@ func GetAllProvider() database.EntityProvider[[]Entity] {
 		return database.SliceQuery[Entity](db, map[string]interface{}{})
 	}
 }
+
+// GetDeletedIdsProvider returns a provider for the IDs of tenants deleted since the given time
+func GetDeletedIdsProvider(since time.Time) database.EntityProvider[[]uuid.UUID] {
+	return func(db *gorm.DB) model.Provider[[]uuid.UUID] {
+		return func() ([]uuid.UUID, error) {
+			var results []uuid.UUID
+			err := db.Unscoped().Model(&Entity{}).Where("deleted_at >= ?", since).Pluck("id", &results).Error
+			return results, err
+		}
+	}
+}
+
+// GetChildIdsProvider returns a provider for the IDs of the tenants naming a tenant as their parent
+func GetChildIdsProvider(id uuid.UUID) database.EntityProvider[[]uuid.UUID] {
+	return func(db *gorm.DB) model.Provider[[]uuid.UUID] {
+		return func() ([]uuid.UUID, error) {
+			var results []uuid.UUID
+			err := db.Model(&Entity{}).Where("parent_id = ?", id).Pluck("id", &results).Error
+			return results, err
+		}
+	}
+}
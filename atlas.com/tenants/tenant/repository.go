@@ -0,0 +1,85 @@
+package tenant
+
+import (
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Repository stores tenants. Providers report a missing tenant as gorm.ErrRecordNotFound.
+type Repository interface {
+	// ByIdProvider returns a provider for a tenant by ID
+	ByIdProvider(id uuid.UUID) model.Provider[Entity]
+
+	// AllProvider returns a provider for all tenants
+	AllProvider() model.Provider[[]Entity]
+
+	// DeletedIdsProvider returns a provider for the IDs of tenants deleted since the given time
+	DeletedIdsProvider(since time.Time) model.Provider[[]uuid.UUID]
+
+	// ChildIdsProvider returns a provider for the IDs of the tenants naming a tenant as their parent
+	ChildIdsProvider(id uuid.UUID) model.Provider[[]uuid.UUID]
+
+	// Create stores a new tenant
+	Create(e Entity) error
+
+	// Update stores the changes to an existing tenant
+	Update(e Entity) error
+
+	// Delete removes a tenant, returning ErrNotFound when it does not exist
+	Delete(id uuid.UUID) error
+
+	// LockSnapshot locks the snapshot of a tenant, returning the function which unlocks it
+	LockSnapshot(id uuid.UUID) (func(), error)
+}
+
+// GormRepository stores tenants in the database
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository creates a repository backed by the database
+func NewGormRepository(db *gorm.DB) Repository {
+	return &GormRepository{db: db}
+}
+
+// ByIdProvider returns a provider for a tenant by ID
+func (r *GormRepository) ByIdProvider(id uuid.UUID) model.Provider[Entity] {
+	return GetByIdProvider(id)(r.db)
+}
+
+// AllProvider returns a provider for all tenants
+func (r *GormRepository) AllProvider() model.Provider[[]Entity] {
+	return GetAllProvider()(r.db)
+}
+
+// DeletedIdsProvider returns a provider for the IDs of tenants deleted since the given time
+func (r *GormRepository) DeletedIdsProvider(since time.Time) model.Provider[[]uuid.UUID] {
+	return GetDeletedIdsProvider(since)(r.db)
+}
+
+// ChildIdsProvider returns a provider for the IDs of the tenants naming a tenant as their parent
+func (r *GormRepository) ChildIdsProvider(id uuid.UUID) model.Provider[[]uuid.UUID] {
+	return GetChildIdsProvider(id)(r.db)
+}
+
+// Create stores a new tenant
+func (r *GormRepository) Create(e Entity) error {
+	return CreateTenant(r.db, e)
+}
+
+// Update stores the changes to an existing tenant
+func (r *GormRepository) Update(e Entity) error {
+	return UpdateTenant(r.db, e)
+}
+
+// Delete removes a tenant
+func (r *GormRepository) Delete(id uuid.UUID) error {
+	return DeleteTenant(r.db, id)
+}
+
+// LockSnapshot locks the snapshot of a tenant across the replicas sharing the database
+func (r *GormRepository) LockSnapshot(id uuid.UUID) (func(), error) {
+	return LockSnapshot(r.db, id)
+}
@@ -1,7 +1,15 @@
 package tenant
 
 import (
+	"atlas-tenants/auth"
+	"atlas-tenants/configuration"
+	"atlas-tenants/domain"
+	"atlas-tenants/idempotency"
+	"atlas-tenants/openapi"
 	"atlas-tenants/rest"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/Chronicle20/atlas-model/model"
 	"github.com/Chronicle20/atlas-rest/server"
 	"github.com/google/uuid"
@@ -12,16 +20,20 @@ import (
 	"net/http"
 )
 
+// ErrUnknownInclude is returned when a tenant read requests the inclusion of an unsupported resource type
+var ErrUnknownInclude = domain.Validation("UNKNOWN_INCLUDE", "unknown include").WithParameter("include")
+
 // GetAllTenantsHandler handles GET /tenants
 func GetAllTenantsHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
 	return func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			processor := NewProcessor(d.Logger(), d.Context(), db)
 
-			restModels, err := model.SliceMap(Transform)(processor.AllProvider())(model.ParallelMap())()
+			tenants := model.FilteredProvider(processor.AllProvider(), []model.Filter[Model]{accessibleBy(d.Principal())})
+			restModels, err := model.SliceMap(Transform)(tenants)(model.ParallelMap())()
 			if err != nil {
 				d.Logger().WithError(err).Error("Failed to transform tenant")
-				w.WriteHeader(http.StatusInternalServerError)
+				rest.WriteError(d.Logger())(w)(err)
 				return
 			}
 
@@ -32,6 +44,13 @@ func GetAllTenantsHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.H
 	}
 }
 
+// accessibleBy returns a filter which keeps the tenants the principal may access
+func accessibleBy(p auth.Principal) model.Filter[Model] {
+	return func(m Model) bool {
+		return p.CanAccessTenant(m.Id())
+	}
+}
+
 // GetTenantByIdHandler handles GET /tenants/{tenantId}
 func GetTenantByIdHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
 	return func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
@@ -42,7 +61,14 @@ func GetTenantByIdHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.H
 				rm, err := model.Map(Transform)(processor.ByIdProvider(tenantId))()
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to get tenant")
-					w.WriteHeader(http.StatusNotFound)
+					rest.WriteError(d.Logger())(w)(err)
+					return
+				}
+
+				rm, err = includeConfigurations(d.Logger(), d.Context(), db, tenantId, rest.ParseQueryList(r, "include"))(rm)
+				if err != nil {
+					d.Logger().WithError(err).Error("Failed to include tenant configurations")
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
@@ -54,6 +80,44 @@ func GetTenantByIdHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.H
 	}
 }
 
+// includeConfigurations returns a function which adds the requested configuration resources of a tenant to its
+// RestModel. Resources are resolved through the tenant's parent, as they are for the configuration endpoints.
+func includeConfigurations(l logrus.FieldLogger, ctx context.Context, db *gorm.DB, tenantId uuid.UUID, includes []string) func(rm RestModel) (RestModel, error) {
+	return func(rm RestModel) (RestModel, error) {
+		processor := configuration.NewProcessor(l, ctx, db)
+		for _, include := range includes {
+			switch include {
+			case "routes":
+				routes, err := model.SliceMap(configuration.TransformRoute)(emptyIfNotFound(processor.AllRoutesProvider(tenantId)))(model.ParallelMap())()
+				if err != nil {
+					return RestModel{}, err
+				}
+				rm = rm.IncludeRoutes(routes)
+			case "vessels":
+				vessels, err := model.SliceMap(configuration.TransformVessel)(emptyIfNotFound(processor.AllVesselsProvider(tenantId)))(model.ParallelMap())()
+				if err != nil {
+					return RestModel{}, err
+				}
+				rm = rm.IncludeVessels(vessels)
+			default:
+				return RestModel{}, fmt.Errorf("%w: %s", ErrUnknownInclude, include)
+			}
+		}
+		return rm, nil
+	}
+}
+
+// emptyIfNotFound treats a tenant without stored resources of a type as holding none
+func emptyIfNotFound(p model.Provider[[]map[string]interface{}]) model.Provider[[]map[string]interface{}] {
+	return func() ([]map[string]interface{}, error) {
+		resources, err := p()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []map[string]interface{}{}, nil
+		}
+		return resources, err
+	}
+}
+
 // CreateTenantHandler handles POST /tenants
 func CreateTenantHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.HandlerContext, model RestModel) http.HandlerFunc {
 	return func(d *rest.HandlerDependency, c *rest.HandlerContext, model RestModel) http.HandlerFunc {
@@ -61,22 +125,22 @@ func CreateTenantHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 			im, err := Extract(model)
 			if err != nil {
 				d.Logger().WithError(err).Error("Failed to extract tenant data")
-				w.WriteHeader(http.StatusBadRequest)
+				rest.WriteError(d.Logger())(w)(err)
 				return
 			}
 
 			processor := NewProcessor(d.Logger(), d.Context(), db)
-			tenant, err := processor.CreateAndEmit(im.Name(), im.Region(), im.MajorVersion(), im.MinorVersion())
+			tenant, err := processor.CreateAndEmit(im.Name(), im.Region(), im.MajorVersion(), im.MinorVersion(), im.ParentId())
 			if err != nil {
 				d.Logger().WithError(err).Error("Failed to create tenant")
-				w.WriteHeader(http.StatusInternalServerError)
+				rest.WriteError(d.Logger())(w)(err)
 				return
 			}
 
 			rm, err := Transform(tenant)
 			if err != nil {
 				d.Logger().WithError(err).Error("Failed to transform tenant")
-				w.WriteHeader(http.StatusInternalServerError)
+				rest.WriteError(d.Logger())(w)(err)
 				return
 			}
 
@@ -96,22 +160,22 @@ func UpdateTenantHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 				im, err := Extract(model)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to extract tenant data")
-					w.WriteHeader(http.StatusBadRequest)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
 				processor := NewProcessor(d.Logger(), d.Context(), db)
-				tenant, err := processor.UpdateAndEmit(tenantId, im.Name(), im.Region(), im.MajorVersion(), im.MinorVersion())
+				tenant, err := processor.UpdateAndEmit(tenantId, im.Name(), im.Region(), im.MajorVersion(), im.MinorVersion(), im.ParentId())
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to update tenant")
-					w.WriteHeader(http.StatusInternalServerError)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
 				rm, err := Transform(tenant)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to transform tenant")
-					w.WriteHeader(http.StatusInternalServerError)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
@@ -132,7 +196,7 @@ func DeleteTenantHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 				err := processor.DeleteAndEmit(tenantId)
 				if err != nil {
 					d.Logger().WithError(err).Error("Failed to delete tenant")
-					w.WriteHeader(http.StatusInternalServerError)
+					rest.WriteError(d.Logger())(w)(err)
 					return
 				}
 
@@ -142,6 +206,25 @@ func DeleteTenantHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.Ha
 	}
 }
 
+// ReconcileSnapshotsHandler handles POST /admin/tenants/snapshot
+func ReconcileSnapshotsHandler(db *gorm.DB) func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return func(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			processor := NewProcessor(d.Logger(), d.Context(), db)
+			rm, err := model.Map(TransformReconciliation)(processor.ReconcileSnapshotsAndEmit)()
+			if err != nil {
+				d.Logger().WithError(err).Error("Failed to reconcile tenant snapshots")
+				rest.WriteError(d.Logger())(w)(err)
+				return
+			}
+
+			query := r.URL.Query()
+			queryParams := jsonapi.ParseQueryFields(&query)
+			server.MarshalResponse[ReconciliationRestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(rm)
+		}
+	}
+}
+
 // RegisterRoutes registers the tenant routes
 func RegisterRoutes(db *gorm.DB) func(si jsonapi.ServerInformation) server.RouteInitializer {
 	return func(si jsonapi.ServerInformation) server.RouteInitializer {
@@ -151,9 +234,24 @@ func RegisterRoutes(db *gorm.DB) func(si jsonapi.ServerInformation) server.Route
 
 			r.HandleFunc("/tenants", registerHandler("get_all_tenants", GetAllTenantsHandler(db))).Methods(http.MethodGet)
 			r.HandleFunc("/tenants/{tenantId}", registerHandler("get_tenant_by_id", GetTenantByIdHandler(db))).Methods(http.MethodGet)
-			r.HandleFunc("/tenants", registerInputHandler("create_tenant", CreateTenantHandler(db))).Methods(http.MethodPost)
+			r.HandleFunc("/tenants", registerInputHandler("create_tenant", idempotency.Handler[RestModel](db)(CreateTenantHandler(db)))).Methods(http.MethodPost)
 			r.HandleFunc("/tenants/{tenantId}", registerInputHandler("update_tenant", UpdateTenantHandler(db))).Methods(http.MethodPatch)
 			r.HandleFunc("/tenants/{tenantId}", registerHandler("delete_tenant", DeleteTenantHandler(db))).Methods(http.MethodDelete)
+			r.HandleFunc("/admin/tenants/snapshot", registerHandler("reconcile_tenant_snapshots", ReconcileSnapshotsHandler(db))).Methods(http.MethodPost)
 		}
 	}
 }
+
+// Operations describes the tenant routes
+func Operations() []openapi.Operation {
+	include := openapi.QueryParameter("include", "Comma separated related resources to include (routes, vessels)", false)
+	idempotencyKey := openapi.HeaderParameter(idempotency.Header, "Key making the request safe to retry")
+	return []openapi.Operation{
+		{Name: "get_all_tenants", Method: http.MethodGet, Path: "/tenants", Summary: "List tenants", Tag: "tenants", Response: RestModel{}, List: true},
+		{Name: "get_tenant_by_id", Method: http.MethodGet, Path: "/tenants/{tenantId}", Summary: "Get a tenant", Tag: "tenants", Parameters: []openapi.Parameter{include}, Response: RestModel{}},
+		{Name: "create_tenant", Method: http.MethodPost, Path: "/tenants", Summary: "Create a tenant", Tag: "tenants", Parameters: []openapi.Parameter{idempotencyKey}, Request: RestModel{}, Response: RestModel{}, Status: http.StatusCreated},
+		{Name: "update_tenant", Method: http.MethodPatch, Path: "/tenants/{tenantId}", Summary: "Update a tenant", Tag: "tenants", Request: RestModel{}, Response: RestModel{}},
+		{Name: "delete_tenant", Method: http.MethodDelete, Path: "/tenants/{tenantId}", Summary: "Delete a tenant", Tag: "tenants", Status: http.StatusNoContent},
+		{Name: "reconcile_tenant_snapshots", Method: http.MethodPost, Path: "/admin/tenants/snapshot", Summary: "Republish every tenant to the snapshot topic", Tag: "tenants", Response: ReconciliationRestModel{}},
+	}
+}
@@ -1,12 +1,23 @@
 package tenant
 
+import (
+	"atlas-tenants/configuration"
+	"github.com/google/uuid"
+	"github.com/jtumidanski/api2go/jsonapi"
+)
+
 // RestModel is the JSON:API resource for tenants
 type RestModel struct {
-	Id           string `json:"-"`
-	Name         string `json:"name"`
-	Region       string `json:"region"`
-	MajorVersion uint16 `json:"majorVersion"`
-	MinorVersion uint16 `json:"minorVersion"`
+	Id            string                          `json:"-"`
+	Name          string                          `json:"name"`
+	Region        string                          `json:"region"`
+	MajorVersion  uint16                          `json:"majorVersion"`
+	MinorVersion  uint16                          `json:"minorVersion"`
+	ParentId      string                          `json:"parentId,omitempty"`
+	Routes        []configuration.RouteRestModel  `json:"-"`
+	Vessels       []configuration.VesselRestModel `json:"-"`
+	routesLoaded  bool
+	vesselsLoaded bool
 }
 
 // GetID returns the resource ID
@@ -25,23 +36,136 @@ func (r RestModel) GetName() string {
 	return "tenants"
 }
 
+// GetReferences returns the relationships of the tenant to its configuration resources
+func (r RestModel) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         "routes",
+			Name:         "routes",
+			IsNotLoaded:  !r.routesLoaded,
+			Relationship: jsonapi.ToManyRelationship,
+		},
+		{
+			Type:         "vessels",
+			Name:         "vessels",
+			IsNotLoaded:  !r.vesselsLoaded,
+			Relationship: jsonapi.ToManyRelationship,
+		},
+	}
+}
+
+// GetReferencedIDs returns the IDs of the included configuration resources
+func (r RestModel) GetReferencedIDs() []jsonapi.ReferenceID {
+	var result []jsonapi.ReferenceID
+	for _, rm := range r.Routes {
+		result = append(result, jsonapi.ReferenceID{ID: rm.GetID(), Type: rm.GetName(), Name: "routes", Relationship: jsonapi.ToManyRelationship})
+	}
+	for _, vm := range r.Vessels {
+		result = append(result, jsonapi.ReferenceID{ID: vm.GetID(), Type: vm.GetName(), Name: "vessels", Relationship: jsonapi.ToManyRelationship})
+	}
+	return result
+}
+
+// GetReferencedStructs returns the included configuration resources
+func (r RestModel) GetReferencedStructs() []jsonapi.MarshalIdentifier {
+	var result []jsonapi.MarshalIdentifier
+	for _, rm := range r.Routes {
+		result = append(result, rm)
+	}
+	for _, vm := range r.Vessels {
+		result = append(result, vm)
+	}
+	return result
+}
+
+// SetToOneReferenceID accepts relationships echoed back by clients. Configuration resources are not modified through
+// the tenant resource, so they are ignored.
+func (r *RestModel) SetToOneReferenceID(name, ID string) error {
+	return nil
+}
+
+// SetToManyReferenceIDs accepts relationships echoed back by clients, ignoring them like SetToOneReferenceID
+func (r *RestModel) SetToManyReferenceIDs(name string, IDs []string) error {
+	return nil
+}
+
+// IncludeRoutes returns a copy of the RestModel which includes the given routes
+func (r RestModel) IncludeRoutes(routes []configuration.RouteRestModel) RestModel {
+	r.Routes = routes
+	r.routesLoaded = true
+	return r
+}
+
+// IncludeVessels returns a copy of the RestModel which includes the given vessels
+func (r RestModel) IncludeVessels(vessels []configuration.VesselRestModel) RestModel {
+	r.Vessels = vessels
+	r.vesselsLoaded = true
+	return r
+}
+
 // Transform converts a Model to a RestModel
 func Transform(m Model) (RestModel, error) {
+	parentId := ""
+	if m.HasParent() {
+		parentId = m.ParentId().String()
+	}
 	return RestModel{
 		Id:           m.Id().String(),
 		Name:         m.Name(),
 		Region:       m.Region(),
 		MajorVersion: m.MajorVersion(),
 		MinorVersion: m.MinorVersion(),
+		ParentId:     parentId,
 	}, nil
 }
 
 // Extract converts a RestModel to parameters for creating or updating a Model
 func Extract(r RestModel) (Model, error) {
+	parentId := uuid.Nil
+	if r.ParentId != "" {
+		var err error
+		parentId, err = uuid.Parse(r.ParentId)
+		if err != nil {
+			return Model{}, ErrInvalidParentId.Wrap(err)
+		}
+	}
 	return NewBuilder().
 		SetName(r.Name).
 		SetRegion(r.Region).
 		SetMajorVersion(r.MajorVersion).
 		SetMinorVersion(r.MinorVersion).
+		SetParentId(parentId).
 		Build(), nil
 }
+
+// ReconciliationRestModel is the JSON:API resource for republications of the tenant snapshot topic
+type ReconciliationRestModel struct {
+	Id         string `json:"-"`
+	Published  int    `json:"published"`
+	Tombstones int    `json:"tombstones"`
+}
+
+// GetID returns the resource ID
+func (r ReconciliationRestModel) GetID() string {
+	return r.Id
+}
+
+// SetID sets the resource ID
+func (r *ReconciliationRestModel) SetID(id string) error {
+	r.Id = id
+	return nil
+}
+
+// GetName returns the resource name
+func (r ReconciliationRestModel) GetName() string {
+	return "tenantSnapshots"
+}
+
+// TransformReconciliation converts a Reconciliation to a ReconciliationRestModel
+func TransformReconciliation(r Reconciliation) (ReconciliationRestModel, error) {
+	return ReconciliationRestModel{
+		Id:         r.Id().String(),
+		Published:  r.Published(),
+		Tombstones: r.Tombstones(),
+	}, nil
+}
@@ -1,56 +1,131 @@
 package tracing
 
 import (
+	"context"
 	"fmt"
-	"github.com/opentracing/opentracing-go"
 	"github.com/sirupsen/logrus"
-	"github.com/uber/jaeger-client-go/config"
-	"io"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func InitTracer(l logrus.FieldLogger) func(serviceName string) (io.Closer, error) {
-	return func(serviceName string) (io.Closer, error) {
-		jaegerHostPort := os.Getenv("JAEGER_HOST_PORT")
-		cfg := &config.Configuration{
-			ServiceName: serviceName,
-			Sampler:     &config.SamplerConfig{Type: "const", Param: 1},
-			Reporter:    &config.ReporterConfig{LogSpans: true, LocalAgentHostPort: jaegerHostPort},
-		}
-		tracer, closer, err := cfg.NewTracer(config.Logger(LogrusAdapter{logger: l}))
+// InstrumentationName identifies the spans created by the service itself
+const InstrumentationName = "atlas-tenants"
+
+// InitTracer installs a global OpenTelemetry tracer provider exporting spans over OTLP. The exporter, sampler and
+// resource are configured from the standard OTEL_* environment variables.
+func InitTracer(l logrus.FieldLogger) func(serviceName string) (*sdktrace.TracerProvider, error) {
+	return func(serviceName string) (*sdktrace.TracerProvider, error) {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			l.WithError(err).Warn("OpenTelemetry error.")
+		}))
+		otel.SetTextMapPropagator(Propagator())
+
+		ctx := context.Background()
+		res, err := resource.New(ctx,
+			resource.WithAttributes(semconv.ServiceName(serviceName)),
+			resource.WithFromEnv(),
+			resource.WithTelemetrySDK(),
+			resource.WithHost(),
+		)
 		if err != nil {
 			return nil, err
 		}
-		opentracing.SetGlobalTracer(tracer)
-		return closer, nil
-	}
-}
 
-func Teardown(l logrus.FieldLogger) func(tc io.Closer) func() {
-	return func(tc io.Closer) func() {
-		return func() {
-			err := tc.Close()
+		opts := []sdktrace.TracerProviderOption{
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(Sampler()),
+		}
+		if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); !disabled {
+			exporter, err := newExporter(ctx)
 			if err != nil {
-				l.WithError(err).Errorf("Unable to close tracer.")
+				return nil, err
 			}
+			opts = append(opts, sdktrace.WithBatcher(exporter))
 		}
+
+		tp := sdktrace.NewTracerProvider(opts...)
+		otel.SetTracerProvider(tp)
+		return tp, nil
+	}
+}
+
+// Propagator reads and writes W3C trace context and baggage, and also reads and writes the Jaeger uber-trace-id
+// header so spans continue across services still emitting it
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}, jaeger.Jaeger{})
+}
+
+// newExporter creates an OTLP exporter using the protocol from OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or
+// OTEL_EXPORTER_OTLP_PROTOCOL. The exporters read their endpoint, headers and timeouts from the environment.
+func newExporter(ctx context.Context) (*otlptrace.Exporter, error) {
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	switch protocol {
+	case "", "http/protobuf":
+		return otlptracehttp.New(ctx)
+	case "grpc":
+		return otlptracegrpc.New(ctx)
 	}
+	return nil, fmt.Errorf("unsupported OTLP protocol [%s]", protocol)
 }
 
-type LogrusAdapter struct {
-	logger logrus.FieldLogger
+// Sampler returns the sampler named by OTEL_TRACES_SAMPLER, with the ratio from OTEL_TRACES_SAMPLER_ARG. Without
+// configuration, root spans are sampled at the configured ratio (default 1.0) and child spans follow their parent.
+func Sampler() sdktrace.Sampler {
+	ratio := 1.0
+	if v, err := strconv.ParseFloat(os.Getenv("OTEL_TRACES_SAMPLER_ARG"), 64); err == nil && v >= 0 && v <= 1 {
+		ratio = v
+	}
+
+	switch strings.ToLower(os.Getenv("OTEL_TRACES_SAMPLER")) {
+	case "always_on":
+		return sdktrace.AlwaysSample()
+	case "always_off":
+		return sdktrace.NeverSample()
+	case "traceidratio":
+		return sdktrace.TraceIDRatioBased(ratio)
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample())
+	}
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
 }
 
-func (l LogrusAdapter) Error(msg string) {
-	l.logger.Error(msg)
+func Teardown(l logrus.FieldLogger) func(tp *sdktrace.TracerProvider) func() {
+	return func(tp *sdktrace.TracerProvider) func() {
+		return func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := tp.Shutdown(ctx)
+			if err != nil {
+				l.WithError(err).Errorf("Unable to close tracer.")
+			}
+		}
+	}
 }
 
-func (l LogrusAdapter) Infof(msg string, args ...interface{}) {
-	l.logger.Infof(msg, args)
+// Tracer returns the tracer for spans created by the service itself
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
 }
 
-func StartSpan(l logrus.FieldLogger, name string, opts ...opentracing.StartSpanOption) (logrus.FieldLogger, opentracing.Span) {
-	span := opentracing.StartSpan(name, opts...)
-	sl := l.WithField("span.id", fmt.Sprintf("%v", span))
-	return sl, span
+func StartSpan(l logrus.FieldLogger, ctx context.Context, name string, opts ...trace.SpanStartOption) (logrus.FieldLogger, context.Context, trace.Span) {
+	sctx, span := Tracer().Start(ctx, name, opts...)
+	sl := l.WithFields(logrus.Fields{"trace.id": span.SpanContext().TraceID().String(), "span.id": span.SpanContext().SpanID().String()})
+	return sl, sctx, span
 }